@@ -0,0 +1,225 @@
+package phone
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrInvalidNumber 手机号格式错误
+	ErrInvalidNumber = errors.New("手机号格式错误")
+	// ErrUnsupportedCountry 无法识别的地区代码（如 defaultCountry 为未注册的 ZZ，可通过 RegisterCountry 添加）
+	ErrUnsupportedCountry = errors.New("不支持的国家代码")
+)
+
+// E.164 号码（含国家代码）的位数范围，未注册号段规则的国家只做此通用校验
+const (
+	minDigits = 7
+	maxDigits = 15
+)
+
+// Country 国家或地区的手机号规则
+type Country struct {
+	Region      string         // 地区代码（ISO 3166-1，如 CN）
+	CallingCode string         // 国家代码（不含 +，如 86）
+	TrunkPrefix string         // 国内长途前缀（如 0），本地格式输入时去掉
+	Mobile      *regexp.Regexp // 手机号规则（不含国家代码和长途前缀）
+}
+
+// Number 解析后的手机号
+// 国家代码未注册号段规则时 Region 为空；国际格式输入无法拆分国家代码和国内号码时 CallingCode 也为空，National 为完整号码
+type Number struct {
+	Region      string // 地区代码（如 CN）
+	CallingCode string // 国家代码（不含 +，如 86）
+	National    string // 国内号码（如 13800138000）
+}
+
+// E164 E.164 格式（如 +8613800138000）
+func (n *Number) E164() string {
+	return "+" + n.CallingCode + n.National
+}
+
+// String 实现 fmt.Stringer，返回 E.164 格式
+func (n *Number) String() string {
+	return n.E164()
+}
+
+var (
+	mu       sync.RWMutex
+	byCode   = make(map[string]*Country) // 国家代码 -> 规则
+	byRegion = make(map[string]*Country) // 地区代码 -> 规则
+)
+
+// separator 常见分隔符：空格（含全角、不换行空格）、连字符、括号、点
+var separator = strings.NewReplacer(" ", "", "-", "", "(", "", ")", "", ".", "", "\u00a0", "", "\u3000", "")
+
+func init() {
+	for _, country := range []*Country{
+		{Region: "CN", CallingCode: "86", Mobile: regexp.MustCompile(`^1[3-9]\d{9}$`)},
+		{Region: "HK", CallingCode: "852", Mobile: regexp.MustCompile(`^[4-79]\d{7}$`)},
+		{Region: "MO", CallingCode: "853", Mobile: regexp.MustCompile(`^6\d{7}$`)},
+		{Region: "TW", CallingCode: "886", TrunkPrefix: "0", Mobile: regexp.MustCompile(`^9\d{8}$`)},
+		{Region: "US", CallingCode: "1", TrunkPrefix: "1", Mobile: regexp.MustCompile(`^[2-9]\d{2}[2-9]\d{6}$`)}, // 北美编号计划（含加拿大）
+		{Region: "GB", CallingCode: "44", TrunkPrefix: "0", Mobile: regexp.MustCompile(`^7\d{9}$`)},
+		{Region: "JP", CallingCode: "81", TrunkPrefix: "0", Mobile: regexp.MustCompile(`^[789]0\d{8}$`)},
+		{Region: "KR", CallingCode: "82", TrunkPrefix: "0", Mobile: regexp.MustCompile(`^1\d{8,9}$`)},
+		{Region: "SG", CallingCode: "65", Mobile: regexp.MustCompile(`^[89]\d{7}$`)},
+		{Region: "MY", CallingCode: "60", TrunkPrefix: "0", Mobile: regexp.MustCompile(`^1\d{8,9}$`)},
+		{Region: "TH", CallingCode: "66", TrunkPrefix: "0", Mobile: regexp.MustCompile(`^[689]\d{8}$`)},
+		{Region: "VN", CallingCode: "84", TrunkPrefix: "0", Mobile: regexp.MustCompile(`^[35789]\d{8}$`)},
+		{Region: "PH", CallingCode: "63", TrunkPrefix: "0", Mobile: regexp.MustCompile(`^9\d{9}$`)},
+		{Region: "ID", CallingCode: "62", TrunkPrefix: "0", Mobile: regexp.MustCompile(`^8\d{8,11}$`)},
+		{Region: "IN", CallingCode: "91", TrunkPrefix: "0", Mobile: regexp.MustCompile(`^[6-9]\d{9}$`)},
+		{Region: "AU", CallingCode: "61", TrunkPrefix: "0", Mobile: regexp.MustCompile(`^4\d{8}$`)},
+		{Region: "DE", CallingCode: "49", TrunkPrefix: "0", Mobile: regexp.MustCompile(`^1[5-7]\d{8,9}$`)},
+		{Region: "FR", CallingCode: "33", TrunkPrefix: "0", Mobile: regexp.MustCompile(`^[67]\d{8}$`)},
+		{Region: "RU", CallingCode: "7", TrunkPrefix: "8", Mobile: regexp.MustCompile(`^[79]\d{9}$`)}, // 含哈萨克斯坦
+		{Region: "AE", CallingCode: "971", TrunkPrefix: "0", Mobile: regexp.MustCompile(`^5\d{8}$`)},
+		{Region: "BR", CallingCode: "55", TrunkPrefix: "0", Mobile: regexp.MustCompile(`^[1-9]{2}9\d{8}$`)},
+	} {
+		RegisterCountry(country)
+	}
+}
+
+// RegisterCountry 注册国家或地区的手机号规则，相同国家代码重复注册时覆盖
+func RegisterCountry(country *Country) {
+	mu.Lock()
+	defer mu.Unlock()
+	byCode[country.CallingCode] = country
+	byRegion[strings.ToUpper(country.Region)] = country
+}
+
+// Parse 解析手机号
+// 支持 +86 138-0013-8000、008613800138000 等国际格式，以及本地格式（使用 defaultCountry 指定的国家，
+// 可以是国家代码 +86、86 或地区代码 CN，为空时默认中国）
+// 已注册的国家按号段规则校验，其他国家代码只校验 E.164 通用规则（纯数字，含国家代码不超过 15 位）
+func Parse(input, defaultCountry string) (*Number, error) {
+	digits := separator.Replace(strings.TrimSpace(input))
+	switch {
+	case strings.HasPrefix(digits, "+"):
+		return parseInternational(digits[1:])
+	case strings.HasPrefix(digits, "00"):
+		return parseInternational(digits[2:])
+	}
+	if !isDigits(digits) {
+		return nil, ErrInvalidNumber
+	}
+
+	country, err := lookupDefault(defaultCountry)
+	if err != nil {
+		return nil, err
+	}
+	if country.Mobile == nil {
+		return genericNumber(country.CallingCode, digits)
+	}
+	national := digits
+	if country.TrunkPrefix != "" && strings.HasPrefix(national, country.TrunkPrefix) && !country.Mobile.MatchString(national) {
+		national = strings.TrimPrefix(national, country.TrunkPrefix)
+	}
+	if country.Mobile.MatchString(national) {
+		return newNumber(country, national), nil
+	}
+
+	// 省略了 + 的国际格式，如 8613800138000
+	if strings.HasPrefix(digits, country.CallingCode) {
+		if national := digits[len(country.CallingCode):]; country.Mobile.MatchString(national) {
+			return newNumber(country, national), nil
+		}
+	}
+	return nil, ErrInvalidNumber
+}
+
+// Normalize 解析手机号并返回 E.164 格式
+func Normalize(input, defaultCountry string) (string, error) {
+	number, err := Parse(input, defaultCountry)
+	if err != nil {
+		return "", err
+	}
+	return number.E164(), nil
+}
+
+// Valid 手机号是否有效
+func Valid(input, defaultCountry string) bool {
+	_, err := Parse(input, defaultCountry)
+	return err == nil
+}
+
+// parseInternational 解析国际格式（不含 + 或 00）
+func parseInternational(digits string) (*Number, error) {
+	if !isDigits(digits) || len(digits) > maxDigits {
+		return nil, ErrInvalidNumber
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+	// 国家代码为 1~3 位，且互不为前缀
+	for i := 1; i <= 3 && i < len(digits); i++ {
+		country, ok := byCode[digits[:i]]
+		if !ok {
+			continue
+		}
+		national := digits[i:]
+		// 部分用户会在国家代码后保留长途前缀，如 +44 07911 123456
+		if country.TrunkPrefix != "" && !country.Mobile.MatchString(national) {
+			national = strings.TrimPrefix(national, country.TrunkPrefix)
+		}
+		if !country.Mobile.MatchString(national) {
+			return nil, ErrInvalidNumber
+		}
+		return newNumber(country, national), nil
+	}
+	return genericNumber("", digits)
+}
+
+// lookupDefault 查找默认国家，未注册的国家代码返回没有号段规则的 Country
+func lookupDefault(defaultCountry string) (*Country, error) {
+	key := strings.TrimPrefix(strings.TrimSpace(defaultCountry), "+")
+	if key == "" {
+		key = "86"
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+	if country, ok := byCode[key]; ok {
+		return country, nil
+	}
+	if country, ok := byRegion[strings.ToUpper(key)]; ok {
+		return country, nil
+	}
+	if isDigits(key) && len(key) <= 3 {
+		return &Country{CallingCode: key}, nil
+	}
+	return nil, ErrUnsupportedCountry
+}
+
+// genericNumber 按 E.164 通用规则校验（未注册号段规则的国家）
+func genericNumber(callingCode, national string) (*Number, error) {
+	if total := len(callingCode) + len(national); total < minDigits || total > maxDigits {
+		return nil, ErrInvalidNumber
+	}
+	return &Number{CallingCode: callingCode, National: national}, nil
+}
+
+// newNumber 创建手机号
+func newNumber(country *Country, national string) *Number {
+	return &Number{
+		Region:      country.Region,
+		CallingCode: country.CallingCode,
+		National:    national,
+	}
+}
+
+// isDigits 是否为非空纯数字
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
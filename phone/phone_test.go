@@ -0,0 +1,105 @@
+package phone
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		input          string
+		defaultCountry string
+		expected       string
+	}{
+		{"13800138000", "", "+8613800138000"},
+		{"+86 138-0013-8000", "", "+8613800138000"},
+		{"008613800138000", "", "+8613800138000"},
+		{"8613800138000", "+86", "+8613800138000"},
+		{"(+86) 138 0013 8000", "CN", "+8613800138000"},
+		{"+852 5123 4567", "", "+85251234567"},
+		{"0912 345 678", "TW", "+886912345678"},
+		{"+1 (415) 555-2671", "", "+14155552671"},
+		{"415-555-2671", "+1", "+14155552671"},
+		{"07911 123456", "GB", "+447911123456"},
+		{"+44 07911 123456", "", "+447911123456"},
+		{"090-1234-5678", "JP", "+819012345678"},
+		{"010-1234-5678", "82", "+821012345678"},
+		{"+65 9123 4567", "", "+6591234567"},
+		{"8 912 345-67-89", "RU", "+79123456789"},
+		{"+7 701 234 5678", "", "+77012345678"},
+		// 未注册的国家代码只做通用校验
+		{"+880 1712-345678", "", "+8801712345678"},
+		{"00880 1712 345678", "", "+8801712345678"},
+		{"1712 345678", "+880", "+8801712345678"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			result, err := Normalize(tt.input, tt.defaultCountry)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		input          string
+		defaultCountry string
+		expected       error
+	}{
+		{"", "", ErrInvalidNumber},
+		{"abc", "", ErrInvalidNumber},
+		{"12800138000", "", ErrInvalidNumber},           // 中国手机号以 13~19 开头
+		{"1380013800", "", ErrInvalidNumber},            // 位数不足
+		{"138001380001", "", ErrInvalidNumber},          // 位数过多
+		{"+86 1380013800a", "", ErrInvalidNumber},       // 非数字
+		{"+44 20 7946 0958", "", ErrInvalidNumber},      // 英国固定电话
+		{"+880 1712 3456 7890 1", "", ErrInvalidNumber}, // 超过 15 位
+		{"+880 123", "", ErrInvalidNumber},              // 不足 7 位
+		{"5123 4567", "ZZ", ErrUnsupportedCountry},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := Parse(tt.input, tt.defaultCountry)
+			assert.ErrorIs(t, err, tt.expected)
+			assert.False(t, Valid(tt.input, tt.defaultCountry))
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	number, err := Parse("+86 138-0013-8000", "")
+	require.NoError(t, err)
+	assert.Equal(t, &Number{Region: "CN", CallingCode: "86", National: "13800138000"}, number)
+	assert.Equal(t, "+8613800138000", number.String())
+}
+
+func TestParseUnregistered(t *testing.T) {
+	// 国际格式无法拆分国家代码
+	number, err := Parse("+880 1712-345678", "")
+	require.NoError(t, err)
+	assert.Equal(t, &Number{National: "8801712345678"}, number)
+	assert.Equal(t, "+8801712345678", number.E164())
+
+	number, err = Parse("1712 345678", "880")
+	require.NoError(t, err)
+	assert.Equal(t, &Number{CallingCode: "880", National: "1712345678"}, number)
+}
+
+func TestRegisterCountry(t *testing.T) {
+	number, err := Parse("+998 90 123 45 67", "")
+	require.NoError(t, err)
+	assert.Empty(t, number.Region)
+
+	RegisterCountry(&Country{Region: "UZ", CallingCode: "998", TrunkPrefix: "8", Mobile: regexp.MustCompile(`^9\d{8}$`)})
+	number, err = Parse("+998 90 123 45 67", "")
+	require.NoError(t, err)
+	assert.Equal(t, &Number{Region: "UZ", CallingCode: "998", National: "901234567"}, number)
+
+	// 注册后按号段规则校验
+	_, err = Parse("+998 12 345 67", "")
+	assert.ErrorIs(t, err, ErrInvalidNumber)
+}
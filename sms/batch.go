@@ -0,0 +1,186 @@
+package sms
+
+import (
+	"context"
+	"sync"
+)
+
+// BatchResult 批量发送中单个接收人的结果
+type BatchResult struct {
+	Request  *SendRequest  // 发送请求
+	Response *SendResponse // 发送响应（发送失败时可能为空）
+	Err      error         // 发送失败原因（限流、配额、免打扰或服务商错误）
+}
+
+// Success 是否发送成功
+func (r *BatchResult) Success() bool {
+	return r.Err == nil && r.Response != nil && r.Response.Success
+}
+
+// BatchResponse 批量发送结果
+type BatchResponse struct {
+	Results   []*BatchResult // 每个接收人的结果，与请求顺序一致
+	Succeeded int            // 成功数
+	Failed    int            // 失败数
+}
+
+// Failures 发送失败的结果
+func (r *BatchResponse) Failures() []*BatchResult {
+	var failures []*BatchResult
+	for _, result := range r.Results {
+		if !result.Success() {
+			failures = append(failures, result)
+		}
+	}
+	return failures
+}
+
+// SendBatch 批量发送短信，每个接收人单独进行免打扰、限流和配额检查，部分失败不影响其他接收人
+// 服务商实现 BatchProvider 时，通过检查的请求按模板和外部ID合并为批量请求发送；否则逐条调用 Send
+// 设置了 IdempotencyKey 的请求始终逐条发送；返回的 error 仅在 ctx 取消时不为空
+func (c *Client) SendBatch(ctx context.Context, reqs []*SendRequest) (*BatchResponse, error) {
+	results := make([]*BatchResult, len(reqs))
+
+	var batched []int
+	for i, req := range reqs {
+		if c.batcher != nil && req.IdempotencyKey == "" {
+			batched = append(batched, i)
+			continue
+		}
+		results[i] = &BatchResult{Request: req}
+	}
+
+	// 逐条发送
+	parallel(len(reqs), c.batchWorkers, func(i int) {
+		if results[i] == nil {
+			return
+		}
+		results[i].Response, results[i].Err = c.Send(ctx, reqs[i])
+	})
+
+	if len(batched) > 0 {
+		c.sendBatched(ctx, reqs, batched, results)
+	}
+
+	response := &BatchResponse{Results: results}
+	for _, result := range results {
+		if result.Success() {
+			response.Succeeded++
+		} else {
+			response.Failed++
+		}
+	}
+	return response, ctx.Err()
+}
+
+// batchItem 批量发送中通过检查的请求
+type batchItem struct {
+	index  int          // 在原请求中的位置
+	cloned *SendRequest // 注入验证码后的请求
+	code   string       // 验证码（需要保存时）
+}
+
+// sendBatched 通过 BatchProvider 发送 indexes 对应的请求
+func (c *Client) sendBatched(ctx context.Context, reqs []*SendRequest, indexes []int, results []*BatchResult) {
+	// 1. 逐个校验模板参数，检查免打扰、限流、配额
+	items := make([]*batchItem, len(indexes))
+	parallel(len(indexes), c.batchWorkers, func(k int) {
+		i := indexes[k]
+		results[i] = &BatchResult{Request: reqs[i]}
+		cloned, code, err := c.prepare(reqs[i])
+		if err != nil {
+			results[i].Err = err
+			return
+		}
+		if err := c.admit(ctx, reqs[i]); err != nil {
+			results[i].Err = err
+			return
+		}
+		items[k] = &batchItem{index: i, cloned: cloned, code: code}
+	})
+
+	// 2. 按模板和外部ID分组，按服务商上限拆分
+	chunks := chunkBatchItems(items, c.batcher.BatchSize())
+
+	// 3. 批量发送
+	parallel(len(chunks), c.batchWorkers, func(k int) {
+		chunk := chunks[k]
+		batch := make([]*SendRequest, len(chunk))
+		for j, item := range chunk {
+			batch[j] = item.cloned
+		}
+
+		responses, err := c.batcher.SendBatch(ctx, batch)
+		for j, item := range chunk {
+			result := results[item.index]
+			if err != nil {
+				result.Err = err
+			} else if j < len(responses) && responses[j] != nil {
+				result.Response = responses[j]
+				c.estimate(item.cloned, result.Response)
+				if !responses[j].Success {
+					result.Err = responseError(responses[j])
+				}
+			} else {
+				result.Err = responseError(nil)
+			}
+
+			if result.Err == nil && item.code != "" {
+				result.Err = c.codes.Save(ctx, item.cloned.BizID, item.cloned.GetFullPhone(), item.code)
+			}
+			c.record(ctx, result.Request, result.Response, result.Err)
+		}
+	})
+
+	// 未通过检查的请求同样记录
+	for k, item := range items {
+		if item == nil {
+			result := results[indexes[k]]
+			c.record(ctx, result.Request, nil, result.Err)
+		}
+	}
+}
+
+// chunkBatchItems 按模板和外部ID分组（批量请求只能有一个外部ID），每组最多 size 个（保持请求顺序），跳过空项
+func chunkBatchItems(items []*batchItem, size int) [][]*batchItem {
+	type group struct {
+		template string
+		outID    string
+	}
+	var (
+		chunks [][]*batchItem
+		groups = make(map[group]int) // 模板和外部ID -> 当前未满的分组
+	)
+	for _, item := range items {
+		if item == nil {
+			continue
+		}
+		key := group{template: item.cloned.Template, outID: item.cloned.OutID}
+		k, ok := groups[key]
+		if !ok || len(chunks[k]) >= size {
+			k = len(chunks)
+			groups[key] = k
+			chunks = append(chunks, nil)
+		}
+		chunks[k] = append(chunks[k], item)
+	}
+	return chunks
+}
+
+// parallel 以最多 concurrency 个并发执行 fn(0..n-1)
+func parallel(n, concurrency int, fn func(i int)) {
+	var (
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, concurrency)
+	)
+	for i := 0; i < n; i++ {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,114 @@
+package sms
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// batchStubProvider 支持批量发送的服务商，每批返回同一个 MsgID（与阿里云一致）
+type batchStubProvider struct {
+	stubProvider
+
+	mu      sync.Mutex
+	batches [][]*SendRequest
+}
+
+func (p *batchStubProvider) SendBatch(ctx context.Context, reqs []*SendRequest) ([]*SendResponse, error) {
+	p.mu.Lock()
+	p.batches = append(p.batches, reqs)
+	msgID := "batch_" + strconv.Itoa(len(p.batches))
+	p.mu.Unlock()
+
+	if reqs[0].Template == "SMS_FAIL" {
+		return nil, NewSMSError("isv.BUSINESS_LIMIT_CONTROL", "业务限流", false, nil)
+	}
+	responses := make([]*SendResponse, len(reqs))
+	for i := range reqs {
+		responses[i] = &SendResponse{MsgID: msgID, Success: true}
+	}
+	return responses, nil
+}
+
+func (p *batchStubProvider) BatchSize() int {
+	return 2
+}
+
+func TestChunkBatchItems(t *testing.T) {
+	item := func(index int, template, outID string) *batchItem {
+		return &batchItem{index: index, cloned: &SendRequest{Template: template, OutID: outID}}
+	}
+	items := []*batchItem{item(0, "A", ""), item(1, "B", ""), nil, item(3, "A", ""), item(4, "A", ""), item(5, "B", ""), item(6, "A", "order-1")}
+
+	var indexes [][]int
+	for _, chunk := range chunkBatchItems(items, 2) {
+		var chunkIndexes []int
+		for _, item := range chunk {
+			chunkIndexes = append(chunkIndexes, item.index)
+		}
+		indexes = append(indexes, chunkIndexes)
+	}
+	assert.Equal(t, [][]int{{0, 3}, {1, 5}, {4}, {6}}, indexes)
+}
+
+func TestSendBatchPartialFailure(t *testing.T) {
+	ctx := context.Background()
+	rdb, _ := newTestRedis(t)
+	provider := &batchStubProvider{}
+	store := NewRedisMessageStore(rdb, 0)
+	client := NewClient(&ClientConfig{Redis: rdb, Provider: provider, MessageStore: store})
+	client.SetQuota("notice", 100)
+
+	reqs := []*SendRequest{
+		{Phone: "13800138000", Template: "SMS_123", BizID: "notice"},
+		{Phone: "13800138001", Template: "SMS_123", BizID: "notice"},
+		{Phone: "123", Template: "SMS_123", BizID: "notice"}, // 检查阶段失败
+		{Phone: "13800138002", Template: "SMS_123", BizID: "notice", OutID: "order-1"},
+		{Phone: "13800138003", Template: "SMS_123", BizID: "notice"},
+		{Phone: "13800138004", Template: "SMS_FAIL", BizID: "notice"}, // 整批失败
+	}
+	resp, err := client.SendBatch(ctx, reqs)
+	require.NoError(t, err)
+	require.Len(t, resp.Results, len(reqs))
+	for i, result := range resp.Results {
+		assert.Same(t, reqs[i], result.Request)
+	}
+	assert.Equal(t, 4, resp.Succeeded)
+	assert.Equal(t, 2, resp.Failed)
+	assert.ErrorIs(t, resp.Results[2].Err, ErrInvalidParams)
+	assert.Error(t, resp.Results[5].Err)
+	assert.Zero(t, provider.calls, "通过批量接口发送")
+
+	// 按模板和外部ID拆分，每批不超过服务商上限
+	require.Len(t, provider.batches, 4)
+	for _, batch := range provider.batches {
+		assert.LessOrEqual(t, len(batch), 2)
+		for _, req := range batch {
+			assert.Equal(t, batch[0].Template, req.Template)
+			assert.Equal(t, batch[0].OutID, req.OutID)
+		}
+	}
+
+	// 同一批的接收人共用 MsgID，状态报告按手机号更新各自的记录
+	shared := resp.Results[0].Response.MsgID
+	assert.Equal(t, shared, resp.Results[1].Response.MsgID)
+	require.NoError(t, StoreReceipts(store).HandleReceipt(ctx, &StatusResponse{MsgID: shared, Phone: "13800138001", Status: StatusFailed, ErrorMsg: "MK:0005"}))
+
+	records, err := store.Query(ctx, &MessageQuery{Phone: "13800138001"})
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, StatusFailed, records[0].Status)
+	records, err = store.Query(ctx, &MessageQuery{Phone: "13800138000"})
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, StatusPending, records[0].Status)
+
+	// 失败的接收人同样记录
+	records, err = store.Query(ctx, &MessageQuery{Status: []MessageStatus{StatusFailed}})
+	require.NoError(t, err)
+	assert.Len(t, records, 3)
+}
@@ -0,0 +1,525 @@
+package sms
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// BreakerState 熔断器状态
+type BreakerState int
+
+const (
+	BreakerClosed   BreakerState = 0 // 关闭（正常放行）
+	BreakerOpen     BreakerState = 1 // 打开（拒绝请求）
+	BreakerHalfOpen BreakerState = 2 // 半开（放行少量探测请求）
+)
+
+// String 返回状态名称
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half_open"
+	default:
+		return "unknown"
+	}
+}
+
+// breakerBuckets 统计窗口切分的桶数
+const breakerBuckets = 10
+
+// CircuitBreakerConfig 熔断配置
+type CircuitBreakerConfig struct {
+	Name             string        // 熔断器名称（共享状态时作为 Redis key 的一部分，同一服务商在所有实例上应一致）
+	FailureRatio     float64       // 打开熔断的失败率阈值，默认 0.5
+	MinRequests      int           // 窗口内最少请求数，达到后才计算失败率，默认 10
+	Window           time.Duration // 失败率统计窗口，默认 1 分钟
+	CoolDown         time.Duration // 打开后的冷却时间，结束后进入半开状态，默认 30 秒
+	HalfOpenRequests int           // 半开状态放行的探测请求数，全部成功后关闭熔断，默认 1
+
+	// Redis 可选，设置后统计数据和打开状态在所有实例间共享，整个集群一起熔断
+	Redis *redis.Client
+
+	// OnStateChange 状态变化回调（可选）
+	OnStateChange func(name string, from, to BreakerState)
+}
+
+// DefaultCircuitBreakerConfig 默认熔断配置
+func DefaultCircuitBreakerConfig() *CircuitBreakerConfig {
+	return &CircuitBreakerConfig{
+		FailureRatio:     0.5,
+		MinRequests:      10,
+		Window:           time.Minute,
+		CoolDown:         30 * time.Second,
+		HalfOpenRequests: 1,
+	}
+}
+
+// BreakerOpenError 熔断器打开错误
+type BreakerOpenError struct {
+	Name       string        // 熔断器名称
+	RetryAfter time.Duration // 预计恢复时间（半开状态探测名额已满时为 0）
+}
+
+func (e *BreakerOpenError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("熔断器[%s]已打开，%s后重试", e.Name, e.RetryAfter.Round(time.Second))
+	}
+	return fmt.Sprintf("熔断器[%s]已打开", e.Name)
+}
+
+// Unwrap 支持 errors.Is(err, ErrCircuitOpen)
+func (e *BreakerOpenError) Unwrap() error {
+	return ErrCircuitOpen
+}
+
+// CircuitBreakerProvider 熔断装饰器
+type CircuitBreakerProvider struct {
+	provider SMSProvider           // 被装饰的provider
+	config   *CircuitBreakerConfig // 熔断配置
+	store    breakerStore          // 统计数据存储
+
+	mu             sync.Mutex
+	state          BreakerState
+	openUntil      time.Time
+	probes         int // 半开状态已放行的探测请求数
+	probeSuccesses int // 半开状态探测成功数
+}
+
+// NewCircuitBreakerProvider 创建熔断装饰器
+func NewCircuitBreakerProvider(provider SMSProvider, config *CircuitBreakerConfig) *CircuitBreakerProvider {
+	if config == nil {
+		config = DefaultCircuitBreakerConfig()
+	}
+	// 复制配置，填充默认值不影响调用方
+	cloned := *config
+	config = &cloned
+	defaults := DefaultCircuitBreakerConfig()
+	if config.FailureRatio <= 0 {
+		config.FailureRatio = defaults.FailureRatio
+	}
+	if config.MinRequests <= 0 {
+		config.MinRequests = defaults.MinRequests
+	}
+	if config.Window <= 0 {
+		config.Window = defaults.Window
+	}
+	if config.CoolDown <= 0 {
+		config.CoolDown = defaults.CoolDown
+	}
+	if config.HalfOpenRequests <= 0 {
+		config.HalfOpenRequests = defaults.HalfOpenRequests
+	}
+	if config.Name == "" {
+		config.Name = "default"
+	}
+
+	var store breakerStore
+	if config.Redis != nil {
+		store = &redisBreakerStore{redis: config.Redis, name: config.Name, window: config.Window}
+	} else {
+		store = &localBreakerStore{window: config.Window}
+	}
+
+	return &CircuitBreakerProvider{
+		provider: provider,
+		config:   config,
+		store:    store,
+		state:    BreakerClosed,
+	}
+}
+
+// State 获取当前状态
+func (b *CircuitBreakerProvider) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Healthy 是否健康（熔断打开且未到冷却结束时间时不健康）
+func (b *CircuitBreakerProvider) Healthy() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state != BreakerOpen || !time.Now().Before(b.openUntil)
+}
+
+// Send 发送短信（熔断打开时直接拒绝）
+func (b *CircuitBreakerProvider) Send(ctx context.Context, req *SendRequest) (*SendResponse, error) {
+	probe, err := b.allow(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := b.provider.Send(ctx, req)
+
+	switch {
+	case err != nil && ctx.Err() != nil:
+		// 调用方取消，结果不计入统计；探测结果无效，归还名额
+		if probe {
+			b.releaseProbe()
+		}
+	case err != nil:
+		b.onResult(ctx, probe, isBreakerFailure(ErrorTypeOf(err)))
+	case resp == nil:
+		// 没有错误也没有响应，按服务商故障处理
+		b.onResult(ctx, probe, true)
+	case !resp.Success:
+		b.onResult(ctx, probe, isBreakerFailure(GetErrorType(resp.ErrorCode)))
+	default:
+		b.onResult(ctx, probe, false)
+	}
+
+	return resp, err
+}
+
+// QueryStatus 查询短信发送状态（不经过熔断）
+func (b *CircuitBreakerProvider) QueryStatus(ctx context.Context, msgID string) (*StatusResponse, error) {
+	return b.provider.QueryStatus(ctx, msgID)
+}
+
+// QueryStatusByPhone 通过手机号查询短信状态（不经过熔断）
+func (b *CircuitBreakerProvider) QueryStatusByPhone(ctx context.Context, phone string) ([]*StatusResponse, error) {
+	return b.provider.QueryStatusByPhone(ctx, phone)
+}
+
+// QueryMessageStatus 按手机号和消息ID查询短信状态（不经过熔断）
+func (b *CircuitBreakerProvider) QueryMessageStatus(ctx context.Context, phone, msgID string, sentAt time.Time) (*StatusResponse, error) {
+	return queryMessageStatus(ctx, b.provider, phone, msgID, sentAt)
+}
+
+// ========== 辅助方法 ==========
+
+// allow 判断是否放行请求，probe 表示该请求是半开状态的探测请求
+func (b *CircuitBreakerProvider) allow(ctx context.Context) (probe bool, err error) {
+	now := time.Now()
+
+	b.mu.Lock()
+	state, openUntil := b.state, b.openUntil
+	b.mu.Unlock()
+
+	if state == BreakerOpen && now.Before(openUntil) {
+		return false, b.openError(openUntil.Sub(now))
+	}
+
+	// 检查共享状态（其他实例可能已经打开熔断），存储不可用时按本地状态处理
+	remaining, _ := b.store.openRemaining(ctx)
+
+	b.mu.Lock()
+	var transitions [][2]BreakerState
+	defer func() {
+		b.mu.Unlock()
+		b.notify(transitions)
+	}()
+
+	if remaining > 0 {
+		b.openUntil = now.Add(remaining)
+		transitions = b.setState(transitions, BreakerOpen)
+		return false, b.openError(remaining)
+	}
+
+	switch b.state {
+	case BreakerOpen:
+		// 冷却结束，进入半开状态
+		b.probes, b.probeSuccesses = 0, 0
+		transitions = b.setState(transitions, BreakerHalfOpen)
+		fallthrough
+	case BreakerHalfOpen:
+		if b.probes >= b.config.HalfOpenRequests {
+			return false, b.openError(0)
+		}
+		b.probes++
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+// onResult 记录请求结果并处理状态变化
+func (b *CircuitBreakerProvider) onResult(ctx context.Context, probe, failed bool) {
+	// 请求已结束，统计数据的写入不受调用方取消影响
+	ctx = context.WithoutCancel(ctx)
+
+	if probe {
+		b.onProbeResult(ctx, failed)
+		return
+	}
+
+	total, failures, err := b.store.record(ctx, failed)
+	if err != nil || !failed {
+		return
+	}
+	if total < int64(b.config.MinRequests) || float64(failures)/float64(total) < b.config.FailureRatio {
+		return
+	}
+
+	b.mu.Lock()
+	var transitions [][2]BreakerState
+	if b.state == BreakerClosed {
+		b.openUntil = time.Now().Add(b.config.CoolDown)
+		transitions = b.setState(transitions, BreakerOpen)
+	}
+	b.mu.Unlock()
+
+	if len(transitions) > 0 {
+		_ = b.store.trip(ctx, b.config.CoolDown)
+		b.notify(transitions)
+	}
+}
+
+// onProbeResult 处理半开状态探测请求的结果
+func (b *CircuitBreakerProvider) onProbeResult(ctx context.Context, failed bool) {
+	b.mu.Lock()
+	if b.state != BreakerHalfOpen {
+		b.mu.Unlock()
+		return
+	}
+
+	var (
+		transitions [][2]BreakerState
+		trip, reset bool
+	)
+	if failed {
+		// 探测失败，重新打开
+		b.openUntil = time.Now().Add(b.config.CoolDown)
+		transitions = b.setState(transitions, BreakerOpen)
+		trip = true
+	} else {
+		b.probeSuccesses++
+		if b.probeSuccesses >= b.config.HalfOpenRequests {
+			transitions = b.setState(transitions, BreakerClosed)
+			reset = true
+		}
+	}
+	b.mu.Unlock()
+
+	if trip {
+		_ = b.store.trip(ctx, b.config.CoolDown)
+	}
+	if reset {
+		_ = b.store.reset(ctx)
+	}
+	b.notify(transitions)
+}
+
+// releaseProbe 归还半开状态的探测名额
+func (b *CircuitBreakerProvider) releaseProbe() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == BreakerHalfOpen && b.probes > 0 {
+		b.probes--
+	}
+}
+
+// setState 切换状态（需持有锁），返回追加后的状态变化列表
+func (b *CircuitBreakerProvider) setState(transitions [][2]BreakerState, to BreakerState) [][2]BreakerState {
+	if b.state == to {
+		return transitions
+	}
+	from := b.state
+	b.state = to
+	return append(transitions, [2]BreakerState{from, to})
+}
+
+// notify 触发状态变化回调（不持有锁）
+func (b *CircuitBreakerProvider) notify(transitions [][2]BreakerState) {
+	if b.config.OnStateChange == nil {
+		return
+	}
+	for _, t := range transitions {
+		b.config.OnStateChange(b.config.Name, t[0], t[1])
+	}
+}
+
+// openError 创建熔断打开错误
+func (b *CircuitBreakerProvider) openError(retryAfter time.Duration) error {
+	openErr := &BreakerOpenError{Name: b.config.Name, RetryAfter: retryAfter}
+	return NewSMSError("CIRCUIT_OPEN", "服务商熔断中", false, openErr).WithType(ErrorTypeCircuitBreak)
+}
+
+// isBreakerFailure 判断错误是否计入熔断失败（只统计服务商侧的故障）
+func isBreakerFailure(errType ErrorType) bool {
+	switch errType {
+	case ErrorTypeFormat, ErrorTypeInvalidPhone:
+		// 请求本身的问题，不代表服务商故障
+		return false
+	case ErrorTypeRateLimit:
+		// 服务商对单个号码的频率限制（如阿里云 isv.BUSINESS_LIMIT_CONTROL），熔断状态在实例间共享，
+		// 计入失败会让一个号码的限流熔断所有号码的发送
+		return false
+	default:
+		return true
+	}
+}
+
+// ========== 统计数据存储 ==========
+
+// breakerStore 熔断统计数据存储
+type breakerStore interface {
+	// record 记录一次请求结果，返回窗口内的总数和失败数
+	record(ctx context.Context, failed bool) (total, failures int64, err error)
+	// reset 清空窗口统计和打开状态
+	reset(ctx context.Context) error
+	// trip 打开熔断
+	trip(ctx context.Context, coolDown time.Duration) error
+	// openRemaining 获取熔断打开的剩余时间，未打开时返回 0
+	openRemaining(ctx context.Context) (time.Duration, error)
+}
+
+// localBreakerStore 进程内存储
+type localBreakerStore struct {
+	mu        sync.Mutex
+	window    time.Duration
+	buckets   [breakerBuckets]breakerBucket
+	openUntil time.Time
+}
+
+type breakerBucket struct {
+	index    int64
+	total    int64
+	failures int64
+}
+
+func (s *localBreakerStore) record(_ context.Context, failed bool) (int64, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	index := bucketIndex(time.Now(), s.window)
+	bucket := &s.buckets[index%breakerBuckets]
+	if bucket.index != index {
+		*bucket = breakerBucket{index: index}
+	}
+	bucket.total++
+	if failed {
+		bucket.failures++
+	}
+
+	var total, failures int64
+	for _, b := range s.buckets {
+		if b.index > index-breakerBuckets {
+			total += b.total
+			failures += b.failures
+		}
+	}
+	return total, failures, nil
+}
+
+func (s *localBreakerStore) reset(_ context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.buckets = [breakerBuckets]breakerBucket{}
+	s.openUntil = time.Time{}
+	return nil
+}
+
+func (s *localBreakerStore) trip(_ context.Context, coolDown time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.openUntil = time.Now().Add(coolDown)
+	return nil
+}
+
+func (s *localBreakerStore) openRemaining(_ context.Context) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if remaining := time.Until(s.openUntil); remaining > 0 {
+		return remaining, nil
+	}
+	return 0, nil
+}
+
+// redisBreakerStore Redis 共享存储
+type redisBreakerStore struct {
+	redis  *redis.Client
+	name   string
+	window time.Duration
+}
+
+// breakerRecordScript 记录一次请求结果并汇总窗口内的统计
+// KEYS[1]：当前统计桶；KEYS[1..n]：窗口内的统计桶；ARGV[1]：是否失败（1/0）；ARGV[2]：统计桶过期时间（毫秒）
+// 返回 {总数, 失败数}
+var breakerRecordScript = redis.NewScript(`
+redis.call('HINCRBY', KEYS[1], 'total', 1)
+if ARGV[1] == '1' then
+	redis.call('HINCRBY', KEYS[1], 'failures', 1)
+end
+redis.call('PEXPIRE', KEYS[1], ARGV[2])
+local total, failures = 0, 0
+for i = 1, #KEYS do
+	local values = redis.call('HMGET', KEYS[i], 'total', 'failures')
+	total = total + (tonumber(values[1]) or 0)
+	failures = failures + (tonumber(values[2]) or 0)
+end
+return {total, failures}
+`)
+
+func (s *redisBreakerStore) record(ctx context.Context, failed bool) (int64, int64, error) {
+	keys := s.windowKeys()
+	flag := "0"
+	if failed {
+		flag = "1"
+	}
+	ttl := s.window + s.window/breakerBuckets
+
+	counts, err := breakerRecordScript.Run(ctx, s.redis, keys, flag, ttl.Milliseconds()).Int64Slice()
+	if err != nil {
+		return 0, 0, err
+	}
+	return counts[0], counts[1], nil
+}
+
+func (s *redisBreakerStore) reset(ctx context.Context) error {
+	return s.redis.Del(ctx, append(s.windowKeys(), s.openKey())...).Err()
+}
+
+// windowKeys 获取窗口内的统计桶key（当前桶在前）
+func (s *redisBreakerStore) windowKeys() []string {
+	index := bucketIndex(time.Now(), s.window)
+	keys := make([]string, 0, breakerBuckets)
+	for i := index; i > index-breakerBuckets; i-- {
+		keys = append(keys, s.bucketKey(i))
+	}
+	return keys
+}
+
+func (s *redisBreakerStore) trip(ctx context.Context, coolDown time.Duration) error {
+	return s.redis.Set(ctx, s.openKey(), "1", coolDown).Err()
+}
+
+func (s *redisBreakerStore) openRemaining(ctx context.Context) (time.Duration, error) {
+	ttl, err := s.redis.PTTL(ctx, s.openKey()).Result()
+	if err != nil {
+		return 0, err
+	}
+	// key 不存在时返回负数
+	if ttl < 0 {
+		return 0, nil
+	}
+	return ttl, nil
+}
+
+// bucketKey 获取统计桶的key
+func (s *redisBreakerStore) bucketKey(index int64) string {
+	return "sms:breaker:" + s.name + ":bucket:" + strconv.FormatInt(index, 10)
+}
+
+// openKey 获取熔断打开标记的key
+func (s *redisBreakerStore) openKey() string {
+	return "sms:breaker:" + s.name + ":open"
+}
+
+// bucketIndex 计算时间所在的统计桶序号
+func bucketIndex(t time.Time, window time.Duration) int64 {
+	size := window / breakerBuckets
+	if size <= 0 {
+		size = 1
+	}
+	return t.UnixNano() / int64(size)
+}
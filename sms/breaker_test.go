@@ -0,0 +1,146 @@
+package sms
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubProvider 按预设错误返回结果的服务商
+type stubProvider struct {
+	err      error
+	calls    int
+	statuses []*StatusResponse // QueryStatusByPhone 返回的结果
+	queries  int               // QueryStatusByPhone 调用次数
+}
+
+func (p *stubProvider) Send(ctx context.Context, req *SendRequest) (*SendResponse, error) {
+	p.calls++
+	if p.err != nil {
+		return nil, p.err
+	}
+	return &SendResponse{MsgID: "stub", Success: true}, nil
+}
+
+func (p *stubProvider) QueryStatus(ctx context.Context, msgID string) (*StatusResponse, error) {
+	return &StatusResponse{MsgID: msgID, Status: StatusDelivered}, nil
+}
+
+func (p *stubProvider) QueryStatusByPhone(ctx context.Context, phone string) ([]*StatusResponse, error) {
+	p.queries++
+	return p.statuses, nil
+}
+
+func TestCircuitBreakerProvider(t *testing.T) {
+	ctx := context.Background()
+	stub := &stubProvider{err: NewSMSError("isv.OUT_OF_SERVICE", "停机", false, nil).WithType(ErrorTypeCircuitBreak)}
+
+	var transitions []BreakerState
+	breaker := NewCircuitBreakerProvider(stub, &CircuitBreakerConfig{
+		Name:         "test",
+		FailureRatio: 0.5,
+		MinRequests:  4,
+		Window:       time.Minute,
+		CoolDown:     50 * time.Millisecond,
+		OnStateChange: func(name string, from, to BreakerState) {
+			assert.Equal(t, "test", name)
+			transitions = append(transitions, to)
+		},
+	})
+
+	// 达到最少请求数后打开
+	for i := 0; i < 4; i++ {
+		_, err := breaker.Send(ctx, &SendRequest{Phone: "13800138000"})
+		require.Error(t, err)
+	}
+	assert.Equal(t, BreakerOpen, breaker.State())
+
+	// 打开状态直接拒绝，不调用服务商
+	_, err := breaker.Send(ctx, &SendRequest{Phone: "13800138000"})
+	var openErr *BreakerOpenError
+	require.True(t, errors.As(err, &openErr))
+	assert.True(t, errors.Is(err, ErrCircuitOpen))
+	assert.Equal(t, ErrorTypeCircuitBreak, ErrorTypeOf(err))
+	assert.Equal(t, 4, stub.calls)
+
+	// 冷却结束后探测成功，关闭熔断
+	time.Sleep(60 * time.Millisecond)
+	stub.err = nil
+	_, err = breaker.Send(ctx, &SendRequest{Phone: "13800138000"})
+	require.NoError(t, err)
+	assert.Equal(t, BreakerClosed, breaker.State())
+	assert.Equal(t, []BreakerState{BreakerOpen, BreakerHalfOpen, BreakerClosed}, transitions)
+}
+
+func TestCircuitBreakerIgnoresRequestErrors(t *testing.T) {
+	errs := []error{
+		NewSMSError("isv.MOBILE_NUMBER_ILLEGAL", "手机号非法", false, nil).WithType(ErrorTypeInvalidPhone),
+		// 单个号码触发服务商频率限制
+		NewSMSError("isv.BUSINESS_LIMIT_CONTROL", "业务限流", false, nil).WithType(ErrorTypeRateLimit),
+	}
+	for _, err := range errs {
+		stub := &stubProvider{err: err}
+		breaker := NewCircuitBreakerProvider(stub, &CircuitBreakerConfig{MinRequests: 2})
+
+		for i := 0; i < 5; i++ {
+			_, _ = breaker.Send(context.Background(), &SendRequest{Phone: "1"})
+		}
+		assert.Equal(t, BreakerClosed, breaker.State(), err.Error())
+		assert.Equal(t, 5, stub.calls, err.Error())
+	}
+}
+
+// emptyProvider 不返回错误也不返回响应的服务商
+type emptyProvider struct {
+	stubProvider
+}
+
+func (p *emptyProvider) Send(ctx context.Context, req *SendRequest) (*SendResponse, error) {
+	p.calls++
+	return nil, nil
+}
+
+func TestCircuitBreakerEmptyResponse(t *testing.T) {
+	stub := &emptyProvider{}
+	breaker := NewCircuitBreakerProvider(stub, &CircuitBreakerConfig{MinRequests: 2})
+
+	for i := 0; i < 2; i++ {
+		_, _ = breaker.Send(context.Background(), &SendRequest{Phone: "13800138000"})
+	}
+	assert.Equal(t, BreakerOpen, breaker.State())
+	assert.Equal(t, 2, stub.calls)
+}
+
+func TestRedisBreakerStore(t *testing.T) {
+	ctx := context.Background()
+	rdb, _ := newTestRedis(t)
+	store := &redisBreakerStore{redis: rdb, name: "aliyun", window: time.Minute}
+	other := &redisBreakerStore{redis: rdb, name: "aliyun", window: time.Minute}
+
+	// 多个实例共享统计
+	_, _, err := store.record(ctx, false)
+	require.NoError(t, err)
+	total, failures, err := other.record(ctx, true)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	assert.Equal(t, int64(1), failures)
+
+	require.NoError(t, store.trip(ctx, time.Minute))
+	remaining, err := other.openRemaining(ctx)
+	require.NoError(t, err)
+	assert.Greater(t, remaining, 50*time.Second)
+
+	// 重置后统计和打开状态都被清除
+	require.NoError(t, other.reset(ctx))
+	remaining, err = store.openRemaining(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, remaining)
+	total, failures, err = store.record(ctx, false)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	assert.Zero(t, failures)
+}
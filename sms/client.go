@@ -2,25 +2,56 @@ package sms
 
 import (
 	"context"
+	"errors"
+	"time"
 
+	"github.com/gpencil/go-common/phone"
 	"github.com/redis/go-redis/v9"
 )
 
 // Client 短信客户端
 // 集成了限流、配额、重试等功能
 type Client struct {
-	provider     SMSProvider   // 短信服务商
-	limiter      *RateLimiter  // 限流器
-	quotaManager *QuotaManager // 配额管理
+	provider     SMSProvider       // 短信服务商
+	limiter      *RateLimiter      // 限流器
+	quotaManager *QuotaManager     // 配额管理
+	codes        *CodeManager      // 验证码管理
+	idempotency  *idempotencyStore // 发送幂等记录
+	suppression  *SuppressionList  // 免打扰名单
+	store        MessageStore      // 发送记录存储（可选）
+	queue        *sendQueue        // 异步发送队列
+	schedules    *scheduleStore    // 定时短信
+	templates    *TemplateRegistry // 模板注册表（可选）
+	prices       *PriceTable       // 短信单价表（可选）
+	batcher      BatchProvider     // 批量发送（服务商支持时）
+	batchWorkers int               // 批量发送并发数
 }
 
 // ClientConfig 客户端配置
 type ClientConfig struct {
-	Redis         *redis.Client  // Redis 客户端（必须）
-	Provider      SMSProvider    // 短信服务商（必须）
-	LimiterConfig *LimiterConfig // 限流配置（可选，使用默认值）
-	RetryConfig   *RetryConfig   // 重试配置（可选，使用默认值）
-	EnableRetry   bool           // 是否启用重试（默认 false）
+	Redis          *redis.Client     // Redis 客户端（必须）
+	Provider       SMSProvider       // 短信服务商（必须）
+	LimiterConfig  *LimiterConfig    // 限流配置（可选，使用默认值）
+	RetryConfig    *RetryConfig      // 重试配置（可选，使用默认值）
+	EnableRetry    bool              // 是否启用重试（默认 false）
+	CodeConfig     *CodeConfig       // 验证码配置（可选，使用默认值）
+	IdempotencyTTL time.Duration     // 幂等记录保存时间（可选，默认 24 小时）
+	SendTimeout    time.Duration     // 设置幂等键时单次 Send 的总超时（含重试，可选，默认 1 分钟），处理中记录按此时间过期
+	MessageStore   MessageStore      // 发送记录存储（可选，配置后记录每一次发送尝试）
+	QueueConfig    *QueueConfig      // 异步发送队列配置（可选，使用默认值）
+	Templates      *TemplateRegistry // 模板注册表（可选，配置后发送前校验模板参数）
+	PriceTable     *PriceTable       // 短信单价表（可选，配合 Templates 在 SendResponse 中填写预估费用）
+
+	// BatchConcurrency 批量发送的并发数（可选，默认 10）
+	BatchConcurrency int
+
+	// SuppressionExempt 豁免全局免打扰名单的业务（可选，如 login、register 等验证码业务）
+	// 用户全局退订后仍然可以收到这些业务的短信，业务自己的免打扰名单仍然生效
+	SuppressionExempt []string
+
+	// BizLimiterConfigs 业务专属限流配置（可选）：bizID -> 限流配置
+	// 未配置的业务使用 LimiterConfig；限流计数按业务隔离，一个业务超限不影响其他业务
+	BizLimiterConfigs map[string]*LimiterConfig
 }
 
 // NewClient 创建短信客户端
@@ -34,44 +65,241 @@ func NewClient(config *ClientConfig) *Client {
 
 	// 创建限流器
 	limiter := NewRateLimiter(config.Redis, config.LimiterConfig)
+	for bizID, bizConfig := range config.BizLimiterConfigs {
+		limiter.SetBizConfig(bizID, bizConfig)
+	}
 
 	// 创建配额管理器
 	quotaManager := NewQuotaManager(config.Redis)
 
+	// 创建免打扰名单
+	suppression := NewSuppressionList(config.Redis)
+	suppression.Exempt(config.SuppressionExempt...)
+
 	// 如果启用重试，包装 provider
 	provider := config.Provider
 	if config.EnableRetry {
 		provider = NewRetryProvider(provider, config.RetryConfig)
 	}
 
+	// 服务商支持批量发送时使用原始服务商（批量请求不经过重试装饰器）
+	batcher, _ := config.Provider.(BatchProvider)
+	batchWorkers := config.BatchConcurrency
+	if batchWorkers <= 0 {
+		batchWorkers = 10
+	}
+
 	return &Client{
 		provider:     provider,
 		limiter:      limiter,
 		quotaManager: quotaManager,
+		codes:        NewCodeManager(config.Redis, config.CodeConfig),
+		idempotency:  newIdempotencyStore(config.Redis, config.IdempotencyTTL, config.SendTimeout),
+		suppression:  suppression,
+		store:        config.MessageStore,
+		queue:        newSendQueue(config.Redis, config.QueueConfig),
+		schedules:    newScheduleStore(config.Redis),
+		templates:    config.Templates,
+		prices:       config.PriceTable,
+		batcher:      batcher,
+		batchWorkers: batchWorkers,
 	}
 }
 
 // Send 发送短信 会自动进行限流和配额检查
+// 设置了 IdempotencyKey 时，相同幂等键在保存时间内只会发送一次，重复调用返回首次成功的结果：
+// 发送超时、网络错误等无法确定是否已发送的错误会保留记录，重复调用返回 ErrIdempotencyUnknown；
+// 幂等键已用于手机号、模板或参数不同的请求时返回 ErrIdempotencyMismatch
 func (c *Client) Send(ctx context.Context, req *SendRequest) (*SendResponse, error) {
-	// 1. 限流检查
-	if err := c.limiter.CheckAndIncrement(ctx, req); err != nil {
+	if req.IdempotencyKey == "" {
+		resp, _, err := c.send(ctx, req)
+		return resp, err
+	}
+
+	pending, resp, err := c.idempotency.acquire(ctx, req.IdempotencyKey, idempotencyDigest(req))
+	if err != nil {
 		return nil, err
 	}
+	if resp != nil {
+		return resp, nil
+	}
+
+	// 发送总时长不超过处理中记录的有效期，避免记录过期后重复请求再次发送
+	sendCtx, cancel := context.WithTimeout(ctx, c.idempotency.sendTimeout)
+	defer cancel()
+	resp, sent, err := c.send(sendCtx, req)
+
+	// 调用方的 ctx 可能已超时，记录结果不受其影响
+	background := context.WithoutCancel(ctx)
+	switch {
+	case resp != nil && resp.Success:
+		_ = c.idempotency.complete(background, req.IdempotencyKey, pending, resp)
+	case sent && isAmbiguousSendError(err):
+		_ = c.idempotency.markUnknown(background, req.IdempotencyKey, pending)
+	default:
+		_ = c.idempotency.release(background, req.IdempotencyKey, pending)
+	}
+	return resp, err
+}
+
+// Enqueue 异步发送短信：写入发送队列后立即返回任务ID，由 QueueWorker 执行限流、配额检查和发送
+// 通过 Job 查询发送结果
+func (c *Client) Enqueue(ctx context.Context, req *SendRequest) (string, error) {
+	return c.queue.enqueue(ctx, req)
+}
+
+// Job 查询异步发送任务，任务不存在或结果已过期时返回 ErrJobNotFound
+func (c *Client) Job(ctx context.Context, id string) (*Job, error) {
+	return c.queue.job(ctx, id)
+}
+
+// Schedule 定时发送短信：到达 at 后由 Scheduler 通过 Send 发送，返回定时ID
+// at 早于当前时间时在下一次轮询时发送
+func (c *Client) Schedule(ctx context.Context, req *SendRequest, at time.Time) (string, error) {
+	return c.schedules.add(ctx, req, at)
+}
+
+// CancelSchedule 取消定时短信，已发送或已取消时返回 ErrScheduleNotFound
+// 已被 Scheduler 领取、正在发送的短信无法取消，返回 ErrScheduleInFlight
+func (c *Client) CancelSchedule(ctx context.Context, id string) error {
+	return c.schedules.cancel(ctx, id)
+}
+
+// ListSchedules 按计划发送时间列出待发送的定时短信，limit 默认 100
+func (c *Client) ListSchedules(ctx context.Context, offset, limit int) ([]*ScheduledMessage, error) {
+	return c.schedules.list(ctx, offset, limit)
+}
+
+// send 发送短信并记录发送结果，sent 表示是否已调用服务商
+func (c *Client) send(ctx context.Context, req *SendRequest) (*SendResponse, bool, error) {
+	resp, sent, err := c.deliver(ctx, req)
+	c.record(ctx, req, resp, err)
+	return resp, sent, err
+}
+
+// record 保存发送记录（未配置 MessageStore 时忽略，记录失败不影响发送结果）
+func (c *Client) record(ctx context.Context, req *SendRequest, resp *SendResponse, err error) {
+	if c.store == nil {
+		return
+	}
+	record := newMessageRecord(req, resp, err, c.codes.config.ParamName)
+	_ = c.store.Save(context.WithoutCancel(ctx), record)
+}
+
+// deliver 发送短信（模板校验、免打扰、限流、配额、验证码），sent 表示是否已调用服务商
+func (c *Client) deliver(ctx context.Context, req *SendRequest) (*SendResponse, bool, error) {
+	// 1. 准备验证码、校验模板参数
+	cloned, code, err := c.prepare(req)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// 2. 免打扰、限流、配额检查
+	if err := c.admit(ctx, req); err != nil {
+		return nil, false, err
+	}
 
-	// 2. 配额检查
+	// 3. 发送短信
+	resp, err := c.provider.Send(ctx, cloned)
+	c.estimate(cloned, resp)
+	if err != nil || resp == nil || !resp.Success || code == "" {
+		return resp, true, err
+	}
+
+	// 4. 发送成功后保存验证码
+	if err := c.codes.Save(ctx, req.BizID, req.GetFullPhone(), code); err != nil {
+		return resp, true, err
+	}
+	return resp, true, nil
+}
+
+// prepare 准备验证码并校验模板参数（复制请求，注入的验证码不影响调用方）
+// 在消耗限流次数和配额之前执行，参数错误不会占用发送次数
+func (c *Client) prepare(req *SendRequest) (*SendRequest, string, error) {
+	cloned := *req
+	number, err := phone.Parse(req.Phone, req.CountryCode)
+	if err != nil {
+		return nil, "", NewSMSError("INVALID_PHONE_NUMBER", "手机号格式错误", false, ErrInvalidParams).WithType(ErrorTypeInvalidPhone)
+	}
+	cloned.Phone = number.National
+	cloned.CountryCode = "+" + number.CallingCode
+	if number.CallingCode == "" {
+		// 未注册的国家代码，无法拆分国家代码和国内号码
+		cloned.Phone, cloned.CountryCode = number.E164(), ""
+	}
+
+	code, err := c.codes.Prepare(&cloned)
+	if err != nil {
+		return nil, "", err
+	}
+	if c.templates != nil {
+		if err := c.templates.Validate(&cloned); err != nil {
+			return nil, "", err
+		}
+	}
+	return &cloned, code, nil
+}
+
+// estimate 填写计费条数和预估费用（需要注册模板），req 为 prepare 标准化后的请求
+func (c *Client) estimate(req *SendRequest, resp *SendResponse) {
+	if resp == nil || c.templates == nil {
+		return
+	}
+	info, ok := c.templates.Segments(req)
+	if !ok {
+		return
+	}
+	resp.Segments = info.Segments
+	if c.prices == nil {
+		return
+	}
+	if cost, ok := c.prices.Estimate(resp.Provider, req.CountryCode, info.Segments); ok {
+		resp.EstimatedCost = cost
+	}
+}
+
+// admit 发送前检查（免打扰、限流、配额）
+func (c *Client) admit(ctx context.Context, req *SendRequest) error {
+	// 1. 免打扰检查（用户已退订）
+	suppressed, err := c.suppression.IsSuppressed(ctx, req.GetFullPhone(), req.BizID)
+	if err != nil {
+		return err
+	}
+	if suppressed {
+		return ErrPhoneSuppressed
+	}
+
+	// 2. 限流检查
+	if err := c.limiter.CheckAndIncrement(ctx, req); err != nil {
+		return err
+	}
+
+	// 3. 配额检查
 	if req.BizID != "" {
 		if err := c.quotaManager.CheckAndIncrement(ctx, req.BizID); err != nil {
-			return nil, err
+			return err
 		}
 	}
-
-	// 3. 发送短信
-	return c.provider.Send(ctx, req)
+	return nil
 }
 
 // Verify 验证短信验证码
 func (c *Client) Verify(ctx context.Context, req *VerifyRequest) (*VerifyResponse, error) {
-	return c.provider.Verify(ctx, req)
+	return c.codes.Verify(ctx, req)
+}
+
+// ConsumeTicket 校验并作废 Verify 签发的一次性验证凭证
+// 凭证必须与业务、手机号匹配且未过期、未使用，否则返回 ErrTicketInvalid、ErrTicketExpired 或 ErrTicketUsed
+func (c *Client) ConsumeTicket(ctx context.Context, ticket, bizID, phone string) error {
+	return c.codes.ConsumeTicket(ctx, ticket, bizID, phone)
+}
+
+// QueryMessages 查询发送记录（需要配置 MessageStore）
+func (c *Client) QueryMessages(ctx context.Context, query *MessageQuery) ([]*MessageRecord, error) {
+	if c.store == nil {
+		return nil, errors.New("未配置 MessageStore")
+	}
+	return c.store.Query(ctx, query)
 }
 
 // QueryStatus 查询短信发送状态
@@ -99,7 +327,29 @@ func (c *Client) ResetQuota(ctx context.Context, bizID string) error {
 	return c.quotaManager.ResetQuota(ctx, bizID)
 }
 
+// Cooldown 获取手机号在业务下距离下一次允许发送的剩余时间（用于展示"xx秒后重新发送"）
+// 可以立即发送时返回 0，不会增加发送计数；需要同时检查设备、IP、用户账号维度时使用 CooldownFor
+func (c *Client) Cooldown(ctx context.Context, phone, bizID string) (time.Duration, error) {
+	return c.CooldownFor(ctx, &SendRequest{Phone: phone, BizID: bizID})
+}
+
+// CooldownFor 获取请求距离下一次允许发送的剩余时间
+// 与 Send 使用相同的限流维度（手机号、设备、IP、用户账号）和国家代码，可以立即发送时返回 0，不会增加发送计数
+func (c *Client) CooldownFor(ctx context.Context, req *SendRequest) (time.Duration, error) {
+	return c.limiter.Cooldown(ctx, req)
+}
+
 // GetPhoneCount 获取手机号发送次数
 func (c *Client) GetPhoneCount(ctx context.Context, phone string, _type string) (int, error) {
 	return c.limiter.GetPhoneCount(ctx, phone, _type)
 }
+
+// GetBizPhoneCount 获取手机号在指定业务下的发送次数
+func (c *Client) GetBizPhoneCount(ctx context.Context, bizID, phone string, _type string) (int, error) {
+	return c.limiter.GetBizPhoneCount(ctx, bizID, phone, _type)
+}
+
+// SetLimiterConfig 设置业务专属限流配置，config 为 nil 时恢复使用默认配置
+func (c *Client) SetLimiterConfig(bizID string, config *LimiterConfig) {
+	c.limiter.SetBizConfig(bizID, config)
+}
@@ -0,0 +1,268 @@
+package sms
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// verifyCodeScript 原子地校验验证码并统计错误次数，避免并发验证绕过次数限制或同一验证码被验证多次
+// KEYS[1]：验证码 key；KEYS[2]：错误次数 key；KEYS[3]：锁定 key；KEYS[4]：验证凭证 key（可选，验证成功时写入）
+// ARGV[1]：待验证的哈希；ARGV[2]：最大错误次数（<=0 不限制）；ARGV[3]：锁定时长（毫秒）；
+// ARGV[4]：验证凭证的值；ARGV[5]：验证凭证有效期（毫秒）
+// 返回 {结果, 值}：0 成功；1 不存在或已过期；2 不匹配（值为剩余次数）；
+// 3 已锁定（值为剩余锁定毫秒数）；4 错误次数达到上限（值为锁定毫秒数）
+var verifyCodeScript = redis.NewScript(`
+local lockTTL = redis.call('PTTL', KEYS[3])
+if lockTTL > 0 then
+	return {3, lockTTL}
+end
+
+local stored = redis.call('GET', KEYS[1])
+if not stored then
+	return {1, 0}
+end
+if stored == ARGV[1] then
+	redis.call('DEL', KEYS[1], KEYS[2])
+	if KEYS[4] then
+		redis.call('SET', KEYS[4], ARGV[4], 'PX', ARGV[5])
+	end
+	return {0, 0}
+end
+
+local attempts = redis.call('INCR', KEYS[2])
+local codeTTL = redis.call('PTTL', KEYS[1])
+if codeTTL > 0 then
+	redis.call('PEXPIRE', KEYS[2], codeTTL)
+end
+
+local max = tonumber(ARGV[2])
+if max > 0 and attempts >= max then
+	redis.call('DEL', KEYS[1], KEYS[2])
+	local lockMs = tonumber(ARGV[3])
+	if lockMs > 0 then
+		redis.call('SET', KEYS[3], '1', 'PX', lockMs)
+	end
+	return {4, lockMs}
+end
+if max > 0 then
+	return {2, max - attempts}
+end
+return {2, -1}
+`)
+
+// CodeConfig 验证码配置
+type CodeConfig struct {
+	Length    int           // 验证码长度，默认 6
+	Alphabet  string        // 验证码字符集，默认 0-9
+	Expiry    time.Duration // 有效期，默认 5 分钟
+	ParamName string        // 注入模板参数的名称，默认 code
+	Secret    string        // 哈希密钥（可选，设置后使用 HMAC-SHA256，Redis 数据泄露时无法离线穷举）
+
+	MaxAttempts  int           // 每个验证码最多验证错误次数，达到后验证码失效并锁定，默认 5，小于 0 表示不限制
+	LockDuration time.Duration // 锁定时长，锁定期间该手机号在该业务下无法验证，默认 15 分钟
+	TicketTTL    time.Duration // 验证凭证有效期，默认 10 分钟
+}
+
+// DefaultCodeConfig 默认验证码配置
+func DefaultCodeConfig() *CodeConfig {
+	return &CodeConfig{
+		Length:       6,
+		Alphabet:     "0123456789",
+		Expiry:       5 * time.Minute,
+		ParamName:    "code",
+		MaxAttempts:  5,
+		LockDuration: 15 * time.Minute,
+		TicketTTL:    10 * time.Minute,
+	}
+}
+
+// CodeManager 验证码管理器
+// 负责验证码的生成、存储和校验，Redis 中只保存验证码的哈希；服务商只负责投递
+type CodeManager struct {
+	redis  *redis.Client
+	config *CodeConfig
+}
+
+// NewCodeManager 创建验证码管理器
+func NewCodeManager(redis *redis.Client, config *CodeConfig) *CodeManager {
+	if config == nil {
+		config = DefaultCodeConfig()
+	}
+	// 复制配置，填充默认值不影响调用方
+	cloned := *config
+	config = &cloned
+	defaults := DefaultCodeConfig()
+	if config.Length <= 0 {
+		config.Length = defaults.Length
+	}
+	if config.Alphabet == "" {
+		config.Alphabet = defaults.Alphabet
+	}
+	if config.Expiry <= 0 {
+		config.Expiry = defaults.Expiry
+	}
+	if config.ParamName == "" {
+		config.ParamName = defaults.ParamName
+	}
+	if config.MaxAttempts == 0 {
+		config.MaxAttempts = defaults.MaxAttempts
+	}
+	if config.LockDuration <= 0 {
+		config.LockDuration = defaults.LockDuration
+	}
+	if config.TicketTTL <= 0 {
+		config.TicketTTL = defaults.TicketTTL
+	}
+
+	return &CodeManager{
+		redis:  redis,
+		config: config,
+	}
+}
+
+// Generate 生成随机验证码
+func (m *CodeManager) Generate() (string, error) {
+	alphabet := []rune(m.config.Alphabet)
+	max := big.NewInt(int64(len(alphabet)))
+
+	code := make([]rune, m.config.Length)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = alphabet[n.Int64()]
+	}
+	return string(code), nil
+}
+
+// Prepare 为发送请求准备验证码，返回需要在发送成功后保存的验证码
+// 请求设置了 GenerateCode 时生成新验证码并注入模板参数；
+// 否则使用模板参数中已有的验证码；两者都没有时返回空字符串
+// 注入参数时会复制 Params，不修改调用方传入的 map
+func (m *CodeManager) Prepare(req *SendRequest) (string, error) {
+	if !req.GenerateCode {
+		return req.Params[m.config.ParamName], nil
+	}
+
+	code, err := m.Generate()
+	if err != nil {
+		return "", err
+	}
+
+	params := make(map[string]string, len(req.Params)+1)
+	for k, v := range req.Params {
+		params[k] = v
+	}
+	params[m.config.ParamName] = code
+	req.Params = params
+
+	return code, nil
+}
+
+// Save 保存验证码（只保存哈希），会覆盖该手机号在该业务下未使用的验证码
+func (m *CodeManager) Save(ctx context.Context, bizID, phone, code string) error {
+	if code == "" {
+		return errors.New("验证码不能为空")
+	}
+
+	phone = normalizePhone(phone, "")
+
+	// 新验证码重新计算错误次数
+	pipe := m.redis.TxPipeline()
+	pipe.Set(ctx, getCodeKey(bizID, phone), m.hash(bizID, phone, code), m.config.Expiry)
+	pipe.Del(ctx, getCodeAttemptsKey(bizID, phone))
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Verify 验证短信验证码，验证成功后验证码失效
+// 错误次数达到 MaxAttempts 时验证码失效，并锁定该手机号在该业务下的验证 LockDuration
+func (m *CodeManager) Verify(ctx context.Context, req *VerifyRequest) (*VerifyResponse, error) {
+	phone := req.GetFullPhone()
+	keys := []string{
+		getCodeKey(req.BizID, phone),
+		getCodeAttemptsKey(req.BizID, phone),
+		getCodeLockKey(req.BizID, phone),
+	}
+	args := []interface{}{m.hash(req.BizID, phone, req.Code), m.config.MaxAttempts, m.config.LockDuration.Milliseconds()}
+
+	// 凭证在验证前生成，验证成功时由脚本一起写入，避免验证码被消耗后凭证写入失败
+	var ticket string
+	if req.IssueTicket {
+		var claims *ticketClaims
+		var err error
+		if ticket, claims, err = m.newTicket(req.BizID, phone); err != nil {
+			return nil, err
+		}
+		keys = append(keys, getTicketKey(claims.Nonce))
+		args = append(args, claims.value(), m.config.TicketTTL.Milliseconds())
+	}
+
+	result, err := verifyCodeScript.Run(ctx, m.redis, keys, args...).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+	if len(result) != 2 {
+		return nil, errors.New("验证码脚本返回格式错误")
+	}
+
+	value := result[1]
+	switch result[0] {
+	case 0:
+		return &VerifyResponse{Success: true, Ticket: ticket}, nil
+	case 1:
+		return &VerifyResponse{
+			Reason: VerifyReasonExpired,
+			ErrMsg: "验证码不存在或已过期",
+		}, nil
+	case 2:
+		return &VerifyResponse{
+			Reason:            VerifyReasonMismatch,
+			RemainingAttempts: int(value),
+			ErrMsg:            "验证码错误",
+		}, nil
+	case 3:
+		return &VerifyResponse{
+			Reason:     VerifyReasonLocked,
+			RetryAfter: time.Duration(value) * time.Millisecond,
+			ErrMsg:     "验证错误次数过多，请稍后再试",
+		}, nil
+	default:
+		return &VerifyResponse{
+			Reason:     VerifyReasonTooManyAttempts,
+			RetryAfter: time.Duration(value) * time.Millisecond,
+			ErrMsg:     "验证错误次数过多，验证码已失效",
+		}, nil
+	}
+}
+
+// hash 计算验证码哈希（绑定业务和手机号，同一验证码不能用于其他业务或手机号）
+func (m *CodeManager) hash(bizID, phone, code string) string {
+	data := bizID + ":" + phone + ":" + code
+	if m.config.Secret == "" {
+		return sha256Hex([]byte(data))
+	}
+	return hex.EncodeToString(hmacSHA256([]byte(m.config.Secret), data))
+}
+
+// getCodeKey 获取验证码存储的key
+func getCodeKey(bizID, phone string) string {
+	return "sms:code:" + bizID + ":" + phone
+}
+
+// getCodeAttemptsKey 获取验证码错误次数的key
+func getCodeAttemptsKey(bizID, phone string) string {
+	return "sms:verify:attempts:" + bizID + ":" + phone
+}
+
+// getCodeLockKey 获取验证锁定的key
+func getCodeLockKey(bizID, phone string) string {
+	return "sms:verify:lock:" + bizID + ":" + phone
+}
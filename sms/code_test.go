@@ -0,0 +1,114 @@
+package sms
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodeManager(t *testing.T) {
+	config := &CodeConfig{Length: 8, Alphabet: "ABC"}
+	codes := NewCodeManager(newOfflineRedis(), config)
+	assert.Equal(t, 5, codes.config.MaxAttempts)
+	assert.Equal(t, 15*time.Minute, codes.config.LockDuration)
+	assert.Equal(t, CodeConfig{Length: 8, Alphabet: "ABC"}, *config)
+	unlimited := NewCodeManager(newOfflineRedis(), &CodeConfig{MaxAttempts: -1})
+	assert.Equal(t, -1, unlimited.config.MaxAttempts)
+
+	code, err := codes.Generate()
+	require.NoError(t, err)
+	assert.Len(t, code, 8)
+	assert.Empty(t, strings.Trim(code, "ABC"))
+
+	// 生成验证码时复制 Params，不修改调用方的 map
+	params := map[string]string{"minutes": "5"}
+	req := &SendRequest{Params: params, GenerateCode: true}
+	code, err = codes.Prepare(req)
+	require.NoError(t, err)
+	assert.Equal(t, code, req.Params["code"])
+	assert.Equal(t, "5", req.Params["minutes"])
+	assert.NotContains(t, params, "code")
+
+	// 未要求生成时使用参数中已有的验证码
+	code, err = codes.Prepare(&SendRequest{Params: map[string]string{"code": "123456"}})
+	require.NoError(t, err)
+	assert.Equal(t, "123456", code)
+
+	// 哈希绑定业务和手机号，设置密钥后使用 HMAC
+	assert.NotEqual(t, codes.hash("login", "13800138000", "1234"), codes.hash("pay", "13800138000", "1234"))
+	secret := NewCodeManager(newOfflineRedis(), &CodeConfig{Secret: "s3cret"})
+	assert.NotEqual(t, codes.hash("login", "13800138000", "1234"), secret.hash("login", "13800138000", "1234"))
+	assert.NotContains(t, secret.hash("login", "13800138000", "1234"), "1234")
+}
+
+func TestCodeManagerVerify(t *testing.T) {
+	ctx := context.Background()
+	rdb, server := newTestRedis(t)
+	codes := NewCodeManager(rdb, &CodeConfig{MaxAttempts: 3, LockDuration: time.Minute})
+
+	require.NoError(t, codes.Save(ctx, "login", "13800138000", "123456"))
+	stored, err := server.Get(getCodeKey("login", "+8613800138000"))
+	require.NoError(t, err)
+	assert.NotContains(t, stored, "123456")
+
+	// 错误次数未达到上限时返回剩余次数
+	resp, err := codes.Verify(ctx, &VerifyRequest{Phone: "13800138000", Code: "000000", BizID: "login"})
+	require.NoError(t, err)
+	assert.Equal(t, VerifyReasonMismatch, resp.Reason)
+	assert.Equal(t, 2, resp.RemainingAttempts)
+
+	resp, err = codes.Verify(ctx, &VerifyRequest{Phone: "13800138000", Code: "111111", BizID: "login"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, resp.RemainingAttempts)
+
+	// 达到上限后验证码失效并锁定，正确的验证码也无法通过
+	resp, err = codes.Verify(ctx, &VerifyRequest{Phone: "13800138000", Code: "222222", BizID: "login"})
+	require.NoError(t, err)
+	assert.Equal(t, VerifyReasonTooManyAttempts, resp.Reason)
+	assert.Equal(t, time.Minute, resp.RetryAfter)
+
+	require.NoError(t, codes.Save(ctx, "login", "13800138000", "123456"))
+	resp, err = codes.Verify(ctx, &VerifyRequest{Phone: "13800138000", Code: "123456", BizID: "login"})
+	require.NoError(t, err)
+	assert.Equal(t, VerifyReasonLocked, resp.Reason)
+	assert.Greater(t, resp.RetryAfter, time.Duration(0))
+
+	// 锁定只影响该业务
+	require.NoError(t, codes.Save(ctx, "pay", "13800138000", "654321"))
+	resp, err = codes.Verify(ctx, &VerifyRequest{Phone: "13800138000", Code: "654321", BizID: "pay"})
+	require.NoError(t, err)
+	assert.True(t, resp.Success)
+
+	// 锁定过期后可以验证，格式不同的同一手机号视为同一号码；验证成功后验证码失效
+	server.FastForward(time.Minute)
+	req := &VerifyRequest{Phone: "+86 138-0013-8000", Code: "123456", BizID: "login"}
+	resp, err = codes.Verify(ctx, req)
+	require.NoError(t, err)
+	assert.True(t, resp.Success)
+
+	resp, err = codes.Verify(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, VerifyReasonExpired, resp.Reason)
+}
+
+func TestCodeManagerExpiry(t *testing.T) {
+	ctx := context.Background()
+	rdb, server := newTestRedis(t)
+	codes := NewCodeManager(rdb, &CodeConfig{Expiry: time.Minute})
+
+	require.NoError(t, codes.Save(ctx, "login", "13800138000", "123456"))
+	resp, err := codes.Verify(ctx, &VerifyRequest{Phone: "13800138000", Code: "000000", BizID: "login"})
+	require.NoError(t, err)
+	assert.Equal(t, 4, resp.RemainingAttempts)
+
+	// 错误次数随验证码过期，新验证码重新计算错误次数
+	server.FastForward(time.Minute)
+	resp, err = codes.Verify(ctx, &VerifyRequest{Phone: "13800138000", Code: "123456", BizID: "login"})
+	require.NoError(t, err)
+	assert.Equal(t, VerifyReasonExpired, resp.Reason)
+	assert.False(t, server.Exists(getCodeAttemptsKey("login", "+8613800138000")))
+}
@@ -1,12 +1,18 @@
 package sms
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
 
 var (
 	// 限流相关错误
 	ErrPhoneRateLimit  = errors.New("手机号发送频率超限")
 	ErrDeviceRateLimit = errors.New("设备发送频率超限")
 	ErrIPRateLimit     = errors.New("IP发送频率超限")
+	ErrUserRateLimit   = errors.New("用户发送频率超限")
 
 	// 配额相关错误
 	ErrQuotaExceeded = errors.New("业务配额已用尽")
@@ -18,13 +24,51 @@ var (
 	ErrProviderFailed = errors.New("短信服务商调用失败")
 	ErrTimeout        = errors.New("请求超时")
 	ErrNetworkError   = errors.New("网络错误")
+	ErrCircuitOpen    = errors.New("熔断器已打开")
+
+	// 幂等相关错误
+	ErrIdempotencyInProgress = errors.New("相同幂等键的请求正在处理中")
+	ErrIdempotencyUnknown    = errors.New("相同幂等键的请求发送结果未知")
+	ErrIdempotencyMismatch   = errors.New("幂等键已用于内容不同的请求")
 
 	// 业务错误
 	ErrCodeExpired      = errors.New("验证码已过期")
 	ErrCodeNotMatch     = errors.New("验证码不匹配")
 	ErrBalanceNotEnough = errors.New("余额不足")
+	ErrPhoneSuppressed  = errors.New("手机号已退订")
+
+	// 验证凭证错误
+	ErrTicketInvalid = errors.New("验证凭证无效")
+	ErrTicketExpired = errors.New("验证凭证已过期")
+	ErrTicketUsed    = errors.New("验证凭证已使用")
+
+	// 推送接收错误
+	ErrCallbackUnverified = errors.New("推送未通过校验")
 )
 
+// RateLimitError 限流错误，包含超限的维度和剩余等待时间
+type RateLimitError struct {
+	Dimension  LimitDimension // 超限维度
+	Period     string         // 限流周期（minute/hour/day）
+	Limit      int            // 限制次数
+	Count      int            // 当前次数
+	RetryAfter time.Duration  // 距离重置（可以再次发送）的剩余时间
+}
+
+func (e *RateLimitError) Error() string {
+	message := "发送频率超限"
+	if err := e.Unwrap(); err != nil {
+		message = err.Error()
+	}
+	return fmt.Sprintf("%s（%s/%s: %d/%d），%d秒后重试",
+		message, e.Dimension, e.Period, e.Count, e.Limit, int(math.Ceil(e.RetryAfter.Seconds())))
+}
+
+// Unwrap 返回维度对应的错误，支持 errors.Is(err, ErrPhoneRateLimit) 等判断
+func (e *RateLimitError) Unwrap() error {
+	return dimensionErrors[e.Dimension]
+}
+
 // NewSMSError 创建短信错误
 func NewSMSError(code, message string, retryable bool, err error) *SMSError {
 	return &SMSError{
@@ -56,10 +100,16 @@ func IsRetryableError(err error) bool {
 		return false
 	case errors.Is(err, ErrIPRateLimit):
 		return false
+	case errors.Is(err, ErrUserRateLimit):
+		return false
 	case errors.Is(err, ErrBalanceNotEnough):
 		return false
 	case errors.Is(err, ErrInvalidParams):
 		return false
+	case errors.Is(err, ErrPhoneSuppressed):
+		return false
+	case errors.Is(err, ErrIdempotencyUnknown), errors.Is(err, ErrIdempotencyMismatch):
+		return false
 	default:
 		// 其他错误默认可重试
 		return true
@@ -86,6 +136,27 @@ func GetErrorType(code string) ErrorType {
 	}
 }
 
+// ErrorTypeOf 获取错误对应的错误类型
+// 优先使用 SMSError.Type，其次按错误码推断；非 SMSError 的错误视为其他错误
+func ErrorTypeOf(err error) ErrorType {
+	if err == nil {
+		return ""
+	}
+
+	var smsErr *SMSError
+	if errors.As(err, &smsErr) {
+		if smsErr.Type != "" {
+			return smsErr.Type
+		}
+		return GetErrorType(smsErr.Code)
+	}
+
+	if errors.Is(err, ErrTimeout) {
+		return ErrorTypeTimeout
+	}
+	return ErrorTypeOther
+}
+
 // ShouldRetry 根据错误类型判断是否应该重试
 func ShouldRetry(errType ErrorType) bool {
 	switch errType {
@@ -29,7 +29,6 @@ func main() {
 		AccessKeySecret: "your-access-key-secret",
 		SignName:        "你的签名",                  // 在阿里云短信控制台配置的签名
 		Endpoint:        "dysmsapi.aliyuncs.com", // 可选，默认值
-		CodeExpiry:      0,                       // 可选，0 表示使用默认 5 分钟
 	})
 	if err != nil {
 		log.Fatalf("创建阿里云短信客户端失败: %v", err)
@@ -93,7 +92,7 @@ func main() {
 	if verifyResp.Success {
 		fmt.Println("验证码验证成功！")
 	} else {
-		fmt.Printf("验证码验证失败: %s\n", verifyResp.ErrMsg)
+		fmt.Printf("验证码验证失败: %s\n", verifyResp.Reason)
 	}
 
 	// 7. 查询短信状态（通过手机号）
@@ -83,7 +83,7 @@ func main() {
 	if verifyResp.Success {
 		fmt.Println("验证码验证成功！")
 	} else {
-		fmt.Printf("验证码验证失败: %s\n", verifyResp.ErrMsg)
+		fmt.Printf("验证码验证失败: %s\n", verifyResp.Reason)
 	}
 
 	// 7. 查询短信状态
@@ -15,14 +15,12 @@ import (
 type CustomProvider struct {
 	apiKey    string
 	apiSecret string
-	redis     *redis.Client
 }
 
-func NewCustomProvider(apiKey, apiSecret string, redis *redis.Client) *CustomProvider {
+func NewCustomProvider(apiKey, apiSecret string) *CustomProvider {
 	return &CustomProvider{
 		apiKey:    apiKey,
 		apiSecret: apiSecret,
-		redis:     redis,
 	}
 }
 
@@ -43,28 +41,6 @@ func (p *CustomProvider) Send(ctx context.Context, req *sms.SendRequest) (*sms.S
 	}, nil
 }
 
-func (p *CustomProvider) Verify(ctx context.Context, req *sms.VerifyRequest) (*sms.VerifyResponse, error) {
-	// 实现验证码验证逻辑
-	key := fmt.Sprintf("sms:code:%s:%s", req.BizID, req.Phone)
-	storedCode, err := p.redis.Get(ctx, key).Result()
-	if err != nil {
-		return &sms.VerifyResponse{
-			Success: false,
-			ErrMsg:  "验证码不存在或已过期",
-		}, nil
-	}
-
-	if storedCode != req.Code {
-		return &sms.VerifyResponse{
-			Success: false,
-			ErrMsg:  "验证码错误",
-		}, nil
-	}
-
-	p.redis.Del(ctx, key)
-	return &sms.VerifyResponse{Success: true}, nil
-}
-
 func (p *CustomProvider) QueryStatus(ctx context.Context, msgID string) (*sms.StatusResponse, error) {
 	// 实现状态查询逻辑
 	return &sms.StatusResponse{
@@ -94,7 +70,7 @@ func main() {
 	})
 
 	// 创建自定义短信服务商
-	customProvider := NewCustomProvider("your-api-key", "your-api-secret", rdb)
+	customProvider := NewCustomProvider("your-api-key", "your-api-secret")
 
 	// 使用装饰器模式添加重试功能
 	providerWithRetry := sms.NewRetryProvider(customProvider, &sms.RetryConfig{
@@ -0,0 +1,87 @@
+package sms
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// FailoverProvider 多服务商故障转移
+// 按顺序尝试服务商，当前服务商失败且错误类型允许切换时使用下一个
+type FailoverProvider struct {
+	*providerGroup // QueryStatus、QueryStatusByPhone 路由到原服务商
+
+	failoverOn map[ErrorType]bool
+}
+
+// FailoverConfig 故障转移配置
+type FailoverConfig struct {
+	// FailoverOn 触发切换的错误类型，默认：熔断、余额不足、限流、其他错误
+	// 超时默认不切换（短信可能已经发出，切换会导致重复发送）；
+	// 格式错误、手机号无效换服务商也无法成功，默认不切换
+	FailoverOn []ErrorType
+	RouteTTL   time.Duration // 路由记录保存时间，默认 72 小时
+}
+
+// DefaultFailoverConfig 默认故障转移配置
+func DefaultFailoverConfig() *FailoverConfig {
+	return &FailoverConfig{
+		FailoverOn: []ErrorType{
+			ErrorTypeCircuitBreak,
+			ErrorTypeBalance,
+			ErrorTypeRateLimit,
+			ErrorTypeOther,
+		},
+		RouteTTL: 72 * time.Hour,
+	}
+}
+
+// NewFailoverProvider 创建故障转移服务商，entries 的顺序即优先级
+func NewFailoverProvider(redis *redis.Client, entries []*ProviderEntry, config *FailoverConfig) (*FailoverProvider, error) {
+	if config == nil {
+		config = DefaultFailoverConfig()
+	}
+	if err := validateEntries(entries); err != nil {
+		return nil, err
+	}
+
+	failoverOn := make(map[ErrorType]bool, len(config.FailoverOn))
+	for _, errType := range config.FailoverOn {
+		failoverOn[errType] = true
+	}
+
+	return &FailoverProvider{
+		providerGroup: newProviderGroup(redis, entries, config.RouteTTL),
+		failoverOn:    failoverOn,
+	}, nil
+}
+
+// Send 发送短信（失败时按顺序切换服务商）
+func (f *FailoverProvider) Send(ctx context.Context, req *SendRequest) (*SendResponse, error) {
+	var (
+		lastResp *SendResponse
+		lastErr  error
+	)
+
+	for _, entry := range f.entries {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		resp, err := f.send(ctx, entry, req)
+		if err == nil {
+			return resp, nil
+		}
+		lastResp, lastErr = resp, err
+
+		// 不应该切换，直接返回错误
+		if !f.failoverOn[ErrorTypeOf(err)] {
+			return resp, err
+		}
+	}
+
+	// 所有服务商都失败
+	return lastResp, fmt.Errorf("短信发送失败，已尝试%d个服务商: %w", len(f.entries), lastErr)
+}
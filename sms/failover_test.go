@@ -0,0 +1,83 @@
+package sms
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFailoverProvider(t *testing.T) {
+	ctx := context.Background()
+	rdb, _ := newTestRedis(t)
+	primary, secondary, backup := &stubProvider{}, &stubProvider{}, &stubProvider{}
+
+	failover, err := NewFailoverProvider(rdb, []*ProviderEntry{
+		{Name: "primary", Provider: primary},
+		{Name: "secondary", Provider: secondary, Templates: map[string]string{"SMS_123": "T_123"}},
+		{Name: "backup", Provider: backup},
+	}, nil)
+	require.NoError(t, err)
+
+	req := &SendRequest{Phone: "13800138000", Template: "SMS_123"}
+	resp, err := failover.Send(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, "primary", resp.Provider)
+
+	// 按顺序切换到下一个服务商
+	primary.err = NewSMSError("isv.AMOUNT_NOT_ENOUGH", "余额不足", false, nil).WithType(ErrorTypeBalance)
+	resp, err = failover.Send(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, "secondary", resp.Provider)
+	assert.Equal(t, []int{2, 1, 0}, []int{primary.calls, secondary.calls, backup.calls})
+
+	// 全部失败时返回最后一个错误
+	secondary.err = NewSMSError("isv.OUT_OF_SERVICE", "停机", false, nil).WithType(ErrorTypeCircuitBreak)
+	backup.err = secondary.err
+	_, err = failover.Send(ctx, req)
+	assert.ErrorIs(t, err, backup.err)
+	assert.Equal(t, []int{3, 2, 1}, []int{primary.calls, secondary.calls, backup.calls})
+
+	// 格式错误、超时不切换
+	for _, errType := range []ErrorType{ErrorTypeFormat, ErrorTypeTimeout} {
+		primary.err = NewSMSError("ERR", "失败", false, nil).WithType(errType)
+		_, err = failover.Send(ctx, req)
+		assert.ErrorIs(t, err, primary.err)
+	}
+	assert.Equal(t, []int{5, 2, 1}, []int{primary.calls, secondary.calls, backup.calls})
+}
+
+func TestFailoverQueryStatusByPhone(t *testing.T) {
+	ctx := context.Background()
+	rdb, _ := newTestRedis(t)
+	primary := &stubProvider{statuses: []*StatusResponse{{MsgID: "p1"}}}
+	secondary := &stubProvider{statuses: []*StatusResponse{{MsgID: "s1"}}}
+	backup := &stubProvider{statuses: []*StatusResponse{{MsgID: "b1"}}}
+
+	failover, err := NewFailoverProvider(rdb, []*ProviderEntry{
+		{Name: "primary", Provider: primary},
+		{Name: "secondary", Provider: secondary},
+		{Name: "backup", Provider: backup},
+	}, nil)
+	require.NoError(t, err)
+
+	// 没有路由记录时查询全部服务商
+	statuses, err := failover.QueryStatusByPhone(ctx, "13800138000")
+	require.NoError(t, err)
+	assert.Len(t, statuses, 3)
+
+	// 同一手机号先后经由两个服务商发送，查询这两个服务商（手机号格式不影响路由）
+	_, err = failover.Send(ctx, &SendRequest{Phone: "13800138000"})
+	require.NoError(t, err)
+	primary.err = NewSMSError("isv.OUT_OF_SERVICE", "停机", false, nil).WithType(ErrorTypeCircuitBreak)
+	_, err = failover.Send(ctx, &SendRequest{Phone: "+86 138 0013 8000"})
+	require.NoError(t, err)
+
+	statuses, err = failover.QueryStatusByPhone(ctx, "13800138000")
+	require.NoError(t, err)
+	require.Len(t, statuses, 2)
+	assert.Equal(t, "primary", statuses[0].Provider)
+	assert.Equal(t, "secondary", statuses[1].Provider)
+	assert.Equal(t, []int{2, 2, 1}, []int{primary.queries, secondary.queries, backup.queries})
+}
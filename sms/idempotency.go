@@ -0,0 +1,197 @@
+package sms
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	idempotencyPending      = "pending:"             // 处理中记录前缀，后接请求摘要和持有者令牌
+	idempotencyDone         = "done:"                // 完成记录前缀，后接请求摘要和 SendResponse JSON
+	idempotencyUnknown      = "unknown:"             // 结果未知记录前缀（发送超时等，服务商可能已发送），后接请求摘要
+	idempotencyGrace        = 5 * time.Second        // 处理中记录在发送超时之外多保留的时间，用于写入结果
+	idempotencyPollInterval = 100 * time.Millisecond // 等待并发请求完成的轮询间隔
+	idempotencyMaxWait      = 5 * time.Second        // 等待并发请求完成的最长时间
+)
+
+// finishIdempotencyScript 持有者写入最终记录（成功结果或结果未知）
+// KEYS[1]：幂等 key；ARGV[1]：处理中记录；ARGV[2]：最终记录；ARGV[3]：保存时间（毫秒）
+var finishIdempotencyScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3])
+	return 1
+end
+return 0
+`)
+
+// releaseIdempotencyScript 持有者释放处理中记录（发送被明确拒绝时允许重新发送）
+// KEYS[1]：幂等 key；ARGV[1]：处理中记录
+var releaseIdempotencyScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// idempotencyStore 发送幂等记录
+// 同一幂等键只有一个请求能获得处理权，其他并发请求等待其结果；
+// 成功结果和结果未知的记录保存 ttl，明确失败时删除记录
+type idempotencyStore struct {
+	redis       *redis.Client
+	ttl         time.Duration
+	sendTimeout time.Duration // 持有处理权时单次发送的总超时，处理中记录按此时间过期
+}
+
+// newIdempotencyStore 创建幂等记录
+func newIdempotencyStore(redis *redis.Client, ttl, sendTimeout time.Duration) *idempotencyStore {
+	if ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+	if sendTimeout <= 0 {
+		sendTimeout = time.Minute
+	}
+	return &idempotencyStore{
+		redis:       redis,
+		ttl:         ttl,
+		sendTimeout: sendTimeout,
+	}
+}
+
+// acquire 获取幂等键的处理权，digest 为请求摘要（见 idempotencyDigest）
+// 获得处理权时返回处理中记录（用于 complete/markUnknown/release）；已有成功结果时返回该结果；
+// 幂等键已用于内容不同的请求时返回 ErrIdempotencyMismatch；上次发送结果未知时返回 ErrIdempotencyUnknown；
+// 其他请求处理中且等待超时时返回 ErrIdempotencyInProgress
+func (s *idempotencyStore) acquire(ctx context.Context, key, digest string) (string, *SendResponse, error) {
+	token := make([]byte, 8)
+	if _, err := rand.Read(token); err != nil {
+		return "", nil, err
+	}
+	pending := idempotencyPending + digest + ":" + hex.EncodeToString(token)
+	redisKey := getIdempotencyKey(key)
+
+	deadline := time.Now().Add(idempotencyMaxWait)
+	for {
+		ok, err := s.redis.SetNX(ctx, redisKey, pending, s.sendTimeout+idempotencyGrace).Result()
+		if err != nil {
+			return "", nil, err
+		}
+		if ok {
+			return pending, nil, nil
+		}
+
+		value, err := s.redis.Get(ctx, redisKey).Result()
+		if err != nil && err != redis.Nil {
+			return "", nil, err
+		}
+		state, recorded, data := parseIdempotencyRecord(value)
+		if state != "" && recorded != digest {
+			return "", nil, ErrIdempotencyMismatch
+		}
+		switch state {
+		case idempotencyDone:
+			var resp SendResponse
+			if err := json.Unmarshal([]byte(data), &resp); err != nil {
+				return "", nil, err
+			}
+			return "", &resp, nil
+		case idempotencyUnknown:
+			return "", nil, ErrIdempotencyUnknown
+		}
+
+		// 其他请求处理中（或刚被释放），等待后重试
+		if time.Now().After(deadline) {
+			return "", nil, ErrIdempotencyInProgress
+		}
+		select {
+		case <-ctx.Done():
+			return "", nil, ctx.Err()
+		case <-time.After(idempotencyPollInterval):
+		}
+	}
+}
+
+// complete 保存成功的发送结果
+func (s *idempotencyStore) complete(ctx context.Context, key, pending string, resp *SendResponse) error {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	_, digest, _ := parseIdempotencyRecord(pending)
+	return s.finish(ctx, key, pending, idempotencyDone+digest+":"+string(data))
+}
+
+// markUnknown 记录发送结果未知（服务商可能已发送），保存时间内相同幂等键的请求返回 ErrIdempotencyUnknown，不会重复发送
+func (s *idempotencyStore) markUnknown(ctx context.Context, key, pending string) error {
+	_, digest, _ := parseIdempotencyRecord(pending)
+	return s.finish(ctx, key, pending, idempotencyUnknown+digest+":")
+}
+
+// finish 持有者写入最终记录
+func (s *idempotencyStore) finish(ctx context.Context, key, pending, record string) error {
+	return finishIdempotencyScript.Run(ctx, s.redis, []string{getIdempotencyKey(key)},
+		pending, record, s.ttl.Milliseconds()).Err()
+}
+
+// release 删除处理中记录
+func (s *idempotencyStore) release(ctx context.Context, key, pending string) error {
+	return releaseIdempotencyScript.Run(ctx, s.redis, []string{getIdempotencyKey(key)}, pending).Err()
+}
+
+// parseIdempotencyRecord 解析幂等记录，返回记录前缀、请求摘要和数据；无法识别时返回空
+func parseIdempotencyRecord(value string) (state, digest, data string) {
+	for _, prefix := range []string{idempotencyPending, idempotencyDone, idempotencyUnknown} {
+		if rest, ok := strings.CutPrefix(value, prefix); ok {
+			digest, data, _ = strings.Cut(rest, ":")
+			return prefix, digest, data
+		}
+	}
+	return "", "", ""
+}
+
+// idempotencyDigest 计算请求摘要（标准化手机号、模板、模板参数），同一幂等键只能用于内容相同的请求
+func idempotencyDigest(req *SendRequest) string {
+	data, _ := json.Marshal(struct {
+		Phone    string            `json:"p"`
+		Template string            `json:"t"`
+		Params   map[string]string `json:"a"` // map 按 key 排序序列化
+	}{req.GetFullPhone(), req.Template, req.Params})
+	return sha256Hex(data)
+}
+
+// isAmbiguousSendError 判断调用服务商后的错误是否无法确定短信是否已发送
+// 超时、请求被取消、网络错误（如请求写出后连接被重置）、响应为空或无法解析时，服务商可能已经受理
+func isAmbiguousSendError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) || ErrorTypeOf(err) == ErrorTypeTimeout {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var smsErr *SMSError
+	if errors.As(err, &smsErr) {
+		switch smsErr.Code {
+		case "NETWORK_ERROR", "RESPONSE_ERROR", "UNKNOWN_ERROR":
+			// UNKNOWN_ERROR：阿里云 SDK 返回的非服务端错误（连接失败、读取响应失败等）
+			return true
+		}
+	}
+	return false
+}
+
+// getIdempotencyKey 获取幂等记录的key
+func getIdempotencyKey(key string) string {
+	return "sms:idempotency:" + key
+}
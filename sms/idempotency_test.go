@@ -0,0 +1,143 @@
+package sms
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSendIdempotency(t *testing.T) {
+	ctx := context.Background()
+	rdb, _ := newTestRedis(t)
+	provider := &stubProvider{}
+	client := NewClient(&ClientConfig{Redis: rdb, Provider: provider})
+	req := &SendRequest{Phone: "13800138000", Template: "SMS_123", Params: map[string]string{"order": "1"}, IdempotencyKey: "order:1"}
+
+	first, err := client.Send(ctx, req)
+	require.NoError(t, err)
+
+	// 重复请求返回首次结果，不再调用服务商；格式不同的同一手机号视为相同内容
+	again, err := client.Send(ctx, &SendRequest{Phone: "+86 138 0013 8000", Template: "SMS_123", Params: map[string]string{"order": "1"}, IdempotencyKey: "order:1"})
+	require.NoError(t, err)
+	assert.Equal(t, first, again)
+	assert.Equal(t, 1, provider.calls)
+
+	// 幂等键用于内容不同的请求
+	_, err = client.Send(ctx, &SendRequest{Phone: "13800138000", Template: "SMS_123", Params: map[string]string{"order": "2"}, IdempotencyKey: "order:1"})
+	assert.ErrorIs(t, err, ErrIdempotencyMismatch)
+	_, err = client.Send(ctx, &SendRequest{Phone: "13900139000", Template: "SMS_123", Params: map[string]string{"order": "1"}, IdempotencyKey: "order:1"})
+	assert.ErrorIs(t, err, ErrIdempotencyMismatch)
+	assert.Equal(t, 1, provider.calls)
+}
+
+func TestSendIdempotencyFailure(t *testing.T) {
+	ctx := context.Background()
+	rdb, _ := newTestRedis(t)
+	provider := &stubProvider{}
+	client := NewClient(&ClientConfig{Redis: rdb, Provider: provider, LimiterConfig: &LimiterConfig{PhonePerDay: 100}})
+	send := func(key string) error {
+		_, err := client.Send(ctx, &SendRequest{Phone: "13800138000", Template: "SMS_123", IdempotencyKey: key})
+		return err
+	}
+
+	// 服务商明确拒绝时释放幂等键，可以重试
+	provider.err = NewSMSError("isv.BUSINESS_LIMIT_CONTROL", "业务限流", false, nil).WithType(ErrorTypeRateLimit)
+	require.Error(t, send("rejected"))
+	provider.err = nil
+	require.NoError(t, send("rejected"))
+	assert.Equal(t, 2, provider.calls)
+
+	// 发送超时无法确定是否已发送，保留记录，重复请求不再调用服务商
+	provider.err = NewSMSError("TIMEOUT", "请求超时", false, context.DeadlineExceeded).WithType(ErrorTypeTimeout)
+	require.Error(t, send("timeout"))
+	provider.err = nil
+	assert.ErrorIs(t, send("timeout"), ErrIdempotencyUnknown)
+	assert.Equal(t, 3, provider.calls)
+
+	// 请求写出后连接被重置、响应为空同样无法确定
+	for _, key := range []string{"network", "response"} {
+		provider.err = NewSMSError(strings.ToUpper(key)+"_ERROR", "发送结果未知", true, nil)
+		require.Error(t, send(key))
+		provider.err = nil
+		assert.ErrorIs(t, send(key), ErrIdempotencyUnknown)
+	}
+	assert.Equal(t, 5, provider.calls)
+
+	// 调用服务商之前失败（如参数错误）时释放幂等键
+	_, err := client.Send(ctx, &SendRequest{Phone: "abc", Template: "SMS_123", IdempotencyKey: "invalid"})
+	assert.ErrorIs(t, err, ErrInvalidParams)
+	assert.False(t, rdb.Exists(ctx, getIdempotencyKey("invalid")).Val() > 0)
+}
+
+// countingProvider 并发安全地统计调用次数的服务商
+type countingProvider struct {
+	calls atomic.Int32
+}
+
+func (p *countingProvider) Send(ctx context.Context, req *SendRequest) (*SendResponse, error) {
+	n := p.calls.Add(1)
+	time.Sleep(50 * time.Millisecond)
+	return &SendResponse{MsgID: "msg_" + strconv.Itoa(int(n)), Success: true}, nil
+}
+
+func (p *countingProvider) QueryStatus(ctx context.Context, msgID string) (*StatusResponse, error) {
+	return nil, nil
+}
+
+func (p *countingProvider) QueryStatusByPhone(ctx context.Context, phone string) ([]*StatusResponse, error) {
+	return nil, nil
+}
+
+func TestSendIdempotencyConcurrent(t *testing.T) {
+	ctx := context.Background()
+	rdb, _ := newTestRedis(t)
+	provider := &countingProvider{}
+	client := NewClient(&ClientConfig{Redis: rdb, Provider: provider})
+
+	// 同一幂等键并发发送，只调用一次服务商，其余请求等待并返回相同结果
+	const n = 10
+	var (
+		wg    sync.WaitGroup
+		resps [n]*SendResponse
+		errs  [n]error
+	)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resps[i], errs[i] = client.Send(ctx, &SendRequest{Phone: "13800138000", Template: "SMS_123", IdempotencyKey: "order:1"})
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), provider.calls.Load())
+	for i := 0; i < n; i++ {
+		require.NoError(t, errs[i])
+		assert.Equal(t, "msg_1", resps[i].MsgID)
+	}
+}
+
+func TestIdempotencyPendingTTL(t *testing.T) {
+	ctx := context.Background()
+	rdb, _ := newTestRedis(t)
+	store := newIdempotencyStore(rdb, 0, 10*time.Second)
+
+	// 处理中记录按发送超时过期
+	pending, resp, err := store.acquire(ctx, "key", "digest")
+	require.NoError(t, err)
+	require.Nil(t, resp)
+	assert.Equal(t, 10*time.Second+idempotencyGrace, rdb.PTTL(ctx, getIdempotencyKey("key")).Val())
+
+	// 结果未知的记录保存 ttl
+	require.NoError(t, store.markUnknown(ctx, "key", pending))
+	assert.Equal(t, 24*time.Hour, rdb.PTTL(ctx, getIdempotencyKey("key")).Val())
+	_, _, err = store.acquire(ctx, "key", "digest")
+	assert.ErrorIs(t, err, ErrIdempotencyUnknown)
+}
@@ -0,0 +1,274 @@
+package sms
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// UnsubscribeKeywords 默认退订关键词
+var UnsubscribeKeywords = []string{"TD", "退订", "STOP", "UNSUBSCRIBE"}
+
+// DefaultUnsubscribeBizID 未指定业务时退订写入的业务名单（营销类短信）
+// 退订默认不写入全局名单，避免用户回复 TD 后收不到登录、验证码等短信
+const DefaultUnsubscribeBizID = "marketing"
+
+// InboundMessage 上行短信（用户回复的短信）
+type InboundMessage struct {
+	Phone       string // 手机号
+	CountryCode string // 国家代码（服务商提供时填写，如：+86）
+	Content     string // 短信内容
+	SignName    string // 用户回复的短信签名
+	ExtendCode  string // 扩展码（用于区分回复的是哪个业务的短信）
+	Provider    string // 服务商名称
+	ReceiveTime int64  // 接收时间（Unix时间戳）
+}
+
+// InboundParser 上行短信解析器，每个服务商一个实现
+type InboundParser interface {
+	// Parse 解析服务商推送的上行短信
+	Parse(r *http.Request, body []byte) ([]*InboundMessage, error)
+	// Reply 按服务商要求的格式响应推送，err 不为空时服务商会稍后重新推送
+	Reply(w http.ResponseWriter, err error)
+}
+
+// InboundSink 上行短信接收方
+type InboundSink interface {
+	HandleInbound(ctx context.Context, msg *InboundMessage) error
+}
+
+// InboundFunc 上行短信回调函数
+type InboundFunc func(ctx context.Context, msg *InboundMessage) error
+
+// HandleInbound 实现 InboundSink
+func (f InboundFunc) HandleInbound(ctx context.Context, msg *InboundMessage) error {
+	return f(ctx, msg)
+}
+
+// InboundHandler 上行短信推送接收（http.Handler）
+// 解析服务商推送的上行短信，校验通过后交给接收方（通常是 KeywordRouter）处理；未配置校验（见 SetVerifier）时拒绝推送
+type InboundHandler struct {
+	callbackVerifier
+
+	provider string // 服务商名称（写入 InboundMessage.Provider）
+	parser   InboundParser
+	sink     InboundSink
+}
+
+// NewInboundHandler 创建上行短信推送接收
+func NewInboundHandler(provider string, parser InboundParser, sink InboundSink) *InboundHandler {
+	return &InboundHandler{
+		provider: provider,
+		parser:   parser,
+		sink:     sink,
+	}
+}
+
+// ServeHTTP 处理上行短信推送
+func (h *InboundHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, ok := readCallback(w, r, &h.callbackVerifier, h.parser, h.parser.Reply)
+	if !ok {
+		return
+	}
+
+	messages, err := h.parser.Parse(r, body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var errs []error
+	for _, msg := range messages {
+		msg.Provider = h.provider
+		if err := h.sink.HandleInbound(r.Context(), msg); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	h.parser.Reply(w, errors.Join(errs...))
+}
+
+// ========== 关键词路由 ==========
+
+// KeywordRouter 上行短信关键词路由
+// 按短信内容匹配关键词（忽略大小写和首尾空白），先匹配完整内容，再匹配第一个词；都未匹配时交给默认处理
+type KeywordRouter struct {
+	mu       sync.RWMutex
+	handlers map[string]InboundSink // 关键词（大写） -> 处理
+	fallback InboundSink
+}
+
+// NewKeywordRouter 创建关键词路由
+func NewKeywordRouter() *KeywordRouter {
+	return &KeywordRouter{
+		handlers: make(map[string]InboundSink),
+	}
+}
+
+// Handle 注册关键词处理，同一关键词重复注册时覆盖
+func (k *KeywordRouter) Handle(keyword string, sink InboundSink) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.handlers[normalizeKeyword(keyword)] = sink
+}
+
+// HandleFunc 注册关键词回调函数
+func (k *KeywordRouter) HandleFunc(keyword string, fn func(ctx context.Context, msg *InboundMessage) error) {
+	k.Handle(keyword, InboundFunc(fn))
+}
+
+// Default 设置未匹配关键词时的处理
+func (k *KeywordRouter) Default(sink InboundSink) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.fallback = sink
+}
+
+// HandleUnsubscribe 注册退订关键词（默认 UnsubscribeKeywords），用户回复后加入免打扰名单
+// bizIDs 为空时加入 DefaultUnsubscribeBizID 业务的名单；需要加入全局名单时显式传入空字符串
+func (k *KeywordRouter) HandleUnsubscribe(list *SuppressionList, bizIDs ...string) {
+	sink := NewUnsubscribeHandler(list, bizIDs...)
+	for _, keyword := range UnsubscribeKeywords {
+		k.Handle(keyword, sink)
+	}
+}
+
+// HandleInbound 实现 InboundSink
+func (k *KeywordRouter) HandleInbound(ctx context.Context, msg *InboundMessage) error {
+	content := normalizeKeyword(msg.Content)
+
+	k.mu.RLock()
+	sink, ok := k.handlers[content]
+	if !ok {
+		if fields := strings.Fields(content); len(fields) > 0 {
+			sink, ok = k.handlers[fields[0]]
+		}
+	}
+	if !ok {
+		sink = k.fallback
+	}
+	k.mu.RUnlock()
+
+	if sink == nil {
+		return nil
+	}
+	return sink.HandleInbound(ctx, msg)
+}
+
+// NewUnsubscribeHandler 创建退订处理，将回复的手机号加入免打扰名单
+// bizIDs 为空时加入 DefaultUnsubscribeBizID 业务的名单，传入空字符串表示全局名单
+func NewUnsubscribeHandler(list *SuppressionList, bizIDs ...string) InboundSink {
+	if len(bizIDs) == 0 {
+		bizIDs = []string{DefaultUnsubscribeBizID}
+	}
+	return InboundFunc(func(ctx context.Context, msg *InboundMessage) error {
+		number := msg.Phone
+		if msg.CountryCode != "" {
+			number = normalizePhone(msg.Phone, msg.CountryCode)
+		}
+		for _, bizID := range bizIDs {
+			if err := list.Add(ctx, number, bizID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// normalizeKeyword 关键词标准化：去除首尾空白并转为大写
+func normalizeKeyword(keyword string) string {
+	return strings.ToUpper(strings.TrimSpace(keyword))
+}
+
+// ========== 阿里云 ==========
+
+// AliyunInboundParser 阿里云上行短信（SmsUp）解析
+type AliyunInboundParser struct{}
+
+// aliyunInbound 阿里云上行短信
+type aliyunInbound struct {
+	PhoneNumber string `json:"phone_number"`
+	SendTime    string `json:"send_time"`
+	Content     string `json:"content"`
+	SignName    string `json:"sign_name"`
+	DestCode    string `json:"dest_code"`
+}
+
+// Parse 解析阿里云上行短信（JSON 数组）
+func (AliyunInboundParser) Parse(r *http.Request, body []byte) ([]*InboundMessage, error) {
+	var reports []aliyunInbound
+	if err := json.Unmarshal(body, &reports); err != nil {
+		return nil, fmt.Errorf("解析阿里云上行短信失败: %w", err)
+	}
+
+	messages := make([]*InboundMessage, 0, len(reports))
+	for _, report := range reports {
+		messages = append(messages, &InboundMessage{
+			Phone:       report.PhoneNumber,
+			Content:     report.Content,
+			SignName:    report.SignName,
+			ExtendCode:  report.DestCode,
+			ReceiveTime: parseReceiptTime(report.SendTime),
+		})
+	}
+	return messages, nil
+}
+
+// Reply 响应阿里云推送
+func (AliyunInboundParser) Reply(w http.ResponseWriter, err error) {
+	replyAliyun(w, err)
+}
+
+// ========== 腾讯云 ==========
+
+// TencentInboundParser 腾讯云短信回复回调解析
+type TencentInboundParser struct{}
+
+// tencentInbound 腾讯云短信回复
+type tencentInbound struct {
+	Extend     string `json:"extend"`
+	Mobile     string `json:"mobile"`
+	NationCode string `json:"nationcode"`
+	Sign       string `json:"sign"`
+	Text       string `json:"text"`
+	Time       int64  `json:"time"`
+}
+
+// Parse 解析腾讯云短信回复（单个 JSON 对象或数组）
+func (TencentInboundParser) Parse(r *http.Request, body []byte) ([]*InboundMessage, error) {
+	var reports []tencentInbound
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '{' {
+		reports = make([]tencentInbound, 1)
+		if err := json.Unmarshal(body, &reports[0]); err != nil {
+			return nil, fmt.Errorf("解析腾讯云短信回复失败: %w", err)
+		}
+	} else if err := json.Unmarshal(body, &reports); err != nil {
+		return nil, fmt.Errorf("解析腾讯云短信回复失败: %w", err)
+	}
+
+	messages := make([]*InboundMessage, 0, len(reports))
+	for _, report := range reports {
+		msg := &InboundMessage{
+			Phone:       report.Mobile,
+			Content:     report.Text,
+			SignName:    report.Sign,
+			ExtendCode:  report.Extend,
+			ReceiveTime: report.Time,
+		}
+		if report.NationCode != "" {
+			msg.CountryCode = "+" + report.NationCode
+		}
+		messages = append(messages, msg)
+	}
+	return messages, nil
+}
+
+// Reply 响应腾讯云回调
+func (TencentInboundParser) Reply(w http.ResponseWriter, err error) {
+	replyTencent(w, err)
+}
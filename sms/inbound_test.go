@@ -0,0 +1,78 @@
+package sms
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeywordRouter(t *testing.T) {
+	ctx := context.Background()
+	var matched []string
+
+	router := NewKeywordRouter()
+	router.HandleFunc("y", func(ctx context.Context, msg *InboundMessage) error {
+		matched = append(matched, "confirm:"+msg.Content)
+		return nil
+	})
+	router.HandleFunc("确认 订单", func(ctx context.Context, msg *InboundMessage) error {
+		matched = append(matched, "order:"+msg.Content)
+		return nil
+	})
+	router.Default(InboundFunc(func(ctx context.Context, msg *InboundMessage) error {
+		matched = append(matched, "default:"+msg.Content)
+		return nil
+	}))
+
+	for _, content := range []string{" Y ", "y 123", "确认 订单", "你好"} {
+		require.NoError(t, router.HandleInbound(ctx, &InboundMessage{Phone: "13800138000", Content: content}))
+	}
+	assert.Equal(t, []string{"confirm: Y ", "confirm:y 123", "order:确认 订单", "default:你好"}, matched)
+
+	// 退订写入免打扰名单失败时返回错误
+	router.HandleUnsubscribe(NewSuppressionList(newOfflineRedis()), "marketing")
+	assert.Error(t, router.HandleInbound(ctx, &InboundMessage{Phone: "13800138000", Content: "td"}))
+}
+
+func TestInboundHandler(t *testing.T) {
+	var received []*InboundMessage
+	handler := NewInboundHandler("tencent", TencentInboundParser{}, InboundFunc(func(ctx context.Context, msg *InboundMessage) error {
+		received = append(received, msg)
+		return nil
+	}))
+
+	// 未配置校验时拒绝推送
+	body := `{"extend":"01","mobile":"13800138000","nationcode":"86","sign":"测试签名","text":"TD","time":1733625000}`
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/sms/inbound", strings.NewReader(body)))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Empty(t, received)
+
+	handler.SetVerifier(CallbackToken("t0ken"))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/sms/inbound?token=t0ken", strings.NewReader(body)))
+
+	assert.JSONEq(t, `{"result":0,"errmsg":"OK"}`, w.Body.String())
+	require.Len(t, received, 1)
+	assert.Equal(t, &InboundMessage{
+		Phone:       "13800138000",
+		CountryCode: "+86",
+		Content:     "TD",
+		SignName:    "测试签名",
+		ExtendCode:  "01",
+		Provider:    "tencent",
+		ReceiveTime: 1733625000,
+	}, received[0])
+
+	messages, err := AliyunInboundParser{}.Parse(nil, []byte(`[{"phone_number":"13800138000","send_time":"2024-12-08 10:30:00","content":"退订","sign_name":"测试签名","dest_code":"1234"}]`))
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+	assert.Equal(t, "退订", messages[0].Content)
+	assert.Equal(t, "1234", messages[0].ExtendCode)
+	assert.Equal(t, int64(1733625000), messages[0].ReceiveTime)
+}
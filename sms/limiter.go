@@ -3,15 +3,140 @@ package sms
 import (
 	"context"
 	"fmt"
+	"math/rand"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
+// LimitDimension 限流维度
+type LimitDimension string
+
+const (
+	DimensionPhone  LimitDimension = "phone"  // 手机号
+	DimensionDevice LimitDimension = "device" // 设备
+	DimensionIP     LimitDimension = "ip"     // IP
+	DimensionUser   LimitDimension = "user"   // 用户账号
+)
+
+// limitScript 原子地检查并增加多个计数器
+// KEYS[i]：计数器 key；ARGV[1]：是否只检查不计数（1/0）；ARGV[2i]：限制次数；
+// ARGV[2i+1]：距离时间桶结束的毫秒数（同时作为计数器过期时间）
+// 返回 {序号, 当前次数, 距离重置的毫秒数}：
+// 有计数器达到限制时不增加任何计数，返回其中需要等待最久的计数器（序号从 1 开始）；全部通过返回序号 0
+var limitScript = redis.NewScript(`
+local index, count, wait = 0, 0, -1
+for i = 1, #KEYS do
+	local current = tonumber(redis.call('GET', KEYS[i]) or '0')
+	if current >= tonumber(ARGV[2 * i]) then
+		local ttl = tonumber(ARGV[2 * i + 1])
+		if ttl > wait then
+			index, count, wait = i, current, ttl
+		end
+	end
+end
+if index > 0 or ARGV[1] == '1' then
+	return {index, count, math.max(wait, 0)}
+end
+for i = 1, #KEYS do
+	if redis.call('INCR', KEYS[i]) == 1 then
+		redis.call('PEXPIRE', KEYS[i], ARGV[2 * i + 1])
+	end
+end
+return {0, 0, 0}
+`)
+
+// slidingLimitScript 滑动窗口（滑动日志）模式下原子地检查并记录多个维度
+// KEYS[i]：有序集合 key；ARGV[1]：是否只检查不计数（1/0）；ARGV[2]：当前时间（毫秒）；
+// ARGV[3]：本次请求的唯一成员；ARGV[2i+2]：限制次数；ARGV[2i+3]：窗口长度（毫秒）
+// 返回值含义与 limitScript 一致，等待时间为窗口内次数降到限制以下所需的时间
+var slidingLimitScript = redis.NewScript(`
+local now = tonumber(ARGV[2])
+local index, count, wait = 0, 0, -1
+for i = 1, #KEYS do
+	local limit = tonumber(ARGV[2 * i + 2])
+	local window = tonumber(ARGV[2 * i + 3])
+	redis.call('ZREMRANGEBYSCORE', KEYS[i], '-inf', now - window)
+	local current = redis.call('ZCARD', KEYS[i])
+	if current >= limit then
+		local oldest = redis.call('ZRANGE', KEYS[i], current - limit, current - limit, 'WITHSCORES')
+		local ttl = tonumber(oldest[2]) + window - now
+		if ttl > wait then
+			index, count, wait = i, current, ttl
+		end
+	end
+end
+if index > 0 or ARGV[1] == '1' then
+	return {index, count, math.max(wait, 0)}
+end
+for i = 1, #KEYS do
+	redis.call('ZADD', KEYS[i], now, ARGV[3])
+	redis.call('PEXPIRE', KEYS[i], ARGV[2 * i + 3])
+end
+return {0, 0, 0}
+`)
+
+// limitPeriod 限流周期
+type limitPeriod struct {
+	name   string        // 周期名称（minute/hour/day）
+	layout string        // 固定窗口的时间桶格式
+	window time.Duration // 窗口长度
+}
+
+var (
+	periodMinute = limitPeriod{name: "minute", layout: "200601021504", window: time.Minute}
+	periodHour   = limitPeriod{name: "hour", layout: "2006010215", window: time.Hour}
+	periodDay    = limitPeriod{name: "day", layout: "20060102", window: 24 * time.Hour}
+)
+
+// bucketEnd 固定窗口时间桶的结束时间（与时间桶格式一致，按 now 所在时区的自然分钟/小时/天）
+func (p limitPeriod) bucketEnd(now time.Time) time.Time {
+	y, m, d := now.Date()
+	switch p.name {
+	case periodMinute.name:
+		return time.Date(y, m, d, now.Hour(), now.Minute()+1, 0, 0, now.Location())
+	case periodHour.name:
+		return time.Date(y, m, d, now.Hour()+1, 0, 0, 0, now.Location())
+	default:
+		return time.Date(y, m, d+1, 0, 0, 0, 0, now.Location())
+	}
+}
+
+// bucketTTL 距离时间桶结束的时间，用作固定窗口计数器的过期时间和超限后的等待时间
+func (p limitPeriod) bucketTTL(now time.Time) time.Duration {
+	ttl := p.bucketEnd(now).Sub(now)
+	if ttl < time.Millisecond {
+		ttl = time.Millisecond // PEXPIRE 0 会直接删除 key
+	}
+	return ttl
+}
+
+// limitRule 限流规则
+type limitRule struct {
+	dimension LimitDimension // 限流维度
+	period    string         // 限流周期
+	key       string         // 计数器 key
+	limit     int            // 限制次数
+	ttl       time.Duration  // 固定窗口为距离时间桶结束的时间，滑动窗口为窗口长度
+}
+
+// dimensionErrors 限流维度对应的错误
+var dimensionErrors = map[LimitDimension]error{
+	DimensionPhone:  ErrPhoneRateLimit,
+	DimensionDevice: ErrDeviceRateLimit,
+	DimensionIP:     ErrIPRateLimit,
+	DimensionUser:   ErrUserRateLimit,
+}
+
 // RateLimiter 限流器
 type RateLimiter struct {
 	redis  *redis.Client
-	config *LimiterConfig
+	config *LimiterConfig // 默认配置
+
+	mu         sync.RWMutex
+	bizConfigs map[string]*LimiterConfig // bizID -> 业务专属配置
 }
 
 // NewRateLimiter 创建限流器
@@ -20,183 +145,239 @@ func NewRateLimiter(redis *redis.Client, config *LimiterConfig) *RateLimiter {
 		config = DefaultLimiterConfig()
 	}
 	return &RateLimiter{
-		redis:  redis,
-		config: config,
+		redis:      redis,
+		config:     config,
+		bizConfigs: make(map[string]*LimiterConfig),
 	}
 }
 
-// CheckAndIncrement 检查并增加计数
-func (l *RateLimiter) CheckAndIncrement(ctx context.Context, req *SendRequest) error {
-	// 1. 检查手机号限流
-	if err := l.checkPhoneLimit(ctx, req.Phone); err != nil {
-		return err
-	}
+// SetBizConfig 设置业务专属的限流配置，config 为 nil 时恢复使用默认配置
+func (l *RateLimiter) SetBizConfig(bizID string, config *LimiterConfig) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 
-	// 2. 检查设备限流
-	if req.DeviceID != "" {
-		if err := l.checkDeviceLimit(ctx, req.DeviceID); err != nil {
-			return err
-		}
+	if config == nil {
+		delete(l.bizConfigs, bizID)
+		return
 	}
+	l.bizConfigs[bizID] = config
+}
 
-	// 3. 检查IP限流
-	if req.IP != "" {
-		if err := l.checkIPLimit(ctx, req.IP); err != nil {
-			return err
-		}
+// configFor 获取业务使用的限流配置，没有专属配置时使用默认配置
+func (l *RateLimiter) configFor(bizID string) *LimiterConfig {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if config, ok := l.bizConfigs[bizID]; ok {
+		return config
 	}
+	return l.config
+}
 
-	// 所有检查通过，增加计数
-	if err := l.incrementCounters(ctx, req); err != nil {
+// CheckAndIncrement 检查并增加计数
+// 手机号、设备、IP、用户账号的检查和计数在一个 Lua 脚本中完成，并发请求不会同时通过检查；
+// 任一维度超限时返回 *RateLimitError（可通过 errors.Is 判断维度，如 ErrPhoneRateLimit），且不增加任何计数
+func (l *RateLimiter) CheckAndIncrement(ctx context.Context, req *SendRequest) error {
+	violated, err := l.eval(ctx, req, false)
+	if err != nil {
 		return err
 	}
-
+	if violated != nil {
+		return violated
+	}
 	return nil
 }
 
-// checkPhoneLimit 检查手机号限流
-func (l *RateLimiter) checkPhoneLimit(ctx context.Context, phone string) error {
-	now := time.Now()
+// Cooldown 获取距离下一次允许发送的剩余时间，可以立即发送时返回 0（不增加计数）
+func (l *RateLimiter) Cooldown(ctx context.Context, req *SendRequest) (time.Duration, error) {
+	violated, err := l.eval(ctx, req, true)
+	if err != nil {
+		return 0, err
+	}
+	if violated == nil {
+		return 0, nil
+	}
+	return violated.RetryAfter, nil
+}
 
-	// 检查3分钟限制
-	if l.config.PhonePerMinute > 0 {
-		key := fmt.Sprintf("sms:limiter:phone:minute:%s:%s", phone, now.Format("200601021504"))
-		count, err := l.redis.Get(ctx, key).Int()
-		if err != nil && err != redis.Nil {
-			return err
-		}
-		if count >= l.config.PhonePerMinute {
-			return ErrPhoneRateLimit
-		}
+// eval 执行限流检查，超限时返回需要等待最久的维度
+func (l *RateLimiter) eval(ctx context.Context, req *SendRequest, dryRun bool) (*RateLimitError, error) {
+	now := time.Now()
+	config := l.configFor(req.BizID)
+	rules := l.rules(config, req, now)
+	if len(rules) == 0 {
+		return nil, nil
 	}
 
-	// 检查1小时限制
-	if l.config.PhonePerHour > 0 {
-		key := fmt.Sprintf("sms:limiter:phone:hour:%s:%s", phone, now.Format("2006010215"))
-		count, err := l.redis.Get(ctx, key).Int()
-		if err != nil && err != redis.Nil {
-			return err
-		}
-		if count >= l.config.PhonePerHour {
-			return ErrPhoneRateLimit
-		}
+	if config.Mode == LimiterModeSliding {
+		return evalSlidingLimitScript(ctx, l.redis, rules, now, dryRun)
 	}
+	return evalLimitScript(ctx, l.redis, rules, dryRun)
+}
 
-	// 检查24小时限制
-	if l.config.PhonePerDay > 0 {
-		key := fmt.Sprintf("sms:limiter:phone:day:%s:%s", phone, now.Format("20060102"))
-		count, err := l.redis.Get(ctx, key).Int()
-		if err != nil && err != redis.Nil {
-			return err
-		}
-		if count >= l.config.PhonePerDay {
-			return ErrPhoneRateLimit
+// rules 生成请求需要检查的限流规则
+func (l *RateLimiter) rules(config *LimiterConfig, req *SendRequest, now time.Time) []*limitRule {
+	var rules []*limitRule
+
+	rule := func(dimension LimitDimension, period limitPeriod, subject string, limit int) {
+		ttl := period.window
+		if config.Mode != LimiterModeSliding {
+			ttl = period.bucketTTL(now)
 		}
+		rules = append(rules, &limitRule{
+			dimension: dimension,
+			period:    period.name,
+			key:       limiterKey(config.Mode, req.BizID, dimension, period, subject, now),
+			limit:     limit,
+			ttl:       ttl,
+		})
 	}
 
-	return nil
-}
+	// 手机号限流（按 E.164 格式计数）
+	phone := req.GetFullPhone()
+	if config.PhonePerMinute > 0 {
+		rule(DimensionPhone, periodMinute, phone, config.PhonePerMinute)
+	}
+	if config.PhonePerHour > 0 {
+		rule(DimensionPhone, periodHour, phone, config.PhonePerHour)
+	}
+	if config.PhonePerDay > 0 {
+		rule(DimensionPhone, periodDay, phone, config.PhonePerDay)
+	}
 
-// checkDeviceLimit 检查设备限流
-func (l *RateLimiter) checkDeviceLimit(ctx context.Context, deviceID string) error {
-	if l.config.DevicePerDay == 0 {
-		return nil
+	// 设备限流
+	if req.DeviceID != "" && config.DevicePerDay > 0 {
+		rule(DimensionDevice, periodDay, req.DeviceID, config.DevicePerDay)
 	}
 
-	now := time.Now()
-	key := fmt.Sprintf("sms:limiter:device:day:%s:%s", deviceID, now.Format("20060102"))
-	count, err := l.redis.Get(ctx, key).Int()
-	if err != nil && err != redis.Nil {
-		return err
+	// IP限流
+	if req.IP != "" && config.IPPerDay > 0 {
+		rule(DimensionIP, periodDay, req.IP, config.IPPerDay)
 	}
 
-	if count >= l.config.DevicePerDay {
-		return ErrDeviceRateLimit
+	// 用户账号限流
+	if req.UserID != "" {
+		if config.UserPerMinute > 0 {
+			rule(DimensionUser, periodMinute, req.UserID, config.UserPerMinute)
+		}
+		if config.UserPerHour > 0 {
+			rule(DimensionUser, periodHour, req.UserID, config.UserPerHour)
+		}
+		if config.UserPerDay > 0 {
+			rule(DimensionUser, periodDay, req.UserID, config.UserPerDay)
+		}
 	}
 
-	return nil
+	return rules
 }
 
-// checkIPLimit 检查IP限流
-func (l *RateLimiter) checkIPLimit(ctx context.Context, ip string) error {
-	if l.config.IPPerDay == 0 {
-		return nil
+// limiterKey 获取计数器的key，计数器按业务隔离（bizID 为空时不带业务前缀）
+// 固定窗口：sms:limiter:[biz:{bizID}:]{dimension}:{period}:{subject}:{时间桶}
+// 滑动窗口：sms:limiter:sliding:[biz:{bizID}:]{dimension}:{period}:{subject}
+func limiterKey(mode LimiterMode, bizID string, dimension LimitDimension, period limitPeriod, subject string, now time.Time) string {
+	prefix := "sms:limiter:"
+	if mode == LimiterModeSliding {
+		prefix += "sliding:"
+	}
+	if bizID != "" {
+		prefix += "biz:" + bizID + ":"
 	}
 
-	now := time.Now()
-	key := fmt.Sprintf("sms:limiter:ip:day:%s:%s", ip, now.Format("20060102"))
-	count, err := l.redis.Get(ctx, key).Int()
-	if err != nil && err != redis.Nil {
-		return err
+	if mode == LimiterModeSliding {
+		return fmt.Sprintf("%s%s:%s:%s", prefix, dimension, period.name, subject)
 	}
+	return fmt.Sprintf("%s%s:%s:%s:%s", prefix, dimension, period.name, subject, now.Format(period.layout))
+}
 
-	if count >= l.config.IPPerDay {
-		return ErrIPRateLimit
+// evalLimitScript 执行固定窗口限流脚本，返回超限信息，全部通过时返回 nil
+func evalLimitScript(ctx context.Context, rdb *redis.Client, rules []*limitRule, dryRun bool) (*RateLimitError, error) {
+	keys := make([]string, 0, len(rules))
+	args := make([]interface{}, 0, 1+2*len(rules))
+	args = append(args, boolArg(dryRun))
+	for _, rule := range rules {
+		keys = append(keys, rule.key)
+		args = append(args, rule.limit, rule.ttl.Milliseconds())
 	}
 
-	return nil
+	result, err := limitScript.Run(ctx, rdb, keys, args...).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+	return parseLimitResult(rules, result), nil
 }
 
-// incrementCounters 增加所有计数器
-func (l *RateLimiter) incrementCounters(ctx context.Context, req *SendRequest) error {
-	now := time.Now()
+// evalSlidingLimitScript 执行滑动窗口限流脚本，返回超限信息，全部通过时返回 nil
+func evalSlidingLimitScript(ctx context.Context, rdb *redis.Client, rules []*limitRule, now time.Time, dryRun bool) (*RateLimitError, error) {
+	member := strconv.FormatInt(now.UnixNano(), 10) + "-" + strconv.FormatInt(rand.Int63(), 36)
 
-	// 使用 pipeline 批量执行
-	pipe := l.redis.Pipeline()
-
-	// 手机号计数器
-	if l.config.PhonePerMinute > 0 {
-		key := fmt.Sprintf("sms:limiter:phone:minute:%s:%s", req.Phone, now.Format("200601021504"))
-		pipe.Incr(ctx, key)
-		pipe.Expire(ctx, key, time.Minute)
+	keys := make([]string, 0, len(rules))
+	args := make([]interface{}, 0, 3+2*len(rules))
+	args = append(args, boolArg(dryRun), now.UnixMilli(), member)
+	for _, rule := range rules {
+		keys = append(keys, rule.key)
+		args = append(args, rule.limit, rule.ttl.Milliseconds())
 	}
 
-	if l.config.PhonePerHour > 0 {
-		key := fmt.Sprintf("sms:limiter:phone:hour:%s:%s", req.Phone, now.Format("2006010215"))
-		pipe.Incr(ctx, key)
-		pipe.Expire(ctx, key, time.Hour)
+	result, err := slidingLimitScript.Run(ctx, rdb, keys, args...).Int64Slice()
+	if err != nil {
+		return nil, err
 	}
+	return parseLimitResult(rules, result), nil
+}
 
-	if l.config.PhonePerDay > 0 {
-		key := fmt.Sprintf("sms:limiter:phone:day:%s:%s", req.Phone, now.Format("20060102"))
-		pipe.Incr(ctx, key)
-		pipe.Expire(ctx, key, 24*time.Hour)
+// parseLimitResult 解析限流脚本返回的 {序号, 当前次数, 等待毫秒数}
+func parseLimitResult(rules []*limitRule, result []int64) *RateLimitError {
+	if len(result) != 3 || result[0] <= 0 || int(result[0]) > len(rules) {
+		return nil
 	}
 
-	// 设备计数器
-	if req.DeviceID != "" && l.config.DevicePerDay > 0 {
-		key := fmt.Sprintf("sms:limiter:device:day:%s:%s", req.DeviceID, now.Format("20060102"))
-		pipe.Incr(ctx, key)
-		pipe.Expire(ctx, key, 24*time.Hour)
+	rule := rules[result[0]-1]
+	return &RateLimitError{
+		Dimension:  rule.dimension,
+		Period:     rule.period,
+		Limit:      rule.limit,
+		Count:      int(result[1]),
+		RetryAfter: time.Duration(result[2]) * time.Millisecond,
 	}
+}
 
-	// IP计数器
-	if req.IP != "" && l.config.IPPerDay > 0 {
-		key := fmt.Sprintf("sms:limiter:ip:day:%s:%s", req.IP, now.Format("20060102"))
-		pipe.Incr(ctx, key)
-		pipe.Expire(ctx, key, 24*time.Hour)
+// boolArg 转换为脚本参数
+func boolArg(b bool) string {
+	if b {
+		return "1"
 	}
-
-	_, err := pipe.Exec(ctx)
-	return err
+	return "0"
 }
 
-// GetPhoneCount 获取手机号当前计数（用于调试）
+// GetPhoneCount 获取手机号当前计数（不区分业务的计数，用于调试）
 func (l *RateLimiter) GetPhoneCount(ctx context.Context, phone string, _type string) (int, error) {
-	now := time.Now()
-	var key string
+	return l.GetBizPhoneCount(ctx, "", phone, _type)
+}
 
+// GetBizPhoneCount 获取手机号在指定业务下的当前计数（用于调试）
+func (l *RateLimiter) GetBizPhoneCount(ctx context.Context, bizID, phone string, _type string) (int, error) {
+	var period limitPeriod
 	switch _type {
 	case "minute":
-		key = fmt.Sprintf("sms:limiter:phone:minute:%s:%s", phone, now.Format("200601021504"))
+		period = periodMinute
 	case "hour":
-		key = fmt.Sprintf("sms:limiter:phone:hour:%s:%s", phone, now.Format("2006010215"))
+		period = periodHour
 	case "day":
-		key = fmt.Sprintf("sms:limiter:phone:day:%s:%s", phone, now.Format("20060102"))
+		period = periodDay
 	default:
 		return 0, fmt.Errorf("invalid period: %s", _type)
 	}
 
+	now := time.Now()
+	config := l.configFor(bizID)
+	key := limiterKey(config.Mode, bizID, DimensionPhone, period, normalizePhone(phone, ""), now)
+
+	if config.Mode == LimiterModeSliding {
+		min := strconv.FormatInt(now.Add(-period.window).UnixMilli(), 10)
+		count, err := l.redis.ZCount(ctx, key, "("+min, "+inf").Result()
+		return int(count), err
+	}
+
 	count, err := l.redis.Get(ctx, key).Int()
 	if err == redis.Nil {
 		return 0, nil
@@ -0,0 +1,262 @@
+package sms
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiterFixedWindow(t *testing.T) {
+	ctx := context.Background()
+	rdb, server := newTestRedis(t)
+	limiter := NewRateLimiter(rdb, &LimiterConfig{PhonePerDay: 2, DevicePerDay: 5})
+	req := &SendRequest{Phone: "13800138000", DeviceID: "device-1"}
+
+	require.NoError(t, limiter.CheckAndIncrement(ctx, req))
+	require.NoError(t, limiter.CheckAndIncrement(ctx, req))
+
+	// 达到限制后返回 RateLimitError
+	err := limiter.CheckAndIncrement(ctx, req)
+	assert.ErrorIs(t, err, ErrPhoneRateLimit)
+	var limitErr *RateLimitError
+	require.True(t, errors.As(err, &limitErr))
+	assert.Equal(t, DimensionPhone, limitErr.Dimension)
+	assert.Equal(t, "day", limitErr.Period)
+	assert.Equal(t, 2, limitErr.Limit)
+	assert.Equal(t, 2, limitErr.Count)
+	assert.Greater(t, limitErr.RetryAfter, time.Duration(0))
+
+	wait, err := limiter.Cooldown(ctx, req)
+	require.NoError(t, err)
+	assert.InDelta(t, limitErr.RetryAfter.Seconds(), wait.Seconds(), 1)
+
+	// 超限时不增加任何维度的计数
+	count, err := limiter.GetPhoneCount(ctx, "13800138000", "day")
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+	deviceKey := limiterKey(LimiterModeFixed, "", DimensionDevice, periodDay, "device-1", time.Now())
+	deviceCount, err := rdb.Get(ctx, deviceKey).Int()
+	require.NoError(t, err)
+	assert.Equal(t, 2, deviceCount)
+
+	// 计数器过期后重置
+	server.FastForward(24 * time.Hour)
+	wait, err = limiter.Cooldown(ctx, req)
+	require.NoError(t, err)
+	assert.Zero(t, wait)
+	require.NoError(t, limiter.CheckAndIncrement(ctx, req))
+}
+
+func TestRateLimiterConcurrent(t *testing.T) {
+	ctx := context.Background()
+	rdb, _ := newTestRedis(t)
+	limiter := NewRateLimiter(rdb, &LimiterConfig{PhonePerDay: 5})
+	req := &SendRequest{Phone: "13800138000"}
+
+	// 并发请求不会同时通过检查
+	var passed int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if limiter.CheckAndIncrement(ctx, req) == nil {
+				atomic.AddInt32(&passed, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.EqualValues(t, 5, passed)
+}
+
+func TestRateLimiterSlidingWindow(t *testing.T) {
+	ctx := context.Background()
+	rdb, _ := newTestRedis(t)
+	limiter := NewRateLimiter(rdb, &LimiterConfig{Mode: LimiterModeSliding, PhonePerMinute: 2})
+	req := &SendRequest{Phone: "13800138000"}
+	key := limiterKey(LimiterModeSliding, "", DimensionPhone, periodMinute, "+8613800138000", time.Now())
+
+	// 窗口外的记录在检查时被清除
+	old := float64(time.Now().Add(-61 * time.Second).UnixMilli())
+	require.NoError(t, rdb.ZAdd(ctx, key, redis.Z{Score: old, Member: "a"}, redis.Z{Score: old, Member: "b"}).Err())
+	require.NoError(t, limiter.CheckAndIncrement(ctx, req))
+	require.NoError(t, limiter.CheckAndIncrement(ctx, req))
+	assert.EqualValues(t, 2, rdb.ZCard(ctx, key).Val())
+
+	// 窗口内达到限制，等待时间为最早一条记录移出窗口所需的时间
+	err := limiter.CheckAndIncrement(ctx, req)
+	var limitErr *RateLimitError
+	require.True(t, errors.As(err, &limitErr))
+	assert.Equal(t, 2, limitErr.Count)
+	assert.Greater(t, limitErr.RetryAfter, 58*time.Second)
+	assert.LessOrEqual(t, limitErr.RetryAfter, time.Minute)
+
+	count, err := limiter.GetPhoneCount(ctx, "13800138000", "minute")
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	// 最早一条移出窗口后可以再发送一条
+	members := rdb.ZRange(ctx, key, 0, 0).Val()
+	require.Len(t, members, 1)
+	require.NoError(t, rdb.ZAdd(ctx, key, redis.Z{Score: old, Member: members[0]}).Err())
+	require.NoError(t, limiter.CheckAndIncrement(ctx, req))
+	assert.ErrorIs(t, limiter.CheckAndIncrement(ctx, req), ErrPhoneRateLimit)
+}
+
+func TestBucketEnd(t *testing.T) {
+	now := time.Date(2024, 12, 31, 23, 59, 30, 0, time.Local)
+	assert.Equal(t, time.Date(2025, 1, 1, 0, 0, 0, 0, time.Local), periodMinute.bucketEnd(now))
+	assert.Equal(t, time.Date(2025, 1, 1, 0, 0, 0, 0, time.Local), periodHour.bucketEnd(now))
+	assert.Equal(t, time.Date(2025, 1, 1, 0, 0, 0, 0, time.Local), periodDay.bucketEnd(now))
+	assert.Equal(t, 30*time.Second, periodMinute.bucketTTL(now))
+
+	// 非整点时区按当地时间分桶
+	india := time.FixedZone("IST", 5*3600+1800)
+	now = time.Date(2024, 6, 1, 10, 15, 0, 0, india)
+	assert.Equal(t, time.Date(2024, 6, 1, 11, 0, 0, 0, india), periodHour.bucketEnd(now))
+	assert.Equal(t, 45*time.Minute, periodHour.bucketTTL(now))
+}
+
+func TestRateLimiterRetryAfter(t *testing.T) {
+	ctx := context.Background()
+	rdb, _ := newTestRedis(t)
+	limiter := NewRateLimiter(rdb, &LimiterConfig{PhonePerDay: 1})
+	req := &SendRequest{Phone: "13800138000"}
+
+	require.NoError(t, limiter.CheckAndIncrement(ctx, req))
+
+	// 等待时间和计数器过期时间都到时间桶结束为止
+	now := time.Now()
+	remaining := periodDay.bucketEnd(now).Sub(now)
+	err := limiter.CheckAndIncrement(ctx, req)
+	var limitErr *RateLimitError
+	require.True(t, errors.As(err, &limitErr))
+	assert.InDelta(t, remaining.Seconds(), limitErr.RetryAfter.Seconds(), 1)
+
+	key := limiterKey(LimiterModeFixed, "", DimensionPhone, periodDay, "+8613800138000", now)
+	assert.InDelta(t, remaining.Seconds(), rdb.PTTL(ctx, key).Val().Seconds(), 1)
+}
+
+func TestClientCooldown(t *testing.T) {
+	ctx := context.Background()
+	rdb, _ := newTestRedis(t)
+	client := NewClient(&ClientConfig{
+		Redis:         rdb,
+		Provider:      &stubProvider{},
+		LimiterConfig: &LimiterConfig{PhonePerDay: 10, DevicePerDay: 1},
+	})
+
+	req := &SendRequest{Phone: "7911 123456", CountryCode: "+44", DeviceID: "device-1"}
+	require.NoError(t, client.limiter.CheckAndIncrement(ctx, req))
+
+	// 设备维度超限时，同一设备换手机号也需要等待
+	wait, err := client.CooldownFor(ctx, &SendRequest{Phone: "13800138000", DeviceID: "device-1"})
+	require.NoError(t, err)
+	assert.Greater(t, wait, time.Duration(0))
+
+	// 只按手机号和业务查询时不受设备维度影响
+	wait, err = client.Cooldown(ctx, "13800138000", "login")
+	require.NoError(t, err)
+	assert.Zero(t, wait)
+
+	// 按国家代码标准化手机号
+	count, err := client.GetPhoneCount(ctx, "+447911123456", "day")
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestClientCooldownPhone(t *testing.T) {
+	ctx := context.Background()
+	rdb, _ := newTestRedis(t)
+	client := NewClient(&ClientConfig{
+		Redis:         rdb,
+		Provider:      &stubProvider{},
+		LimiterConfig: &LimiterConfig{PhonePerMinute: 10, PhonePerDay: 10},
+	})
+	client.SetLimiterConfig("login", &LimiterConfig{PhonePerMinute: 1, PhonePerDay: 10})
+
+	_, err := client.Send(ctx, &SendRequest{Phone: "13800138000", Template: "SMS_123", BizID: "login"})
+	require.NoError(t, err)
+
+	// 业务维度超限：同一业务需要等待，其他业务不受影响
+	wait, err := client.Cooldown(ctx, "13800138000", "login")
+	require.NoError(t, err)
+	assert.Greater(t, wait, time.Duration(0))
+	assert.LessOrEqual(t, wait, time.Minute)
+
+	wait, err = client.Cooldown(ctx, "+86 138 0013 8000", "login")
+	require.NoError(t, err)
+	assert.Greater(t, wait, time.Duration(0))
+
+	wait, err = client.Cooldown(ctx, "13800138000", "notice")
+	require.NoError(t, err)
+	assert.Zero(t, wait)
+
+	wait, err = client.Cooldown(ctx, "13900139000", "login")
+	require.NoError(t, err)
+	assert.Zero(t, wait)
+}
+
+func TestRateLimiterBizConfig(t *testing.T) {
+	ctx := context.Background()
+	rdb, _ := newTestRedis(t)
+	limiter := NewRateLimiter(rdb, &LimiterConfig{PhonePerDay: 1})
+	limiter.SetBizConfig("notify", &LimiterConfig{PhonePerDay: 3})
+
+	// 业务专属配置优先于默认配置
+	notify := &SendRequest{Phone: "13800138000", BizID: "notify"}
+	for i := 0; i < 3; i++ {
+		require.NoError(t, limiter.CheckAndIncrement(ctx, notify))
+	}
+	assert.ErrorIs(t, limiter.CheckAndIncrement(ctx, notify), ErrPhoneRateLimit)
+
+	// 其他业务使用默认配置，且计数按业务隔离
+	login := &SendRequest{Phone: "13800138000", BizID: "login"}
+	require.NoError(t, limiter.CheckAndIncrement(ctx, login))
+	assert.ErrorIs(t, limiter.CheckAndIncrement(ctx, login), ErrPhoneRateLimit)
+
+	count, err := limiter.GetBizPhoneCount(ctx, "notify", "13800138000", "day")
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	// 删除专属配置后恢复默认配置
+	limiter.SetBizConfig("notify", nil)
+	assert.Same(t, limiter.config, limiter.configFor("notify"))
+	assert.ErrorIs(t, limiter.CheckAndIncrement(ctx, notify), ErrPhoneRateLimit)
+}
+
+func TestRateLimiterUserDimension(t *testing.T) {
+	ctx := context.Background()
+	for _, mode := range []LimiterMode{LimiterModeFixed, LimiterModeSliding} {
+		t.Run(string(mode), func(t *testing.T) {
+			rdb, _ := newTestRedis(t)
+			limiter := NewRateLimiter(rdb, &LimiterConfig{Mode: mode, PhonePerDay: 5, DevicePerDay: 5, IPPerDay: 5, UserPerDay: 2})
+
+			// 更换手机号、设备、IP 后，同一账号仍然受限
+			require.NoError(t, limiter.CheckAndIncrement(ctx, &SendRequest{Phone: "13800138000", DeviceID: "d1", IP: "10.0.0.1", UserID: "u1"}))
+			require.NoError(t, limiter.CheckAndIncrement(ctx, &SendRequest{Phone: "13800138001", DeviceID: "d2", IP: "10.0.0.2", UserID: "u1"}))
+
+			err := limiter.CheckAndIncrement(ctx, &SendRequest{Phone: "13800138002", DeviceID: "d3", IP: "10.0.0.3", UserID: "u1"})
+			assert.ErrorIs(t, err, ErrUserRateLimit)
+			var limitErr *RateLimitError
+			require.True(t, errors.As(err, &limitErr))
+			assert.Equal(t, DimensionUser, limitErr.Dimension)
+
+			// 账号超限时其他维度不计数
+			count, err := limiter.GetPhoneCount(ctx, "13800138002", "day")
+			require.NoError(t, err)
+			assert.Zero(t, count)
+
+			// 其他账号、未登录的请求不受影响
+			require.NoError(t, limiter.CheckAndIncrement(ctx, &SendRequest{Phone: "13800138002", UserID: "u2"}))
+			require.NoError(t, limiter.CheckAndIncrement(ctx, &SendRequest{Phone: "13800138003"}))
+		})
+	}
+}
@@ -5,6 +5,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
+	"strconv"
+	"strings"
 	"time"
 
 	g_json "github.com/gpencil/go-common/json"
@@ -18,10 +21,8 @@ import (
 
 // AliyunProvider 阿里云短信服务商
 type AliyunProvider struct {
-	client     *dysmsapi.Client
-	redis      *redis.Client
-	codeExpiry time.Duration
-	signName   string // 签名名称
+	client   *dysmsapi.Client
+	signName string // 签名名称
 }
 
 // AliyunConfig 阿里云配置
@@ -30,17 +31,30 @@ type AliyunConfig struct {
 	AccessKeySecret string // AccessKey Secret
 	SignName        string // 签名名称
 	Endpoint        string
-	CodeExpiry      time.Duration // 验证码过期时间，默认 5 分钟
+	Protocol        string        // 请求协议：HTTPS（默认）或 HTTP
+	ConnectTimeout  time.Duration // 连接超时时间，默认 5 秒
+	ReadTimeout     time.Duration // 读取超时时间，默认 10 秒
+	CodeExpiry      time.Duration // 已废弃：验证码有效期由 ClientConfig.CodeConfig 配置
 }
 
 // NewAliyunProvider 创建阿里云短信服务商
+// redis 参数已不再使用（验证码由 Client 的 CodeManager 管理），保留用于兼容
 func NewAliyunProvider(redis *redis.Client, config *AliyunConfig) (*AliyunProvider, error) {
-	if config.CodeExpiry == 0 {
-		config.CodeExpiry = 5 * time.Minute
-	}
+	// 复制配置，填充默认值不影响调用方
+	cloned := *config
+	config = &cloned
 	if config.Endpoint == "" {
 		config.Endpoint = "dysmsapi.aliyuncs.com"
 	}
+	if config.Protocol == "" {
+		config.Protocol = "HTTPS"
+	}
+	if config.ConnectTimeout == 0 {
+		config.ConnectTimeout = 5 * time.Second
+	}
+	if config.ReadTimeout == 0 {
+		config.ReadTimeout = 10 * time.Second
+	}
 
 	if config.AccessKeyID == "" || config.AccessKeySecret == "" {
 		return nil, errors.New("AccessKey不能为空")
@@ -51,6 +65,9 @@ func NewAliyunProvider(redis *redis.Client, config *AliyunConfig) (*AliyunProvid
 		AccessKeyId:     tea.String(config.AccessKeyID),
 		AccessKeySecret: tea.String(config.AccessKeySecret),
 		Endpoint:        tea.String(config.Endpoint),
+		Protocol:        tea.String(config.Protocol),
+		ConnectTimeout:  tea.Int(int(config.ConnectTimeout.Milliseconds())),
+		ReadTimeout:     tea.Int(int(config.ReadTimeout.Milliseconds())),
 	}
 
 	// 创建客户端
@@ -60,10 +77,8 @@ func NewAliyunProvider(redis *redis.Client, config *AliyunConfig) (*AliyunProvid
 	}
 
 	return &AliyunProvider{
-		client:     client,
-		redis:      redis,
-		codeExpiry: config.CodeExpiry,
-		signName:   config.SignName,
+		client:   client,
+		signName: config.SignName,
 	}, nil
 }
 
@@ -103,8 +118,8 @@ func (p *AliyunProvider) Send(ctx context.Context, req *SendRequest) (*SendRespo
 		MaxAttempts: tea.Int(1),      // 只尝试一次
 	}
 
-	// 发送短信
-	response, err := p.client.SendSmsWithOptions(sendRequest, runtime)
+	// 发送短信（ctx 取消或超时会中断请求）
+	response, err := p.client.SendSmsWithContext(ctx, sendRequest, runtime)
 
 	if err != nil {
 		return nil, p.handleSendError(err)
@@ -125,13 +140,7 @@ func (p *AliyunProvider) Send(ctx context.Context, req *SendRequest) (*SendRespo
 			Success:   false,
 			ErrorCode: code,
 			ErrorMsg:  message,
-		}, NewSMSError(code, message, ShouldRetry(errType), nil)
-	}
-
-	// 如果发送的是验证码，存储到 Redis
-	if codeValue, ok := req.Params["code"]; ok {
-		key := getCodeKey(req.BizID, req.Phone)
-		_ = p.redis.Set(ctx, key, codeValue, p.codeExpiry).Err()
+		}, NewSMSError(code, message, ShouldRetry(errType), nil).WithType(errType)
 	}
 
 	// 返回成功响应
@@ -143,35 +152,93 @@ func (p *AliyunProvider) Send(ctx context.Context, req *SendRequest) (*SendRespo
 	}, nil
 }
 
-// Verify 验证短信验证码
-func (p *AliyunProvider) Verify(ctx context.Context, req *VerifyRequest) (*VerifyResponse, error) {
-	key := getCodeKey(req.BizID, req.Phone)
+// SendBatch 批量发送短信（SendBatchSms），所有请求必须使用同一模板和同一外部ID
+// 阿里云批量发送只返回一个 BizId，所有接收人的 MsgID 相同，状态报告按 MsgID + 手机号区分接收人
+func (p *AliyunProvider) SendBatch(ctx context.Context, reqs []*SendRequest) ([]*SendResponse, error) {
+	if len(reqs) == 0 {
+		return nil, nil
+	}
+	if len(reqs) > p.BatchSize() {
+		return nil, NewSMSError("PARAM_ERROR", fmt.Sprintf("批量发送最多 %d 个手机号", p.BatchSize()), false, nil)
+	}
+
+	var (
+		phones    = make([]string, len(reqs))
+		signNames = make([]string, len(reqs))
+		params    = make([]map[string]string, len(reqs))
+	)
+	for i, req := range reqs {
+		if req.Template != reqs[0].Template {
+			return nil, NewSMSError("PARAM_ERROR", "批量发送必须使用同一模板", false, nil)
+		}
+		if req.OutID != reqs[0].OutID {
+			return nil, NewSMSError("PARAM_ERROR", "批量发送必须使用同一外部ID", false, nil)
+		}
+		phones[i] = req.GetFullPhone()
+		signNames[i] = req.SignName
+		if signNames[i] == "" {
+			signNames[i] = p.signName
+		}
+		params[i] = req.Params
+		if params[i] == nil {
+			params[i] = map[string]string{}
+		}
+	}
 
-	storedCode, err := p.redis.Get(ctx, key).Result()
-	if err == redis.Nil {
-		return &VerifyResponse{
-			Success: false,
-			ErrMsg:  "验证码不存在",
-		}, nil
+	phonesJSON, err := g_json.Marshal(phones)
+	if err != nil {
+		return nil, NewSMSError("PARAM_ERROR", "手机号序列化失败", false, err)
 	}
+	signNamesJSON, err := g_json.Marshal(signNames)
 	if err != nil {
-		return nil, err
+		return nil, NewSMSError("PARAM_ERROR", "签名序列化失败", false, err)
+	}
+	paramsJSON, err := g_json.Marshal(params)
+	if err != nil {
+		return nil, NewSMSError("PARAM_ERROR", "模板参数序列化失败", false, err)
 	}
 
-	if storedCode != req.Code {
-		return &VerifyResponse{
-			Success: false,
-			ErrMsg:  "验证码错误",
-		}, nil
+	batchRequest := &dysmsapi.SendBatchSmsRequest{
+		PhoneNumberJson:   tea.String(string(phonesJSON)),
+		SignNameJson:      tea.String(string(signNamesJSON)),
+		TemplateCode:      tea.String(reqs[0].Template),
+		TemplateParamJson: tea.String(string(paramsJSON)),
+	}
+	if reqs[0].OutID != "" {
+		batchRequest.OutId = tea.String(reqs[0].OutID)
 	}
 
-	// 验证成功，删除验证码
-	p.redis.Del(ctx, key)
+	runtime := &util.RuntimeOptions{
+		Autoretry:   tea.Bool(false),
+		MaxAttempts: tea.Int(1),
+	}
+	response, err := p.client.SendBatchSmsWithContext(ctx, batchRequest, runtime)
+	if err != nil {
+		return nil, p.handleSendError(err)
+	}
+	if response.Body == nil {
+		return nil, NewSMSError("RESPONSE_ERROR", "响应体为空", true, nil)
+	}
 
-	return &VerifyResponse{
-		Success: true,
-		ErrMsg:  "",
-	}, nil
+	code := tea.StringValue(response.Body.Code)
+	if code != "OK" {
+		errType := p.getErrorType(code)
+		return nil, NewSMSError(code, tea.StringValue(response.Body.Message), ShouldRetry(errType), nil).WithType(errType)
+	}
+
+	responses := make([]*SendResponse, len(reqs))
+	for i := range responses {
+		responses[i] = &SendResponse{
+			MsgID:   tea.StringValue(response.Body.BizId),
+			Success: true,
+		}
+	}
+	return responses, nil
+}
+
+// BatchSize 阿里云单次批量发送最多 100 个手机号
+func (p *AliyunProvider) BatchSize() int {
+	return 100
 }
 
 // QueryStatus 查询短信发送状态（注意：阿里云需要手机号+BizId 才能查询）
@@ -185,72 +252,116 @@ func (p *AliyunProvider) QueryStatus(ctx context.Context, msgID string) (*Status
 	)
 }
 
-// QueryStatusByPhone 通过手机号查询短信状态
+// QueryStatusByPhone 通过手机号查询今天和昨天的短信状态
+// 阿里云的查询结果不含 BizId，MsgID 为发送时的外部ID（OutId）；按 BizId 查询请使用 QueryMessageStatus
 func (p *AliyunProvider) QueryStatusByPhone(ctx context.Context, phone string) ([]*StatusResponse, error) {
-	// 查询今天和昨天的短信记录（阿里云只支持查询最近30天的记录）
-	now := time.Now()
-	dates := []string{
-		now.Format("20060102"),                      // 今天
-		now.Add(-24 * time.Hour).Format("20060102"), // 昨天
+	now := time.Now().In(chinaTimezone)
+	var allResults []*StatusResponse
+	for _, day := range []time.Time{now, now.AddDate(0, 0, -1)} {
+		details, err := p.querySendDetails(ctx, phone, "", day)
+		if err != nil {
+			return nil, err
+		}
+		for _, detail := range details {
+			allResults = append(allResults, p.detailStatus(tea.StringValue(detail.OutId), detail))
+		}
+	}
+	return allResults, nil
+}
+
+// QueryMessageStatus 按手机号和 BizId 查询短信状态（实现 MessageStatusQuerier）
+// 批量发送的接收人共用 BizId，按手机号区分；sentAt 为零值时查询今天和昨天
+func (p *AliyunProvider) QueryMessageStatus(ctx context.Context, phone, msgID string, sentAt time.Time) (*StatusResponse, error) {
+	days := []time.Time{sentAt.In(chinaTimezone)}
+	if sentAt.IsZero() {
+		now := time.Now().In(chinaTimezone)
+		days = []time.Time{now, now.AddDate(0, 0, -1)}
 	}
+	for _, day := range days {
+		details, err := p.querySendDetails(ctx, phone, msgID, day)
+		if err != nil {
+			return nil, err
+		}
+		if len(details) > 0 {
+			return p.detailStatus(msgID, details[0]), nil
+		}
+	}
+	return nil, nil
+}
 
-	var allResults []*StatusResponse
+// querySendDetails 分页查询某天（北京时间）发送给手机号的短信明细，bizID 不为空时只查询该次发送
+func (p *AliyunProvider) querySendDetails(ctx context.Context, phone, bizID string, day time.Time) ([]*dysmsapi.QuerySendDetailsResponseBodySmsSendDetailDTOsSmsSendDetailDTO, error) {
+	const pageSize = 50 // 阿里云每页最多 50 条
 
-	for _, sendDate := range dates {
-		// 创建查询请求
+	var details []*dysmsapi.QuerySendDetailsResponseBodySmsSendDetailDTOsSmsSendDetailDTO
+	for page := int64(1); ; page++ {
 		queryRequest := &dysmsapi.QuerySendDetailsRequest{
-			PhoneNumber: tea.String(phone),
-			SendDate:    tea.String(sendDate),
-			PageSize:    tea.Int64(10),
-			CurrentPage: tea.Int64(1),
+			PhoneNumber: tea.String(aliyunQueryPhone(phone)),
+			SendDate:    tea.String(day.Format("20060102")),
+			PageSize:    tea.Int64(pageSize),
+			CurrentPage: tea.Int64(page),
+		}
+		if bizID != "" {
+			queryRequest.BizId = tea.String(bizID)
 		}
 
-		response, err := p.client.QuerySendDetails(queryRequest)
+		runtime := &util.RuntimeOptions{
+			Autoretry:   tea.Bool(false),
+			MaxAttempts: tea.Int(1),
+		}
+		response, err := p.client.QuerySendDetailsWithContext(ctx, queryRequest, runtime)
 		if err != nil {
 			return nil, p.handleQueryError(err)
 		}
-
-		// 检查响应
 		if response.Body == nil {
-			continue
+			return nil, NewSMSError("RESPONSE_ERROR", "响应体为空", true, nil)
+		}
+		if code := tea.StringValue(response.Body.Code); code != "OK" {
+			return nil, NewSMSError(code, tea.StringValue(response.Body.Message), false, nil)
 		}
 
-		code := tea.StringValue(response.Body.Code)
-		if code != "OK" {
-			// 如果查询失败，记录错误但继续查询下一个日期
-			continue
+		var items []*dysmsapi.QuerySendDetailsResponseBodySmsSendDetailDTOsSmsSendDetailDTO
+		if response.Body.SmsSendDetailDTOs != nil {
+			items = response.Body.SmsSendDetailDTOs.SmsSendDetailDTO
 		}
+		details = append(details, items...)
 
-		// 解析结果
-		if response.Body.SmsSendDetailDTOs != nil &&
-			response.Body.SmsSendDetailDTOs.SmsSendDetailDTO != nil {
-
-			for _, detail := range response.Body.SmsSendDetailDTOs.SmsSendDetailDTO {
-				status := &StatusResponse{
-					MsgID:       tea.StringValue(detail.OutId),
-					Phone:       tea.StringValue(detail.PhoneNum),
-					Status:      p.parseStatus(tea.Int64Value(detail.SendStatus)),
-					SentTime:    p.parseSendDate(tea.StringValue(detail.SendDate)),
-					ReceiveTime: p.parseReceiveDate(tea.StringValue(detail.ReceiveDate)),
-					ErrorMsg:    tea.StringValue(detail.ErrCode),
-				}
-				allResults = append(allResults, status)
-			}
+		total, _ := strconv.Atoi(tea.StringValue(response.Body.TotalCount))
+		if len(items) < pageSize || len(details) >= total {
+			return details, nil
 		}
 	}
+}
 
-	return allResults, nil
+// detailStatus 将发送明细转换为短信状态
+func (p *AliyunProvider) detailStatus(msgID string, detail *dysmsapi.QuerySendDetailsResponseBodySmsSendDetailDTOsSmsSendDetailDTO) *StatusResponse {
+	return &StatusResponse{
+		MsgID:       msgID,
+		Phone:       tea.StringValue(detail.PhoneNum),
+		Status:      p.parseStatus(tea.Int64Value(detail.SendStatus)),
+		SentTime:    p.parseSendDate(tea.StringValue(detail.SendDate)),
+		ReceiveTime: p.parseReceiveDate(tea.StringValue(detail.ReceiveDate)),
+		ErrorMsg:    tea.StringValue(detail.ErrCode),
+	}
 }
 
-// getCodeKey 获取验证码存储的key
-func getCodeKey(bizID, phone string) string {
-	return "sms:code:" + bizID + ":" + phone
+// aliyunQueryPhone 查询接口使用的手机号：国内号码为 11 位号码，国际号码为国际区号+号码（不带 +）
+func aliyunQueryPhone(phone string) string {
+	normalized := normalizePhone(phone, "")
+	if national, ok := strings.CutPrefix(normalized, "+86"); ok {
+		return national
+	}
+	return strings.TrimPrefix(normalized, "+")
 }
 
 // ========== 辅助方法 ==========
 
 // handleSendError 处理发送错误
 func (p *AliyunProvider) handleSendError(err error) error {
+	if ctxErr := p.handleTimeoutError(err); ctxErr != nil {
+		return ctxErr
+	}
+
 	if sdkErr, ok := err.(*tea.SDKError); ok {
 		code := tea.StringValue(sdkErr.Code)
 		message := tea.StringValue(sdkErr.Message)
@@ -268,7 +379,7 @@ func (p *AliyunProvider) handleSendError(err error) error {
 		}
 
 		errType := p.getErrorType(code)
-		return NewSMSError(code, message, ShouldRetry(errType), err)
+		return NewSMSError(code, message, ShouldRetry(errType), err).WithType(errType)
 	}
 
 	// 未知错误，可以重试
@@ -277,6 +388,10 @@ func (p *AliyunProvider) handleSendError(err error) error {
 
 // handleQueryError 处理查询错误
 func (p *AliyunProvider) handleQueryError(err error) error {
+	if ctxErr := p.handleTimeoutError(err); ctxErr != nil {
+		return ctxErr
+	}
+
 	if sdkErr, ok := err.(*tea.SDKError); ok {
 		code := tea.StringValue(sdkErr.Code)
 		message := tea.StringValue(sdkErr.Message)
@@ -285,6 +400,18 @@ func (p *AliyunProvider) handleQueryError(err error) error {
 	return NewSMSError("QUERY_ERROR", err.Error(), true, err)
 }
 
+// handleTimeoutError 处理超时和取消错误，其他错误返回 nil
+func (p *AliyunProvider) handleTimeoutError(err error) error {
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return NewSMSError("TIMEOUT", "请求阿里云超时", ShouldRetry(ErrorTypeTimeout), err).WithType(ErrorTypeTimeout)
+	}
+	if errors.Is(err, context.Canceled) {
+		return NewSMSError("CANCELED", "请求已取消", false, err)
+	}
+	return nil
+}
+
 // getErrorType 获取错误类型
 func (p *AliyunProvider) getErrorType(code string) ErrorType {
 	// 阿里云错误码映射
@@ -0,0 +1,130 @@
+package sms
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newAliyunStandIn 启动一个模拟阿里云 API 的本地服务，响应前等待 delay
+func newAliyunStandIn(t *testing.T, delay time.Duration, body string) *AliyunProvider {
+	return newAliyunStandInFunc(t, delay, func(r *http.Request) string { return body })
+}
+
+// newAliyunStandInFunc 启动一个模拟阿里云 API 的本地服务，按请求生成响应
+func newAliyunStandInFunc(t *testing.T, delay time.Duration, handler func(r *http.Request) string) *AliyunProvider {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(delay):
+		case <-done:
+			return
+		}
+		_ = r.ParseForm()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, handler(r))
+	}))
+	t.Cleanup(func() {
+		close(done)
+		server.Close()
+	})
+
+	provider, err := NewAliyunProvider(newOfflineRedis(), &AliyunConfig{
+		AccessKeyID:     "id",
+		AccessKeySecret: "secret",
+		SignName:        "测试签名",
+		Endpoint:        strings.TrimPrefix(server.URL, "http://"),
+		Protocol:        "HTTP",
+		ReadTimeout:     500 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	return provider
+}
+
+func TestAliyunProviderSend(t *testing.T) {
+	provider := newAliyunStandIn(t, 0, `{"Code":"OK","Message":"OK","BizId":"biz_123","RequestId":"req"}`)
+
+	resp, err := provider.Send(context.Background(), &SendRequest{
+		Phone:    "13800138000",
+		Template: "SMS_123456",
+		Params:   map[string]string{"code": "123456"},
+	})
+	require.NoError(t, err)
+	assert.True(t, resp.Success)
+	assert.Equal(t, "biz_123", resp.MsgID)
+}
+
+func TestAliyunProviderTimeout(t *testing.T) {
+	req := &SendRequest{Phone: "13800138000", Template: "SMS_123456"}
+	slow := `{"Code":"OK","Message":"OK","BizId":"biz_123"}`
+
+	// ctx 截止时间早于读取超时
+	provider := newAliyunStandIn(t, 5*time.Second, slow)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := provider.Send(ctx, req)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, ErrorTypeTimeout, ErrorTypeOf(err))
+	assert.False(t, IsRetryableError(err))
+
+	// 读取超时
+	start = time.Now()
+	_, err = provider.Send(context.Background(), req)
+	assert.Less(t, time.Since(start), 2*time.Second)
+	assert.Equal(t, ErrorTypeTimeout, ErrorTypeOf(err))
+
+	// 查询同样响应 ctx
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	_, err = provider.QueryStatusByPhone(ctx, "13800138000")
+	var smsErr *SMSError
+	require.True(t, errors.As(err, &smsErr))
+	assert.Equal(t, "CANCELED", smsErr.Code)
+}
+
+func TestAliyunProviderQueryMessageStatus(t *testing.T) {
+	var pages []string
+	provider := newAliyunStandInFunc(t, 0, func(r *http.Request) string {
+		assert.Equal(t, "QuerySendDetails", r.Header.Get("X-Acs-Action"))
+		assert.Equal(t, "13800138000", r.Form.Get("PhoneNumber"))
+		assert.Equal(t, "20241208", r.Form.Get("SendDate"))
+		pages = append(pages, r.Form.Get("CurrentPage"))
+		if r.Form.Get("BizId") != "" {
+			assert.Equal(t, "biz_123^0", r.Form.Get("BizId"))
+			return `{"Code":"OK","TotalCount":"1","SmsSendDetailDTOs":{"SmsSendDetailDTO":[
+				{"PhoneNum":"13800138000","SendStatus":3,"ErrCode":"DELIVERED","SendDate":"2024-12-08 10:30:00","ReceiveDate":"2024-12-08 10:30:05"}]}}`
+		}
+
+		// 不按 BizId 查询时分页返回 51 条
+		detail := `{"PhoneNum":"13800138000","SendStatus":3,"OutId":"out"}`
+		count := 50
+		if r.Form.Get("CurrentPage") == "2" {
+			count = 1
+		}
+		return `{"Code":"OK","TotalCount":"51","SmsSendDetailDTOs":{"SmsSendDetailDTO":[` + strings.TrimSuffix(strings.Repeat(detail+",", count), ",") + `]}}`
+	})
+
+	sentAt := time.Date(2024, 12, 8, 10, 30, 0, 0, chinaTimezone)
+	status, err := provider.QueryMessageStatus(context.Background(), "+8613800138000", "biz_123^0", sentAt)
+	require.NoError(t, err)
+	require.NotNil(t, status)
+	assert.Equal(t, "biz_123^0", status.MsgID)
+	assert.Equal(t, StatusDelivered, status.Status)
+	assert.Equal(t, []string{"1"}, pages)
+
+	pages = nil
+	details, err := provider.querySendDetails(context.Background(), "13800138000", "", sentAt)
+	require.NoError(t, err)
+	assert.Len(t, details, 51)
+	assert.Equal(t, []string{"1", "2"}, pages)
+}
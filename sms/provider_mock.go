@@ -12,17 +12,14 @@ import (
 
 // MockProvider 模拟短信服务商（用于测试）
 type MockProvider struct {
-	redis       *redis.Client
-	codeExpiry  time.Duration // 验证码过期时间
-	successRate float64       // 成功率（用于模拟失败）
+	successRate float64 // 成功率（用于模拟失败）
 	mu          sync.RWMutex
 }
 
 // NewMockProvider 创建模拟短信服务商
+// redis 参数已不再使用（验证码由 Client 的 CodeManager 管理），保留用于兼容
 func NewMockProvider(redis *redis.Client) *MockProvider {
 	return &MockProvider{
-		redis:       redis,
-		codeExpiry:  5 * time.Minute,
 		successRate: 0.95, // 95%成功率
 	}
 }
@@ -34,16 +31,6 @@ func (p *MockProvider) Send(ctx context.Context, req *SendRequest) (*SendRespons
 		return nil, NewSMSError("NETWORK_ERROR", "网络错误", true, nil)
 	}
 
-	// 生成6位验证码
-	code := fmt.Sprintf("%06d", rand.Intn(1000000))
-
-	// 存储验证码到 Redis
-	key := fmt.Sprintf("sms:code:%s:%s", req.BizID, req.Phone)
-	err := p.redis.Set(ctx, key, code, p.codeExpiry).Err()
-	if err != nil {
-		return nil, err
-	}
-
 	msgID := fmt.Sprintf("mock_%d", time.Now().UnixNano())
 
 	return &SendResponse{
@@ -54,39 +41,6 @@ func (p *MockProvider) Send(ctx context.Context, req *SendRequest) (*SendRespons
 	}, nil
 }
 
-// Verify 验证短信验证码
-func (p *MockProvider) Verify(ctx context.Context, req *VerifyRequest) (*VerifyResponse, error) {
-	key := fmt.Sprintf("sms:code:%s:%s", req.BizID, req.Phone)
-
-	// 从 Redis 获取验证码
-	storedCode, err := p.redis.Get(ctx, key).Result()
-	if err == redis.Nil {
-		return &VerifyResponse{
-			Success: false,
-			ErrMsg:  "验证码不存在或已过期",
-		}, nil
-	}
-	if err != nil {
-		return nil, err
-	}
-
-	// 验证码匹配
-	if storedCode != req.Code {
-		return &VerifyResponse{
-			Success: false,
-			ErrMsg:  "验证码错误",
-		}, nil
-	}
-
-	// 验证成功，删除验证码
-	p.redis.Del(ctx, key)
-
-	return &VerifyResponse{
-		Success: true,
-		ErrMsg:  "",
-	}, nil
-}
-
 // QueryStatus 查询短信发送状态
 func (p *MockProvider) QueryStatus(ctx context.Context, msgID string) (*StatusResponse, error) {
 	// 模拟查询
@@ -0,0 +1,467 @@
+package sms
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	g_json "github.com/gpencil/go-common/json"
+)
+
+const (
+	tencentService    = "sms"
+	tencentAPIVersion = "2021-01-11"
+	tencentAlgorithm  = "TC3-HMAC-SHA256"
+
+	tencentPullPageSize = 100 // 拉取回执每页数量（接口上限）
+)
+
+// TencentProvider 腾讯云短信服务商
+type TencentProvider struct {
+	httpClient     *http.Client
+	secretID       string
+	secretKey      string
+	sdkAppID       string
+	signName       string // 签名名称
+	region         string
+	endpoint       string // 完整请求地址（含协议）
+	host           string
+	templateParams map[string][]string
+}
+
+// TencentConfig 腾讯云配置
+type TencentConfig struct {
+	SecretID  string        // SecretId
+	SecretKey string        // SecretKey
+	SdkAppID  string        // 短信应用 SdkAppId
+	SignName  string        // 签名名称
+	Region    string        // 地域，默认 ap-guangzhou
+	Endpoint  string        // 接入地址，默认 sms.tencentcloudapi.com，可带协议（如 http://127.0.0.1:8080）
+	Timeout   time.Duration // 单次请求超时时间，默认 10 秒
+
+	// TemplateParams 模板参数顺序：模板ID -> 参数名列表
+	// 腾讯云模板使用 {1}、{2} 顺序占位，需要将 Params 按此顺序转换为数组；
+	// 未配置的模板按参数名排序（数字参数名按数值排序）
+	TemplateParams map[string][]string
+}
+
+// NewTencentProvider 创建腾讯云短信服务商
+func NewTencentProvider(config *TencentConfig) (*TencentProvider, error) {
+	// 复制配置，填充默认值不影响调用方
+	cloned := *config
+	config = &cloned
+	if config.Region == "" {
+		config.Region = "ap-guangzhou"
+	}
+	if config.Endpoint == "" {
+		config.Endpoint = "sms.tencentcloudapi.com"
+	}
+	if config.Timeout == 0 {
+		config.Timeout = 10 * time.Second
+	}
+
+	if config.SecretID == "" || config.SecretKey == "" {
+		return nil, errors.New("SecretId/SecretKey不能为空")
+	}
+	if config.SdkAppID == "" {
+		return nil, errors.New("SdkAppId不能为空")
+	}
+
+	endpoint := config.Endpoint
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "https://" + endpoint
+	}
+	host := endpoint[strings.Index(endpoint, "://")+3:]
+	host = strings.TrimSuffix(host, "/")
+
+	return &TencentProvider{
+		httpClient:     &http.Client{Timeout: config.Timeout},
+		secretID:       config.SecretID,
+		secretKey:      config.SecretKey,
+		sdkAppID:       config.SdkAppID,
+		signName:       config.SignName,
+		region:         config.Region,
+		endpoint:       endpoint,
+		host:           host,
+		templateParams: config.TemplateParams,
+	}, nil
+}
+
+// ========== 腾讯云 API 数据结构 ==========
+
+// tencentError 腾讯云公共错误
+type tencentError struct {
+	Code    string `json:"Code"`
+	Message string `json:"Message"`
+}
+
+type tencentSendSmsRequest struct {
+	PhoneNumberSet   []string `json:"PhoneNumberSet"`
+	SmsSdkAppId      string   `json:"SmsSdkAppId"`
+	SignName         string   `json:"SignName,omitempty"`
+	TemplateId       string   `json:"TemplateId"`
+	TemplateParamSet []string `json:"TemplateParamSet,omitempty"`
+	SessionContext   string   `json:"SessionContext,omitempty"`
+}
+
+type tencentSendStatus struct {
+	SerialNo       string `json:"SerialNo"`
+	PhoneNumber    string `json:"PhoneNumber"`
+	Fee            int64  `json:"Fee"`
+	SessionContext string `json:"SessionContext"`
+	Code           string `json:"Code"`
+	Message        string `json:"Message"`
+	IsoCode        string `json:"IsoCode"`
+}
+
+type tencentSendSmsResponse struct {
+	Response struct {
+		Error         *tencentError        `json:"Error"`
+		SendStatusSet []*tencentSendStatus `json:"SendStatusSet"`
+		RequestId     string               `json:"RequestId"`
+	} `json:"Response"`
+}
+
+type tencentPullStatusRequest struct {
+	BeginTime   int64  `json:"BeginTime"`
+	EndTime     int64  `json:"EndTime"`
+	Offset      int64  `json:"Offset"`
+	Limit       int64  `json:"Limit"`
+	PhoneNumber string `json:"PhoneNumber"`
+	SmsSdkAppId string `json:"SmsSdkAppId"`
+}
+
+type tencentPullStatus struct {
+	UserReceiveTime  int64  `json:"UserReceiveTime"`
+	CountryCode      string `json:"CountryCode"`
+	SubscriberNumber string `json:"SubscriberNumber"`
+	PhoneNumber      string `json:"PhoneNumber"`
+	SerialNo         string `json:"SerialNo"`
+	ReportStatus     string `json:"ReportStatus"`
+	Description      string `json:"Description"`
+	SessionContext   string `json:"SessionContext"`
+}
+
+type tencentPullStatusResponse struct {
+	Response struct {
+		Error                *tencentError        `json:"Error"`
+		PullSmsSendStatusSet []*tencentPullStatus `json:"PullSmsSendStatusSet"`
+		RequestId            string               `json:"RequestId"`
+	} `json:"Response"`
+}
+
+// Send 发送短信
+func (p *TencentProvider) Send(ctx context.Context, req *SendRequest) (*SendResponse, error) {
+	// 签名
+	signName := req.SignName
+	if signName == "" {
+		signName = p.signName
+	}
+
+	sendRequest := &tencentSendSmsRequest{
+		PhoneNumberSet:   []string{req.GetFullPhone()},
+		SmsSdkAppId:      p.sdkAppID,
+		SignName:         signName,
+		TemplateId:       req.Template,
+		TemplateParamSet: p.templateParamSet(req.Template, req.Params),
+		SessionContext:   req.OutID,
+	}
+
+	var response tencentSendSmsResponse
+	if err := p.call(ctx, "SendSms", sendRequest, &response); err != nil {
+		return nil, err
+	}
+
+	if apiErr := response.Response.Error; apiErr != nil {
+		errType := p.getErrorType(apiErr.Code)
+		return nil, NewSMSError(apiErr.Code, apiErr.Message, ShouldRetry(errType), nil).WithType(errType)
+	}
+
+	if len(response.Response.SendStatusSet) == 0 {
+		return nil, NewSMSError("RESPONSE_ERROR", "响应体为空", true, nil)
+	}
+
+	status := response.Response.SendStatusSet[0]
+
+	// 如果发送失败
+	if status.Code != "Ok" {
+		errType := p.getErrorType(status.Code)
+		return &SendResponse{
+			MsgID:     status.SerialNo,
+			Success:   false,
+			ErrorCode: status.Code,
+			ErrorMsg:  status.Message,
+		}, NewSMSError(status.Code, status.Message, ShouldRetry(errType), nil).WithType(errType)
+	}
+
+	// 返回成功响应
+	return &SendResponse{
+		MsgID:     status.SerialNo,
+		Success:   true,
+		ErrorCode: "",
+		ErrorMsg:  "",
+	}, nil
+}
+
+// QueryStatus 查询短信发送状态（注意：腾讯云需要手机号才能拉取指定短信的回执）
+func (p *TencentProvider) QueryStatus(ctx context.Context, msgID string) (*StatusResponse, error) {
+	// PullSmsSendStatus 会消费全量回执，无法仅通过 msgID 查询
+	return nil, NewSMSError(
+		"NOT_SUPPORTED",
+		"腾讯云查询需要手机号，请使用 QueryStatusByPhone 方法",
+		false,
+		nil,
+	)
+}
+
+// QueryStatusByPhone 通过手机号拉取最近 24 小时的短信回执（分页获取全部结果）
+// phone 不含国家代码时默认按 +86 处理
+func (p *TencentProvider) QueryStatusByPhone(ctx context.Context, phone string) ([]*StatusResponse, error) {
+	now := time.Now()
+	pullRequest := &tencentPullStatusRequest{
+		BeginTime:   now.Add(-24 * time.Hour).Unix(),
+		EndTime:     now.Unix(),
+		Limit:       tencentPullPageSize,
+		PhoneNumber: normalizePhone(phone, ""),
+		SmsSdkAppId: p.sdkAppID,
+	}
+
+	var allResults []*StatusResponse
+	for {
+		var response tencentPullStatusResponse
+		if err := p.call(ctx, "PullSmsSendStatusByPhoneNumber", pullRequest, &response); err != nil {
+			return nil, err
+		}
+
+		if apiErr := response.Response.Error; apiErr != nil {
+			return nil, NewSMSError(apiErr.Code, apiErr.Message, false, nil)
+		}
+
+		for _, detail := range response.Response.PullSmsSendStatusSet {
+			status := &StatusResponse{
+				MsgID:       detail.SerialNo,
+				Phone:       detail.PhoneNumber,
+				Status:      p.parseStatus(detail.ReportStatus),
+				ReceiveTime: detail.UserReceiveTime,
+			}
+			if status.Status == StatusFailed {
+				status.ErrorMsg = detail.Description
+			}
+			allResults = append(allResults, status)
+		}
+
+		// 接口不返回总数，不足一页时结束
+		if len(response.Response.PullSmsSendStatusSet) < tencentPullPageSize {
+			return allResults, nil
+		}
+		pullRequest.Offset += tencentPullPageSize
+	}
+}
+
+// ========== 辅助方法 ==========
+
+// call 调用腾讯云 API（TC3-HMAC-SHA256 签名）
+func (p *TencentProvider) call(ctx context.Context, action string, request, response interface{}) error {
+	payload, err := g_json.Marshal(request)
+	if err != nil {
+		return NewSMSError("PARAM_ERROR", "请求参数序列化失败", false, err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return NewSMSError("PARAM_ERROR", "创建请求失败", false, err)
+	}
+
+	timestamp := time.Now().Unix()
+	httpReq.Host = p.host
+	httpReq.Header.Set("Content-Type", "application/json; charset=utf-8")
+	httpReq.Header.Set("Authorization", p.sign(payload, timestamp))
+	httpReq.Header.Set("X-TC-Action", action)
+	httpReq.Header.Set("X-TC-Timestamp", strconv.FormatInt(timestamp, 10))
+	httpReq.Header.Set("X-TC-Version", tencentAPIVersion)
+	httpReq.Header.Set("X-TC-Region", p.region)
+
+	httpResp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return p.handleRequestError(err)
+	}
+	defer httpResp.Body.Close()
+
+	body, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return p.handleRequestError(err)
+	}
+
+	if httpResp.StatusCode != http.StatusOK {
+		return NewSMSError(
+			"HTTP_"+strconv.Itoa(httpResp.StatusCode),
+			fmt.Sprintf("腾讯云接口返回异常状态码: %d", httpResp.StatusCode),
+			httpResp.StatusCode >= http.StatusInternalServerError,
+			nil,
+		)
+	}
+
+	if err := g_json.UnMarshal(body, response); err != nil {
+		return NewSMSError("RESPONSE_ERROR", "响应解析失败", true, err)
+	}
+
+	return nil
+}
+
+// sign 生成 TC3-HMAC-SHA256 Authorization 头
+func (p *TencentProvider) sign(payload []byte, timestamp int64) string {
+	date := time.Unix(timestamp, 0).UTC().Format("2006-01-02")
+
+	canonicalHeaders := "content-type:application/json; charset=utf-8\nhost:" + p.host + "\n"
+	signedHeaders := "content-type;host"
+	canonicalRequest := strings.Join([]string{
+		http.MethodPost,
+		"/",
+		"",
+		canonicalHeaders,
+		signedHeaders,
+		sha256Hex(payload),
+	}, "\n")
+
+	credentialScope := date + "/" + tencentService + "/tc3_request"
+	stringToSign := strings.Join([]string{
+		tencentAlgorithm,
+		strconv.FormatInt(timestamp, 10),
+		credentialScope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	secretDate := hmacSHA256([]byte("TC3"+p.secretKey), date)
+	secretService := hmacSHA256(secretDate, tencentService)
+	secretSigning := hmacSHA256(secretService, "tc3_request")
+	signature := hex.EncodeToString(hmacSHA256(secretSigning, stringToSign))
+
+	return fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		tencentAlgorithm, p.secretID, credentialScope, signedHeaders, signature)
+}
+
+// templateParamSet 将命名参数转换为腾讯云的顺序参数
+func (p *TencentProvider) templateParamSet(template string, params map[string]string) []string {
+	if len(params) == 0 {
+		return nil
+	}
+
+	keys, ok := p.templateParams[template]
+	if !ok {
+		keys = make([]string, 0, len(params))
+		for k := range params {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			a, errA := strconv.Atoi(keys[i])
+			b, errB := strconv.Atoi(keys[j])
+			if errA == nil && errB == nil {
+				return a < b
+			}
+			return keys[i] < keys[j]
+		})
+	}
+
+	values := make([]string, 0, len(keys))
+	for _, k := range keys {
+		values = append(values, params[k])
+	}
+	return values
+}
+
+// handleRequestError 处理网络请求错误
+func (p *TencentProvider) handleRequestError(err error) error {
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return NewSMSError("TIMEOUT", "请求腾讯云超时", ShouldRetry(ErrorTypeTimeout), err).WithType(ErrorTypeTimeout)
+	}
+	if errors.Is(err, context.Canceled) {
+		return NewSMSError("CANCELED", "请求已取消", false, err)
+	}
+
+	// 未知错误，可以重试
+	return NewSMSError("NETWORK_ERROR", err.Error(), true, err)
+}
+
+// getErrorType 获取错误类型
+func (p *TencentProvider) getErrorType(code string) ErrorType {
+	// 腾讯云错误码映射
+	errorMapping := map[string]ErrorType{
+		"LimitExceeded.PhoneNumberCountLimit":                             ErrorTypeFormat,       // 手机号数量超限
+		"LimitExceeded.PhoneNumberDailyLimit":                             ErrorTypeRateLimit,    // 单个手机号日下发条数超限
+		"LimitExceeded.PhoneNumberOneHourLimit":                           ErrorTypeRateLimit,    // 单个手机号1小时内下发条数超限
+		"LimitExceeded.PhoneNumberThirtySecondLimit":                      ErrorTypeRateLimit,    // 单个手机号30秒内下发条数超限
+		"LimitExceeded.PhoneNumberSameContentDailyLimit":                  ErrorTypeRateLimit,    // 单个手机号相同内容日下发超限
+		"LimitExceeded.DailyLimit":                                        ErrorTypeRateLimit,    // 日下发条数超限
+		"LimitExceeded.DeliveryFrequencyLimit":                            ErrorTypeRateLimit,    // 下发频率超限
+		"RequestLimitExceeded":                                            ErrorTypeRateLimit,    // 接口请求频率超限
+		"FailedOperation.InsufficientBalanceInSmsPackage":                 ErrorTypeBalance,      // 套餐包余量不足
+		"FailedOperation.PhoneNumberInBlacklist":                          ErrorTypeInvalidPhone, // 手机号在免打扰名单中
+		"FailedOperation.PhoneNumberParseFail":                            ErrorTypeInvalidPhone, // 手机号解析失败
+		"InvalidParameterValue.IncorrectPhoneNumber":                      ErrorTypeInvalidPhone, // 手机号格式错误
+		"UnsupportedOperation.UnsupportedRegion":                          ErrorTypeInvalidPhone, // 不支持该地区
+		"UnsupportedOperation.ContainDomesticAndInternationalPhoneNumber": ErrorTypeFormat,       // 国内国际号码混发
+		"FailedOperation.SignatureIncorrectOrUnapproved":                  ErrorTypeFormat,       // 签名未审批或格式错误
+		"FailedOperation.TemplateIncorrectOrUnapproved":                   ErrorTypeFormat,       // 模板未审批或不存在
+		"FailedOperation.ContainSensitiveWord":                            ErrorTypeFormat,       // 含敏感词
+		"FailedOperation.MissingSignature":                                ErrorTypeFormat,       // 缺少签名
+		"InvalidParameterValue.TemplateParameterFormatError":              ErrorTypeFormat,       // 模板参数格式错误
+		"InvalidParameterValue.TemplateParameterLengthLimit":              ErrorTypeFormat,       // 模板参数长度超限
+		"InvalidParameterValue.ProhibitedUseUrlInTemplateParameter":       ErrorTypeFormat,       // 模板参数含链接
+		"InvalidParameterValue.SdkAppIdNotExist":                          ErrorTypeFormat,       // SdkAppId 不存在
+		"UnauthorizedOperation.SmsSdkAppIdVerifyFail":                     ErrorTypeFormat,       // SdkAppId 校验失败
+		"AuthFailure.SignatureFailure":                                    ErrorTypeFormat,       // 签名校验失败
+		"AuthFailure.SecretIdNotFound":                                    ErrorTypeFormat,       // 密钥不存在
+		"UnauthorizedOperation.ServiceSuspendDueToArrears":                ErrorTypeBalance,      // 欠费停服
+		"FailedOperation.ServiceSuspendDueToArrears":                      ErrorTypeBalance,      // 欠费停服
+		"InternalError.Timeout":                                           ErrorTypeTimeout,      // 请求下发短信超时
+		"InternalError.RequestTimeException":                              ErrorTypeFormat,       // 请求时间戳与服务器相差过大
+		"InternalError.SendAndRecvFail":                                   ErrorTypeOther,        // 接口超时或收发包失败
+		"InternalError.OtherError":                                        ErrorTypeOther,        // 其他错误
+		"InternalError":                                                   ErrorTypeOther,        // 内部错误
+	}
+
+	if errType, ok := errorMapping[code]; ok {
+		return errType
+	}
+
+	// 默认为其他错误
+	return ErrorTypeOther
+}
+
+// parseStatus 解析短信状态
+// 腾讯云回执状态：SUCCESS-用户接收成功 FAIL-用户接收失败
+func (p *TencentProvider) parseStatus(status string) MessageStatus {
+	switch status {
+	case "SUCCESS":
+		return StatusDelivered
+	case "FAIL":
+		return StatusFailed
+	default:
+		return StatusUnknown
+	}
+}
+
+// sha256Hex 计算 SHA256 十六进制摘要
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// hmacSHA256 计算 HMAC-SHA256
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,174 @@
+package sms
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTencentStandIn 启动一个模拟腾讯云 API 的本地服务
+func newTencentStandIn(t *testing.T, handler func(action string, body map[string]interface{}) string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.True(t, strings.HasPrefix(r.Header.Get("Authorization"), "TC3-HMAC-SHA256 Credential=id/"))
+		assert.Equal(t, tencentAPIVersion, r.Header.Get("X-TC-Version"))
+
+		raw, _ := io.ReadAll(r.Body)
+		var body map[string]interface{}
+		_ = json.Unmarshal(raw, &body)
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, handler(r.Header.Get("X-TC-Action"), body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestTencentProvider(t *testing.T, endpoint string) *TencentProvider {
+	provider, err := NewTencentProvider(&TencentConfig{
+		SecretID:  "id",
+		SecretKey: "key",
+		SdkAppID:  "1400000000",
+		SignName:  "测试签名",
+		Endpoint:  endpoint,
+		Timeout:   time.Second,
+		TemplateParams: map[string][]string{
+			"1001": {"code", "minutes"},
+		},
+	})
+	require.NoError(t, err)
+	return provider
+}
+
+func TestTencentProviderSend(t *testing.T) {
+	server := newTencentStandIn(t, func(action string, body map[string]interface{}) string {
+		assert.Equal(t, "SendSms", action)
+		assert.Equal(t, []interface{}{"+8613800138000"}, body["PhoneNumberSet"])
+		assert.Equal(t, []interface{}{"123456", "5"}, body["TemplateParamSet"])
+		assert.Equal(t, "测试签名", body["SignName"])
+		return `{"Response":{"SendStatusSet":[{"SerialNo":"2019:123","PhoneNumber":"+8613800138000","Fee":1,"Code":"Ok","Message":"send success"}],"RequestId":"req"}}`
+	})
+	provider := newTestTencentProvider(t, server.URL)
+
+	resp, err := provider.Send(context.Background(), &SendRequest{
+		Phone:    "13800138000",
+		Template: "1001",
+		Params:   map[string]string{"minutes": "5", "code": "123456"},
+	})
+	require.NoError(t, err)
+	assert.True(t, resp.Success)
+	assert.Equal(t, "2019:123", resp.MsgID)
+}
+
+func TestTencentProviderSendErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		response  string
+		code      string
+		retryable bool
+	}{
+		{
+			name:      "余额不足",
+			response:  `{"Response":{"SendStatusSet":[{"SerialNo":"","Code":"FailedOperation.InsufficientBalanceInSmsPackage","Message":"insufficient"}]}}`,
+			code:      "FailedOperation.InsufficientBalanceInSmsPackage",
+			retryable: false,
+		},
+		{
+			name:      "手机号格式错误",
+			response:  `{"Response":{"Error":{"Code":"InvalidParameterValue.IncorrectPhoneNumber","Message":"bad phone"}}}`,
+			code:      "InvalidParameterValue.IncorrectPhoneNumber",
+			retryable: false,
+		},
+		{
+			name:      "收发包失败",
+			response:  `{"Response":{"Error":{"Code":"InternalError.SendAndRecvFail","Message":"recv fail"}}}`,
+			code:      "InternalError.SendAndRecvFail",
+			retryable: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTencentStandIn(t, func(string, map[string]interface{}) string {
+				return tt.response
+			})
+			provider := newTestTencentProvider(t, server.URL)
+
+			_, err := provider.Send(context.Background(), &SendRequest{Phone: "13800138000", Template: "1001"})
+			var smsErr *SMSError
+			require.True(t, errors.As(err, &smsErr))
+			assert.Equal(t, tt.code, smsErr.Code)
+			assert.Equal(t, tt.retryable, IsRetryableError(err))
+		})
+	}
+}
+
+func TestTencentProviderQueryStatusByPhone(t *testing.T) {
+	server := newTencentStandIn(t, func(action string, body map[string]interface{}) string {
+		assert.Equal(t, "PullSmsSendStatusByPhoneNumber", action)
+		assert.Equal(t, "+8613800138000", body["PhoneNumber"])
+		return `{"Response":{"PullSmsSendStatusSet":[
+			{"UserReceiveTime":1700000000,"PhoneNumber":"+8613800138000","SerialNo":"a","ReportStatus":"SUCCESS"},
+			{"UserReceiveTime":1700000001,"PhoneNumber":"+8613800138000","SerialNo":"b","ReportStatus":"FAIL","Description":"DELIVRD_FAIL"}
+		]}}`
+	})
+	provider := newTestTencentProvider(t, server.URL)
+
+	// 不同格式的手机号都按 E.164 查询
+	for _, phone := range []string{"13800138000", "+8613800138000", "+86 138 0013 8000"} {
+		statuses, err := provider.QueryStatusByPhone(context.Background(), phone)
+		require.NoError(t, err)
+		require.Len(t, statuses, 2)
+		assert.Equal(t, StatusDelivered, statuses[0].Status)
+		assert.Equal(t, StatusFailed, statuses[1].Status)
+		assert.Equal(t, "DELIVRD_FAIL", statuses[1].ErrorMsg)
+	}
+}
+
+func TestTencentProviderQueryStatusByPhonePaging(t *testing.T) {
+	var offsets []float64
+	server := newTencentStandIn(t, func(action string, body map[string]interface{}) string {
+		// 非中国号码保持原国家代码
+		assert.Equal(t, "+447911123456", body["PhoneNumber"])
+		offset := body["Offset"].(float64)
+		offsets = append(offsets, offset)
+
+		count := tencentPullPageSize
+		if offset > 0 {
+			count = 1
+		}
+		item := `{"PhoneNumber":"+447911123456","SerialNo":"s","ReportStatus":"SUCCESS"}`
+		return `{"Response":{"PullSmsSendStatusSet":[` + strings.TrimSuffix(strings.Repeat(item+",", count), ",") + `]}}`
+	})
+	provider := newTestTencentProvider(t, server.URL)
+
+	statuses, err := provider.QueryStatusByPhone(context.Background(), "+447911123456")
+	require.NoError(t, err)
+	assert.Len(t, statuses, tencentPullPageSize+1)
+	assert.Equal(t, []float64{0, tencentPullPageSize}, offsets)
+}
+
+func TestTencentProviderTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer server.Close()
+	provider := newTestTencentProvider(t, server.URL)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := provider.Send(ctx, &SendRequest{Phone: "13800138000", Template: "1001"})
+	var smsErr *SMSError
+	require.True(t, errors.As(err, &smsErr))
+	assert.Equal(t, "TIMEOUT", smsErr.Code)
+	assert.False(t, IsRetryableError(err))
+}
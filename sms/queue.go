@@ -0,0 +1,473 @@
+package sms
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrJobNotFound 异步发送任务不存在（未入队或结果已过期）
+var ErrJobNotFound = errors.New("短信发送任务不存在")
+
+// QueueConfig 异步发送队列配置
+type QueueConfig struct {
+	Stream        string        // 队列 Stream，默认 sms:queue
+	DeadStream    string        // 死信 Stream，默认 sms:queue:dead
+	Group         string        // 消费者组，默认 sms-workers
+	Consumer      string        // 消费者名称，默认 主机名-进程号（多实例部署时必须唯一）
+	Workers       int           // 每个实例的并发发送数，默认 5
+	MaxDeliveries int           // 最大投递次数，超过后进入死信队列，默认 3
+	ClaimIdle     time.Duration // 消息被领取后超过该时间未确认时重新投递（处理失败或实例崩溃），默认 1 分钟
+	BlockTimeout  time.Duration // 读取队列的阻塞时间，默认 5 秒
+	MaxLen        int64         // 队列最大长度（近似裁剪），默认 100000
+	ResultTTL     time.Duration // 任务结果保存时间，默认 24 小时
+
+	OnError func(err error) // 队列读写出错（可选）
+}
+
+// DefaultQueueConfig 默认异步发送队列配置
+func DefaultQueueConfig() *QueueConfig {
+	hostname, _ := os.Hostname()
+	return &QueueConfig{
+		Stream:        "sms:queue",
+		DeadStream:    "sms:queue:dead",
+		Group:         "sms-workers",
+		Consumer:      hostname + "-" + strconv.Itoa(os.Getpid()),
+		Workers:       5,
+		MaxDeliveries: 3,
+		ClaimIdle:     time.Minute,
+		BlockTimeout:  5 * time.Second,
+		MaxLen:        100000,
+		ResultTTL:     24 * time.Hour,
+	}
+}
+
+// JobStatus 异步发送任务状态
+type JobStatus string
+
+const (
+	JobQueued     JobStatus = "queued"     // 排队中
+	JobProcessing JobStatus = "processing" // 发送中
+	JobSucceeded  JobStatus = "succeeded"  // 发送成功
+	JobFailed     JobStatus = "failed"     // 发送失败（不可重试的错误，如限流、配额、免打扰）
+	JobRetrying   JobStatus = "retrying"   // 发送失败，等待重新投递
+	JobDead       JobStatus = "dead"       // 超过最大投递次数，已进入死信队列
+)
+
+// Job 异步发送任务
+type Job struct {
+	ID        string    // 任务ID（Enqueue 返回）
+	Status    JobStatus // 任务状态
+	Attempts  int       // 已尝试次数
+	MsgID     string    // 服务商消息ID（发送成功时填写）
+	Provider  string    // 实际发送的服务商名称
+	ErrorCode string    // 错误码
+	ErrorMsg  string    // 错误信息
+	UpdatedAt time.Time // 最后更新时间
+}
+
+// sendQueue 异步发送队列
+type sendQueue struct {
+	redis  *redis.Client
+	config *QueueConfig
+}
+
+// newSendQueue 创建异步发送队列
+func newSendQueue(redis *redis.Client, config *QueueConfig) *sendQueue {
+	if config == nil {
+		config = DefaultQueueConfig()
+	}
+	// 复制配置，填充默认值不影响调用方
+	cloned := *config
+	config = &cloned
+	defaults := DefaultQueueConfig()
+	if config.Stream == "" {
+		config.Stream = defaults.Stream
+	}
+	if config.DeadStream == "" {
+		config.DeadStream = config.Stream + ":dead"
+	}
+	if config.Group == "" {
+		config.Group = defaults.Group
+	}
+	if config.Consumer == "" {
+		config.Consumer = defaults.Consumer
+	}
+	if config.Workers <= 0 {
+		config.Workers = defaults.Workers
+	}
+	if config.MaxDeliveries <= 0 {
+		config.MaxDeliveries = defaults.MaxDeliveries
+	}
+	if config.ClaimIdle <= 0 {
+		config.ClaimIdle = defaults.ClaimIdle
+	}
+	if config.BlockTimeout <= 0 {
+		config.BlockTimeout = defaults.BlockTimeout
+	}
+	if config.MaxLen <= 0 {
+		config.MaxLen = defaults.MaxLen
+	}
+	if config.ResultTTL <= 0 {
+		config.ResultTTL = defaults.ResultTTL
+	}
+	return &sendQueue{
+		redis:  redis,
+		config: config,
+	}
+}
+
+// enqueue 写入发送请求，返回任务ID
+func (q *sendQueue) enqueue(ctx context.Context, req *SendRequest) (string, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+
+	id := newMessageID()
+	pipe := q.redis.TxPipeline()
+	q.setStatus(ctx, pipe, id, map[string]any{"status": string(JobQueued), "attempts": 0})
+	pipe.XAdd(ctx, &redis.XAddArgs{
+		Stream: q.config.Stream,
+		MaxLen: q.config.MaxLen,
+		Approx: true,
+		Values: map[string]any{"job": id, "request": data},
+	})
+	if _, err := pipe.Exec(ctx); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// job 查询任务状态
+func (q *sendQueue) job(ctx context.Context, id string) (*Job, error) {
+	values, err := q.redis.HGetAll(ctx, getJobKey(id)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return nil, ErrJobNotFound
+	}
+
+	attempts, _ := strconv.Atoi(values["attempts"])
+	updatedAt, _ := strconv.ParseInt(values["updated_at"], 10, 64)
+	return &Job{
+		ID:        id,
+		Status:    JobStatus(values["status"]),
+		Attempts:  attempts,
+		MsgID:     values["msg_id"],
+		Provider:  values["provider"],
+		ErrorCode: values["error_code"],
+		ErrorMsg:  values["error_msg"],
+		UpdatedAt: time.UnixMilli(updatedAt),
+	}, nil
+}
+
+// setStatus 更新任务状态
+func (q *sendQueue) setStatus(ctx context.Context, cmd redis.Cmdable, id string, values map[string]any) {
+	values["updated_at"] = time.Now().UnixMilli()
+	cmd.HSet(ctx, getJobKey(id), values)
+	cmd.Expire(ctx, getJobKey(id), q.config.ResultTTL)
+}
+
+// createGroup 创建消费者组（已存在时忽略）
+func (q *sendQueue) createGroup(ctx context.Context) error {
+	err := q.redis.XGroupCreateMkStream(ctx, q.config.Stream, q.config.Group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return err
+	}
+	return nil
+}
+
+// getJobKey 获取任务状态的key
+func getJobKey(id string) string {
+	return "sms:queue:job:" + id
+}
+
+// ========== 消费者 ==========
+
+// QueueWorker 异步发送消费者
+// 通过消费者组读取 Client.Enqueue 写入的请求，执行限流、配额检查和发送后确认；多个实例可以同时消费，每条消息只会被一个消费者处理
+type QueueWorker struct {
+	client *Client
+	queue  *sendQueue
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewQueueWorker 创建异步发送消费者，使用客户端的队列配置（ClientConfig.QueueConfig）
+func NewQueueWorker(client *Client) *QueueWorker {
+	return &QueueWorker{
+		client: client,
+		queue:  client.queue,
+	}
+}
+
+// Start 创建消费者组并启动消费，重复调用无效
+func (w *QueueWorker) Start(ctx context.Context) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.cancel != nil {
+		return nil
+	}
+	if err := w.queue.createGroup(ctx); err != nil {
+		return err
+	}
+
+	ctx, w.cancel = context.WithCancel(ctx)
+	w.done = make(chan struct{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < w.queue.config.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.consume(ctx)
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		w.reclaim(ctx)
+	}()
+
+	go func(done chan struct{}) {
+		wg.Wait()
+		close(done)
+	}(w.done)
+	return nil
+}
+
+// Stop 停止消费，等待正在发送的消息处理完成
+func (w *QueueWorker) Stop() {
+	w.mu.Lock()
+	cancel, done := w.cancel, w.done
+	w.cancel, w.done = nil, nil
+	w.mu.Unlock()
+
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+}
+
+// consume 读取新消息并处理
+func (w *QueueWorker) consume(ctx context.Context) {
+	config := w.queue.config
+	for ctx.Err() == nil {
+		streams, err := w.queue.redis.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    config.Group,
+			Consumer: config.Consumer,
+			Streams:  []string{config.Stream, ">"},
+			Count:    1,
+			Block:    config.BlockTimeout,
+		}).Result()
+		if err == redis.Nil || ctx.Err() != nil {
+			continue
+		}
+		if err != nil {
+			w.onError(err)
+			w.sleep(ctx, time.Second)
+			continue
+		}
+
+		for _, stream := range streams {
+			for _, message := range stream.Messages {
+				w.process(ctx, message, 1)
+			}
+		}
+	}
+}
+
+// reclaim 定期领取超时未确认的消息（处理失败或消费者崩溃）重新处理，超过最大投递次数的进入死信队列
+func (w *QueueWorker) reclaim(ctx context.Context) {
+	config := w.queue.config
+	ticker := time.NewTicker(config.ClaimIdle / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		pending, err := w.queue.redis.XPendingExt(ctx, &redis.XPendingExtArgs{
+			Stream: config.Stream,
+			Group:  config.Group,
+			Idle:   config.ClaimIdle,
+			Start:  "-",
+			End:    "+",
+			Count:  int64(config.Workers),
+		}).Result()
+		if err != nil {
+			w.onError(err)
+			continue
+		}
+
+		for _, entry := range pending {
+			// 领取成功才处理，其他消费者已领取时返回空
+			messages, err := w.queue.redis.XClaim(ctx, &redis.XClaimArgs{
+				Stream:   config.Stream,
+				Group:    config.Group,
+				Consumer: config.Consumer,
+				MinIdle:  config.ClaimIdle,
+				Messages: []string{entry.ID},
+			}).Result()
+			if err != nil {
+				w.onError(err)
+				continue
+			}
+			for _, message := range messages {
+				w.process(ctx, message, int(entry.RetryCount)+1)
+			}
+		}
+	}
+}
+
+// process 处理一条消息，deliveries 为包括本次在内的投递次数
+func (w *QueueWorker) process(ctx context.Context, message redis.XMessage, deliveries int) {
+	// 停止时让正在处理的消息完成
+	ctx = context.WithoutCancel(ctx)
+	config := w.queue.config
+
+	id, _ := message.Values["job"].(string)
+	data, _ := message.Values["request"].(string)
+	var req SendRequest
+	if err := json.Unmarshal([]byte(data), &req); err != nil {
+		w.deadLetter(ctx, message, id, deliveries, fmt.Errorf("解析发送请求失败: %w", err))
+		return
+	}
+
+	if deliveries > config.MaxDeliveries {
+		w.deadLetter(ctx, message, id, deliveries-1, errors.New("超过最大投递次数"))
+		return
+	}
+
+	// 以任务ID作为默认幂等键，避免发送成功但确认前崩溃时重复发送
+	if req.IdempotencyKey == "" {
+		req.IdempotencyKey = "queue:" + id
+	}
+
+	w.queue.setStatus(ctx, w.queue.redis, id, map[string]any{"status": string(JobProcessing), "attempts": deliveries})
+	resp, err := w.client.Send(ctx, &req)
+	if err == nil && (resp == nil || !resp.Success) {
+		err = responseError(resp)
+	}
+
+	values := map[string]any{"attempts": deliveries}
+	if resp != nil {
+		values["msg_id"] = resp.MsgID
+		values["provider"] = resp.Provider
+	}
+	if err == nil {
+		values["status"] = string(JobSucceeded)
+		values["error_code"] = ""
+		values["error_msg"] = ""
+		w.ack(ctx, message, id, values)
+		return
+	}
+
+	values["error_code"] = errorCode(err)
+	values["error_msg"] = err.Error()
+	switch {
+	case !w.retryable(err):
+		values["status"] = string(JobFailed)
+		w.ack(ctx, message, id, values)
+	case deliveries >= config.MaxDeliveries:
+		w.deadLetter(ctx, message, id, deliveries, err)
+	default:
+		// 不确认，ClaimIdle 后重新投递
+		values["status"] = string(JobRetrying)
+		w.queue.setStatus(ctx, w.queue.redis, id, values)
+	}
+}
+
+// ack 确认消息并更新任务状态
+func (w *QueueWorker) ack(ctx context.Context, message redis.XMessage, id string, values map[string]any) {
+	config := w.queue.config
+	pipe := w.queue.redis.TxPipeline()
+	w.queue.setStatus(ctx, pipe, id, values)
+	pipe.XAck(ctx, config.Stream, config.Group, message.ID)
+	pipe.XDel(ctx, config.Stream, message.ID)
+	if _, err := pipe.Exec(ctx); err != nil {
+		w.onError(err)
+	}
+}
+
+// deadLetter 将消息转入死信队列
+func (w *QueueWorker) deadLetter(ctx context.Context, message redis.XMessage, id string, deliveries int, cause error) {
+	config := w.queue.config
+	values := map[string]any{
+		"status":     string(JobDead),
+		"attempts":   deliveries,
+		"error_code": errorCode(cause),
+		"error_msg":  cause.Error(),
+	}
+
+	pipe := w.queue.redis.TxPipeline()
+	pipe.XAdd(ctx, &redis.XAddArgs{
+		Stream: config.DeadStream,
+		MaxLen: config.MaxLen,
+		Approx: true,
+		Values: map[string]any{
+			"job":        id,
+			"request":    message.Values["request"],
+			"message_id": message.ID,
+			"error":      cause.Error(),
+		},
+	})
+	w.queue.setStatus(ctx, pipe, id, values)
+	pipe.XAck(ctx, config.Stream, config.Group, message.ID)
+	pipe.XDel(ctx, config.Stream, message.ID)
+	if _, err := pipe.Exec(ctx); err != nil {
+		w.onError(err)
+	}
+}
+
+// retryable 判断失败的消息是否需要重新投递
+// 配额当天不会恢复，直接失败；相同幂等键处理中时稍后重试
+func (w *QueueWorker) retryable(err error) bool {
+	if errors.Is(err, ErrQuotaExceeded) {
+		return false
+	}
+	if errors.Is(err, ErrIdempotencyInProgress) {
+		return true
+	}
+	return IsRetryableError(err)
+}
+
+// sleep 等待一段时间，ctx 取消时提前返回
+func (w *QueueWorker) sleep(ctx context.Context, d time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
+
+// onError 报告队列错误
+func (w *QueueWorker) onError(err error) {
+	if w.queue.config.OnError != nil {
+		w.queue.config.OnError(err)
+	}
+}
+
+// errorCode 获取错误码
+func errorCode(err error) string {
+	var smsErr *SMSError
+	if errors.As(err, &smsErr) {
+		return smsErr.Code
+	}
+	return ""
+}
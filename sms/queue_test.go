@@ -0,0 +1,160 @@
+package sms
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestQueueClient 创建使用内存 Redis 的客户端，队列参数缩短以便测试重新投递
+func newTestQueueClient(t *testing.T, provider SMSProvider) (*Client, *redis.Client) {
+	t.Helper()
+	rdb, _ := newTestRedis(t)
+	client := NewClient(&ClientConfig{
+		Redis:         rdb,
+		Provider:      provider,
+		LimiterConfig: &LimiterConfig{PhonePerDay: 100},
+		QueueConfig: &QueueConfig{
+			Consumer:      "test",
+			Workers:       1,
+			MaxDeliveries: 2,
+			ClaimIdle:     100 * time.Millisecond,
+			BlockTimeout:  20 * time.Millisecond,
+		},
+	})
+	return client, rdb
+}
+
+// waitJob 等待任务进入指定状态
+func waitJob(t *testing.T, client *Client, id string, status JobStatus) *Job {
+	t.Helper()
+	var job *Job
+	require.Eventually(t, func() bool {
+		var err error
+		job, err = client.Job(context.Background(), id)
+		return err == nil && job.Status == status
+	}, 5*time.Second, 10*time.Millisecond)
+	return job
+}
+
+func TestQueueConfigDefaults(t *testing.T) {
+	config := &QueueConfig{Stream: "sms:queue:marketing"}
+	queue := newSendQueue(newOfflineRedis(), config)
+	assert.Equal(t, QueueConfig{Stream: "sms:queue:marketing"}, *config)
+	assert.Equal(t, "sms:queue:marketing:dead", queue.config.DeadStream)
+	assert.Equal(t, "sms-workers", queue.config.Group)
+	assert.NotEmpty(t, queue.config.Consumer)
+	assert.Equal(t, 3, queue.config.MaxDeliveries)
+}
+
+func TestQueueEnqueueConsume(t *testing.T) {
+	ctx := context.Background()
+	client, rdb := newTestQueueClient(t, &stubProvider{})
+
+	id, err := client.Enqueue(ctx, &SendRequest{Phone: "13800138000", Template: "SMS_123", BizID: "login"})
+	require.NoError(t, err)
+
+	job, err := client.Job(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, JobQueued, job.Status)
+	assert.Equal(t, int64(1), rdb.XLen(ctx, "sms:queue").Val())
+
+	_, err = client.Job(ctx, "missing")
+	assert.ErrorIs(t, err, ErrJobNotFound)
+
+	worker := NewQueueWorker(client)
+	require.NoError(t, worker.Start(ctx))
+	defer worker.Stop()
+
+	job = waitJob(t, client, id, JobSucceeded)
+	assert.Equal(t, 1, job.Attempts)
+	assert.Equal(t, "stub", job.MsgID)
+
+	// 确认后从队列删除
+	assert.Zero(t, rdb.XLen(ctx, "sms:queue").Val())
+	pending, err := rdb.XPending(ctx, "sms:queue", "sms-workers").Result()
+	require.NoError(t, err)
+	assert.Zero(t, pending.Count)
+}
+
+func TestQueueReclaimDeadLetter(t *testing.T) {
+	ctx := context.Background()
+	provider := &stubProvider{err: NewSMSError("ServiceUnavailable", "服务不可用", true, nil)}
+	client, rdb := newTestQueueClient(t, provider)
+
+	id, err := client.Enqueue(ctx, &SendRequest{Phone: "13800138000", Template: "SMS_123", BizID: "login"})
+	require.NoError(t, err)
+
+	worker := NewQueueWorker(client)
+	require.NoError(t, worker.Start(ctx))
+
+	// 第一次失败后不确认，ClaimIdle 后重新领取；达到最大投递次数后进入死信队列
+	job := waitJob(t, client, id, JobDead)
+	worker.Stop()
+
+	assert.Equal(t, 2, job.Attempts)
+	assert.Equal(t, "ServiceUnavailable", job.ErrorCode)
+	assert.Equal(t, 2, provider.calls)
+
+	assert.Zero(t, rdb.XLen(ctx, "sms:queue").Val())
+	dead, err := rdb.XRange(ctx, "sms:queue:dead", "-", "+").Result()
+	require.NoError(t, err)
+	require.Len(t, dead, 1)
+	assert.Equal(t, id, dead[0].Values["job"])
+}
+
+func TestQueueAmbiguousError(t *testing.T) {
+	ctx := context.Background()
+	provider := &stubProvider{err: NewSMSError("NETWORK_ERROR", "网络错误", true, nil)}
+	client, _ := newTestQueueClient(t, provider)
+
+	id, err := client.Enqueue(ctx, &SendRequest{Phone: "13800138000", Template: "SMS_123", BizID: "login"})
+	require.NoError(t, err)
+
+	worker := NewQueueWorker(client)
+	require.NoError(t, worker.Start(ctx))
+
+	// 网络错误时短信可能已发送：重新投递后不再调用服务商，任务失败
+	job := waitJob(t, client, id, JobFailed)
+	worker.Stop()
+
+	assert.Equal(t, 2, job.Attempts)
+	assert.Contains(t, job.ErrorMsg, ErrIdempotencyUnknown.Error())
+	assert.Equal(t, 1, provider.calls)
+}
+
+func TestQueueNonRetryable(t *testing.T) {
+	ctx := context.Background()
+	provider := &stubProvider{err: NewSMSError("isv.MOBILE_NUMBER_ILLEGAL", "号码格式错误", false, nil)}
+	client, rdb := newTestQueueClient(t, provider)
+
+	id, err := client.Enqueue(ctx, &SendRequest{Phone: "13800138000", Template: "SMS_123", BizID: "login"})
+	require.NoError(t, err)
+
+	worker := NewQueueWorker(client)
+	require.NoError(t, worker.Start(ctx))
+
+	// 不可重试的错误直接确认，不重新投递
+	job := waitJob(t, client, id, JobFailed)
+	time.Sleep(300 * time.Millisecond)
+	worker.Stop()
+
+	assert.Equal(t, 1, job.Attempts)
+	assert.Equal(t, 1, provider.calls)
+	assert.Zero(t, rdb.XLen(ctx, "sms:queue").Val())
+	assert.Zero(t, rdb.XLen(ctx, "sms:queue:dead").Val())
+}
+
+func TestQueueWorkerRetryable(t *testing.T) {
+	worker := &QueueWorker{}
+	assert.False(t, worker.retryable(ErrQuotaExceeded))
+	assert.False(t, worker.retryable(ErrPhoneSuppressed))
+	assert.False(t, worker.retryable(&RateLimitError{Dimension: DimensionPhone}))
+	assert.False(t, worker.retryable(NewSMSError("isv.MOBILE_NUMBER_ILLEGAL", "号码格式错误", false, nil)))
+	assert.True(t, worker.retryable(ErrIdempotencyInProgress))
+	assert.True(t, worker.retryable(NewSMSError("NETWORK_ERROR", "网络错误", true, nil)))
+}
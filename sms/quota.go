@@ -45,49 +45,23 @@ func (q *QuotaManager) CheckAndIncrement(ctx context.Context, bizID string) erro
 		}
 	}
 
-	// 检查配额
-	if err := q.checkQuota(ctx, bizID, quota.MaxPerDay); err != nil {
-		return err
-	}
-
-	// 增加计数
-	if err := q.incrementQuota(ctx, bizID); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-// checkQuota 检查配额
-func (q *QuotaManager) checkQuota(ctx context.Context, bizID string, maxPerDay int) error {
+	// 检查并增加计数（原子操作）
 	now := time.Now()
-	key := fmt.Sprintf("sms:quota:%s:%s", bizID, now.Format("20060102"))
-
-	count, err := q.redis.Get(ctx, key).Int()
-	if err != nil && err != redis.Nil {
+	violated, err := evalLimitScript(ctx, q.redis, []*limitRule{{
+		key:   fmt.Sprintf("sms:quota:%s:%s", bizID, now.Format("20060102")),
+		limit: quota.MaxPerDay,
+		ttl:   periodDay.bucketTTL(now),
+	}}, false)
+	if err != nil {
 		return err
 	}
-
-	if count >= maxPerDay {
+	if violated != nil {
 		return ErrQuotaExceeded
 	}
 
 	return nil
 }
 
-// incrementQuota 增加配额计数
-func (q *QuotaManager) incrementQuota(ctx context.Context, bizID string) error {
-	now := time.Now()
-	key := fmt.Sprintf("sms:quota:%s:%s", bizID, now.Format("20060102"))
-
-	pipe := q.redis.Pipeline()
-	pipe.Incr(ctx, key)
-	pipe.Expire(ctx, key, 24*time.Hour)
-
-	_, err := pipe.Exec(ctx)
-	return err
-}
-
 // GetQuota 获取当前配额使用情况
 func (q *QuotaManager) GetQuota(ctx context.Context, bizID string) (used int, max int, err error) {
 	quota, exists := q.quotas[bizID]
@@ -0,0 +1,36 @@
+package sms
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuotaManager(t *testing.T) {
+	ctx := context.Background()
+	rdb, _ := newTestRedis(t)
+	quota := NewQuotaManager(rdb)
+	quota.SetQuota("login", 2)
+
+	require.NoError(t, quota.CheckAndIncrement(ctx, "login"))
+	require.NoError(t, quota.CheckAndIncrement(ctx, "login"))
+	assert.ErrorIs(t, quota.CheckAndIncrement(ctx, "login"), ErrQuotaExceeded)
+
+	used, max, err := quota.GetQuota(ctx, "login")
+	require.NoError(t, err)
+	assert.Equal(t, 2, used)
+	assert.Equal(t, 2, max)
+
+	// 未配置的业务使用默认配额，各业务独立计数
+	used, max, err = quota.GetQuota(ctx, "register")
+	require.NoError(t, err)
+	assert.Zero(t, used)
+	assert.Equal(t, defaultMax, max)
+	require.NoError(t, quota.CheckAndIncrement(ctx, "register"))
+
+	// 重置后可以继续发送
+	require.NoError(t, quota.ResetQuota(ctx, "login"))
+	require.NoError(t, quota.CheckAndIncrement(ctx, "login"))
+}
@@ -0,0 +1,321 @@
+package sms
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxReceiptBodySize 状态报告请求体大小上限
+const maxReceiptBodySize = 1 << 20
+
+// chinaTimezone 服务商状态报告使用北京时间
+var chinaTimezone = time.FixedZone("CST", 8*60*60)
+
+// ReceiptParser 状态报告解析器，每个服务商一个实现
+type ReceiptParser interface {
+	// Parse 解析服务商推送的状态报告，转换为统一的 StatusResponse
+	Parse(r *http.Request, body []byte) ([]*StatusResponse, error)
+	// Reply 按服务商要求的格式响应推送，err 不为空时服务商会稍后重新推送
+	Reply(w http.ResponseWriter, err error)
+}
+
+// ReceiptVerifier 推送校验，服务商支持签名时由 ReceiptParser、InboundParser 实现，
+// 否则通过 SetVerifier 设置（如 CallbackToken）
+type ReceiptVerifier interface {
+	VerifyReceipt(r *http.Request, body []byte) error
+}
+
+// CallbackToken 回调地址令牌校验（实现 ReceiptVerifier）
+// 阿里云、腾讯云的推送不带签名：在控制台配置回调地址时加入随机令牌（如 https://example.com/sms/receipt?token=xxx），
+// 校验请求中的查询参数 token 或请求头 X-Callback-Token；令牌为空时拒绝所有请求
+type CallbackToken string
+
+// VerifyReceipt 校验推送请求携带的令牌（常量时间比较）
+func (t CallbackToken) VerifyReceipt(r *http.Request, body []byte) error {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		token = r.Header.Get("X-Callback-Token")
+	}
+	if t == "" || subtle.ConstantTimeCompare([]byte(token), []byte(t)) != 1 {
+		return ErrCallbackUnverified
+	}
+	return nil
+}
+
+// callbackVerifier 推送校验配置，未配置校验的推送默认拒绝
+type callbackVerifier struct {
+	verifier        ReceiptVerifier
+	allowUnverified bool
+}
+
+// SetVerifier 设置推送校验，优先于解析器实现的 ReceiptVerifier（需要在开始接收推送前设置）
+// 解析器没有实现 ReceiptVerifier 时（如阿里云、腾讯云的内置解析器）必须设置，否则拒绝所有推送
+func (v *callbackVerifier) SetVerifier(verifier ReceiptVerifier) {
+	v.verifier = verifier
+}
+
+// AllowUnverified 允许接收未校验的推送（需要在开始接收推送前设置）
+// 仅在回调地址已通过网络策略（如来源 IP 白名单）保护时使用
+func (v *callbackVerifier) AllowUnverified() {
+	v.allowUnverified = true
+}
+
+// verify 校验推送请求：优先使用设置的校验，其次使用解析器实现的 ReceiptVerifier
+func (v *callbackVerifier) verify(r *http.Request, body []byte, parser any) error {
+	if v.verifier != nil {
+		return v.verifier.VerifyReceipt(r, body)
+	}
+	if verifier, ok := parser.(ReceiptVerifier); ok {
+		return verifier.VerifyReceipt(r, body)
+	}
+	if v.allowUnverified {
+		return nil
+	}
+	return ErrCallbackUnverified
+}
+
+// ReceiptSink 状态报告接收方
+type ReceiptSink interface {
+	HandleReceipt(ctx context.Context, receipt *StatusResponse) error
+}
+
+// ReceiptFunc 状态报告回调函数
+type ReceiptFunc func(ctx context.Context, receipt *StatusResponse) error
+
+// HandleReceipt 实现 ReceiptSink
+func (f ReceiptFunc) HandleReceipt(ctx context.Context, receipt *StatusResponse) error {
+	return f(ctx, receipt)
+}
+
+// ReceiptHandler 状态报告推送接收（http.Handler）
+// 解析服务商推送的状态报告，校验通过后分发给注册的接收方；未配置校验（见 SetVerifier）时拒绝推送
+// 接收方返回错误时响应失败，服务商会重新推送整批状态报告，接收方需要保证幂等
+type ReceiptHandler struct {
+	callbackVerifier
+
+	provider string // 服务商名称（写入 StatusResponse.Provider）
+	parser   ReceiptParser
+
+	mu    sync.RWMutex
+	sinks []ReceiptSink
+}
+
+// NewReceiptHandler 创建状态报告推送接收
+func NewReceiptHandler(provider string, parser ReceiptParser, sinks ...ReceiptSink) *ReceiptHandler {
+	return &ReceiptHandler{
+		provider: provider,
+		parser:   parser,
+		sinks:    sinks,
+	}
+}
+
+// Register 注册状态报告接收方
+func (h *ReceiptHandler) Register(sink ReceiptSink) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.sinks = append(h.sinks, sink)
+}
+
+// OnReceipt 注册状态报告回调函数
+func (h *ReceiptHandler) OnReceipt(fn func(ctx context.Context, receipt *StatusResponse) error) {
+	h.Register(ReceiptFunc(fn))
+}
+
+// ServeHTTP 处理状态报告推送
+func (h *ReceiptHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, ok := readCallback(w, r, &h.callbackVerifier, h.parser, h.parser.Reply)
+	if !ok {
+		return
+	}
+
+	receipts, err := h.parser.Parse(r, body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	h.parser.Reply(w, h.dispatch(r.Context(), receipts))
+}
+
+// dispatch 将状态报告分发给所有接收方
+func (h *ReceiptHandler) dispatch(ctx context.Context, receipts []*StatusResponse) error {
+	h.mu.RLock()
+	sinks := h.sinks
+	h.mu.RUnlock()
+
+	var errs []error
+	for _, receipt := range receipts {
+		receipt.Provider = h.provider
+		for _, sink := range sinks {
+			if err := sink.HandleReceipt(ctx, receipt); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ========== 阿里云 ==========
+
+// AliyunReceiptParser 阿里云短信状态报告（SmsReport）解析
+// 阿里云 HTTP 推送不带签名，需要通过 SetVerifier 设置校验（如 CallbackToken），并建议限制来源 IP
+type AliyunReceiptParser struct{}
+
+// aliyunReceipt 阿里云状态报告
+type aliyunReceipt struct {
+	PhoneNumber string `json:"phone_number"`
+	SendTime    string `json:"send_time"`
+	ReportTime  string `json:"report_time"`
+	Success     bool   `json:"success"`
+	ErrCode     string `json:"err_code"`
+	ErrMsg      string `json:"err_msg"`
+	BizID       string `json:"biz_id"`
+	OutID       string `json:"out_id"`
+}
+
+// Parse 解析阿里云状态报告（JSON 数组）
+func (AliyunReceiptParser) Parse(r *http.Request, body []byte) ([]*StatusResponse, error) {
+	var reports []aliyunReceipt
+	if err := json.Unmarshal(body, &reports); err != nil {
+		return nil, fmt.Errorf("解析阿里云状态报告失败: %w", err)
+	}
+
+	receipts := make([]*StatusResponse, 0, len(reports))
+	for _, report := range reports {
+		receipt := &StatusResponse{
+			MsgID:       report.BizID,
+			Phone:       report.PhoneNumber,
+			Status:      StatusDelivered,
+			SentTime:    parseReceiptTime(report.SendTime),
+			ReceiveTime: parseReceiptTime(report.ReportTime),
+		}
+		if !report.Success {
+			receipt.Status = StatusFailed
+			receipt.ErrorMsg = report.ErrCode
+		}
+		receipts = append(receipts, receipt)
+	}
+	return receipts, nil
+}
+
+// Reply 响应阿里云推送
+func (AliyunReceiptParser) Reply(w http.ResponseWriter, err error) {
+	replyAliyun(w, err)
+}
+
+// ========== 腾讯云 ==========
+
+// TencentReceiptParser 腾讯云短信下发状态回调解析
+// 腾讯云回调不带签名，需要通过 SetVerifier 设置校验（如 CallbackToken），并建议限制来源 IP
+type TencentReceiptParser struct{}
+
+// tencentReceipt 腾讯云状态回调
+type tencentReceipt struct {
+	UserReceiveTime string `json:"user_receive_time"`
+	NationCode      string `json:"nationcode"`
+	Mobile          string `json:"mobile"`
+	ReportStatus    string `json:"report_status"` // SUCCESS / FAIL
+	ErrMsg          string `json:"errmsg"`
+	Description     string `json:"description"`
+	SID             string `json:"sid"`
+}
+
+// Parse 解析腾讯云状态回调（JSON 数组）
+func (TencentReceiptParser) Parse(r *http.Request, body []byte) ([]*StatusResponse, error) {
+	var reports []tencentReceipt
+	if err := json.Unmarshal(body, &reports); err != nil {
+		return nil, fmt.Errorf("解析腾讯云状态回调失败: %w", err)
+	}
+
+	receipts := make([]*StatusResponse, 0, len(reports))
+	for _, report := range reports {
+		receipt := &StatusResponse{
+			MsgID:       report.SID,
+			Phone:       tencentReceiptPhone(report),
+			Status:      StatusDelivered,
+			ReceiveTime: parseReceiptTime(report.UserReceiveTime),
+		}
+		if !strings.EqualFold(report.ReportStatus, "SUCCESS") {
+			receipt.Status = StatusFailed
+			receipt.ErrorMsg = report.ErrMsg
+		}
+		receipts = append(receipts, receipt)
+	}
+	return receipts, nil
+}
+
+// tencentReceiptPhone 腾讯云回调的手机号不含国家代码，按 nationcode 拼接为 E.164 格式
+func tencentReceiptPhone(report tencentReceipt) string {
+	if report.NationCode == "" {
+		return report.Mobile
+	}
+	return normalizePhone(report.Mobile, "+"+report.NationCode)
+}
+
+// Reply 响应腾讯云回调
+func (TencentReceiptParser) Reply(w http.ResponseWriter, err error) {
+	replyTencent(w, err)
+}
+
+// ========== 辅助函数 ==========
+
+// readCallback 读取服务商推送的请求体并校验，未通过校验时响应 401
+// 返回 false 时已写入响应
+func readCallback(w http.ResponseWriter, r *http.Request, verifier *callbackVerifier, parser any, reply func(http.ResponseWriter, error)) ([]byte, bool) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return nil, false
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxReceiptBodySize))
+	if err != nil {
+		reply(w, err)
+		return nil, false
+	}
+
+	if err := verifier.verify(r, body, parser); err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return nil, false
+	}
+	return body, true
+}
+
+// replyAliyun 响应阿里云推送，code 为 0 表示接收成功
+func replyAliyun(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		_, _ = io.WriteString(w, `{"code":1,"msg":"接收失败"}`)
+		return
+	}
+	_, _ = io.WriteString(w, `{"code":0,"msg":"成功"}`)
+}
+
+// replyTencent 响应腾讯云回调，result 为 0 表示接收成功
+func replyTencent(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		_, _ = io.WriteString(w, `{"result":1,"errmsg":"FAIL"}`)
+		return
+	}
+	_, _ = io.WriteString(w, `{"result":0,"errmsg":"OK"}`)
+}
+
+// parseReceiptTime 解析状态报告中的时间（北京时间，格式：2024-12-08 10:30:00）
+func parseReceiptTime(value string) int64 {
+	if value == "" {
+		return 0
+	}
+	t, err := time.ParseInLocation("2006-01-02 15:04:05", value, chinaTimezone)
+	if err != nil {
+		return 0
+	}
+	return t.Unix()
+}
@@ -0,0 +1,144 @@
+package sms
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tokenReceiptParser 校验请求头中令牌的阿里云格式解析器
+type tokenReceiptParser struct {
+	AliyunReceiptParser
+}
+
+func (tokenReceiptParser) VerifyReceipt(r *http.Request, body []byte) error {
+	if r.Header.Get("X-Token") != "secret" {
+		return errors.New("invalid token")
+	}
+	return nil
+}
+
+func TestReceiptHandler(t *testing.T) {
+	var received []*StatusResponse
+	handler := NewReceiptHandler("aliyun", AliyunReceiptParser{})
+	handler.SetVerifier(CallbackToken("t0ken"))
+	handler.OnReceipt(func(ctx context.Context, receipt *StatusResponse) error {
+		received = append(received, receipt)
+		return nil
+	})
+
+	body := `[
+		{"phone_number":"13800138000","send_time":"2024-12-08 10:30:00","report_time":"2024-12-08 10:30:05","success":true,"err_code":"DELIVERED","biz_id":"biz_1"},
+		{"phone_number":"13900139000","send_time":"2024-12-08 10:30:00","report_time":"2024-12-08 10:30:05","success":false,"err_code":"MK:0001","biz_id":"biz_2"}
+	]`
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/sms/receipt?token=t0ken", strings.NewReader(body)))
+
+	assert.JSONEq(t, `{"code":0,"msg":"成功"}`, w.Body.String())
+	require.Len(t, received, 2)
+	assert.Equal(t, &StatusResponse{
+		MsgID:       "biz_1",
+		Phone:       "13800138000",
+		Provider:    "aliyun",
+		Status:      StatusDelivered,
+		SentTime:    1733625000,
+		ReceiveTime: 1733625005,
+	}, received[0])
+	assert.Equal(t, StatusFailed, received[1].Status)
+	assert.Equal(t, "MK:0001", received[1].ErrorMsg)
+
+	// 接收方失败时响应失败，服务商会重新推送
+	handler.Register(ReceiptFunc(func(ctx context.Context, receipt *StatusResponse) error {
+		return errors.New("store unavailable")
+	}))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/sms/receipt?token=t0ken", strings.NewReader(body)))
+	assert.JSONEq(t, `{"code":1,"msg":"接收失败"}`, w.Body.String())
+
+	// 格式错误
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/sms/receipt?token=t0ken", strings.NewReader("{")))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestReceiptHandlerVerify(t *testing.T) {
+	calls := 0
+	handler := NewReceiptHandler("custom", tokenReceiptParser{}, ReceiptFunc(func(ctx context.Context, receipt *StatusResponse) error {
+		calls++
+		return nil
+	}))
+	body := `[{"phone_number":"13800138000","success":true,"biz_id":"biz_1"}]`
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/sms/receipt", strings.NewReader(body)))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, 0, calls)
+
+	req := httptest.NewRequest(http.MethodPost, "/sms/receipt", strings.NewReader(body))
+	req.Header.Set("X-Token", "secret")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 1, calls)
+}
+
+func TestReceiptHandlerUnverified(t *testing.T) {
+	calls := 0
+	sink := ReceiptFunc(func(ctx context.Context, receipt *StatusResponse) error {
+		calls++
+		return nil
+	})
+	body := `[{"phone_number":"13800138000","success":true,"biz_id":"biz_1"}]`
+	serve := func(handler *ReceiptHandler, target string, header string) int {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		if header != "" {
+			req.Header.Set("X-Callback-Token", header)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// 解析器不支持签名且未配置校验时拒绝推送
+	handler := NewReceiptHandler("aliyun", AliyunReceiptParser{}, sink)
+	assert.Equal(t, http.StatusUnauthorized, serve(handler, "/sms/receipt", ""))
+
+	// 令牌校验：查询参数或请求头
+	handler.SetVerifier(CallbackToken("t0ken"))
+	assert.Equal(t, http.StatusUnauthorized, serve(handler, "/sms/receipt?token=wrong", ""))
+	assert.Equal(t, http.StatusOK, serve(handler, "/sms/receipt?token=t0ken", ""))
+	assert.Equal(t, http.StatusOK, serve(handler, "/sms/receipt", "t0ken"))
+	assert.Equal(t, 2, calls)
+
+	// 空令牌拒绝所有请求
+	handler.SetVerifier(CallbackToken(""))
+	assert.Equal(t, http.StatusUnauthorized, serve(handler, "/sms/receipt?token=", ""))
+
+	// 显式允许未校验的推送
+	handler = NewReceiptHandler("tencent", TencentReceiptParser{}, sink)
+	handler.AllowUnverified()
+	assert.Equal(t, http.StatusOK, serve(handler, "/sms/receipt", ""))
+	assert.Equal(t, 3, calls)
+}
+
+func TestTencentReceiptParser(t *testing.T) {
+	body := `[
+		{"user_receive_time":"2024-12-08 10:30:05","nationcode":"86","mobile":"13800138000","report_status":"SUCCESS","errmsg":"DELIVRD","sid":"sid_1"},
+		{"user_receive_time":"2024-12-08 10:30:05","nationcode":"86","mobile":"13900139000","report_status":"FAIL","errmsg":"MK:0005","sid":"sid_2"}
+	]`
+	receipts, err := TencentReceiptParser{}.Parse(nil, []byte(body))
+	require.NoError(t, err)
+	require.Len(t, receipts, 2)
+	assert.Equal(t, "sid_1", receipts[0].MsgID)
+	assert.Equal(t, "+8613800138000", receipts[0].Phone)
+	assert.Equal(t, StatusDelivered, receipts[0].Status)
+	assert.Equal(t, int64(1733625005), receipts[0].ReceiveTime)
+	assert.Equal(t, StatusFailed, receipts[1].Status)
+	assert.Equal(t, "MK:0005", receipts[1].ErrorMsg)
+}
@@ -0,0 +1,286 @@
+package sms
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ReconcilerConfig 状态对账配置
+type ReconcilerConfig struct {
+	Interval    time.Duration // 扫描间隔，默认 1 分钟
+	MinAge      time.Duration // 发送后等待多久开始查询（留给状态报告推送），默认 1 分钟
+	MaxAge      time.Duration // 超过该时间仍未获取到最终状态时放弃，标记为 StatusUnknown，默认 72 小时
+	BatchSize   int           // 每次从存储读取的记录数，默认 100
+	Concurrency int           // 同时查询服务商的最大数量，默认 5
+
+	// Providers 按服务商名称（MessageRecord.Provider）查询状态（可选），找不到时使用默认服务商
+	Providers map[string]SMSProvider
+
+	OnDelivered func(ctx context.Context, record *MessageRecord) // 短信已送达（可选）
+	OnFailed    func(ctx context.Context, record *MessageRecord) // 短信发送失败（可选）
+	OnError     func(err error)                                  // 查询或更新出错（可选），出错的记录下次扫描时重试
+}
+
+// DefaultReconcilerConfig 默认状态对账配置
+func DefaultReconcilerConfig() *ReconcilerConfig {
+	return &ReconcilerConfig{
+		Interval:    time.Minute,
+		MinAge:      time.Minute,
+		MaxAge:      72 * time.Hour,
+		BatchSize:   100,
+		Concurrency: 5,
+	}
+}
+
+// Reconciler 状态对账
+// 定期扫描发送记录中等待回执（StatusPending）的短信，向服务商查询最终状态并写回存储，
+// 用于补偿丢失的状态报告推送
+type Reconciler struct {
+	store    MessageStore
+	provider SMSProvider
+	config   *ReconcilerConfig
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewReconciler 创建状态对账
+func NewReconciler(store MessageStore, provider SMSProvider, config *ReconcilerConfig) *Reconciler {
+	if config == nil {
+		config = DefaultReconcilerConfig()
+	}
+	// 复制配置，填充默认值不影响调用方
+	cloned := *config
+	config = &cloned
+	defaults := DefaultReconcilerConfig()
+	if config.Interval <= 0 {
+		config.Interval = defaults.Interval
+	}
+	if config.MinAge <= 0 {
+		config.MinAge = defaults.MinAge
+	}
+	if config.MaxAge <= 0 {
+		config.MaxAge = defaults.MaxAge
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaults.BatchSize
+	}
+	if config.Concurrency <= 0 {
+		config.Concurrency = defaults.Concurrency
+	}
+	return &Reconciler{
+		store:    store,
+		provider: provider,
+		config:   config,
+	}
+}
+
+// Start 启动后台对账，重复调用无效
+func (r *Reconciler) Start(ctx context.Context) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cancel != nil {
+		return
+	}
+
+	ctx, r.cancel = context.WithCancel(ctx)
+	r.done = make(chan struct{})
+	go r.run(ctx, r.done)
+}
+
+// Stop 停止后台对账，等待正在进行的对账完成
+func (r *Reconciler) Stop() {
+	r.mu.Lock()
+	cancel, done := r.cancel, r.done
+	r.cancel, r.done = nil, nil
+	r.mu.Unlock()
+
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+}
+
+// run 定期执行对账
+func (r *Reconciler) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(r.config.Interval)
+	defer ticker.Stop()
+	for {
+		r.Reconcile(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Reconcile 执行一次对账：放弃超过 MaxAge 的记录，查询其余等待回执记录的最终状态
+func (r *Reconciler) Reconcile(ctx context.Context) {
+	now := time.Now()
+	r.scan(ctx, time.Time{}, now.Add(-r.config.MaxAge), r.expire)
+	r.scan(ctx, now.Add(-r.config.MaxAge), now.Add(-r.config.MinAge), r.reconcile)
+}
+
+// scan 按发送时间倒序分批读取 [start, end) 内等待回执的记录
+// 以每批最后一条记录的发送时间和ID作为下一批的游标，同一毫秒的记录不会遗漏，处理过程中记录状态变化不影响分页
+func (r *Reconciler) scan(ctx context.Context, start, end time.Time, handle func(context.Context, []*MessageRecord)) {
+	var beforeID string
+	for ctx.Err() == nil {
+		records, err := r.store.Query(ctx, &MessageQuery{
+			Status:   []MessageStatus{StatusPending},
+			Start:    start,
+			End:      end,
+			BeforeID: beforeID,
+			Limit:    r.config.BatchSize,
+		})
+		if err != nil {
+			r.onError(err)
+			return
+		}
+		if len(records) == 0 {
+			return
+		}
+
+		last := records[len(records)-1]
+		handle(ctx, trackableRecords(records))
+
+		if len(records) < r.config.BatchSize {
+			return
+		}
+		end, beforeID = last.CreatedAt, last.ID
+	}
+}
+
+// expire 放弃超过 MaxAge 仍未获取到最终状态的记录
+func (r *Reconciler) expire(ctx context.Context, records []*MessageRecord) {
+	for _, record := range records {
+		err := r.store.UpdateStatus(ctx, &StatusResponse{
+			MsgID:    record.MsgID,
+			Phone:    record.Phone,
+			Provider: record.Provider,
+			Status:   StatusUnknown,
+		})
+		if err != nil {
+			r.onError(err)
+		}
+	}
+}
+
+// reconcile 按服务商和手机号分组并发查询状态（同一手机号的多条短信只查询一次）
+func (r *Reconciler) reconcile(ctx context.Context, records []*MessageRecord) {
+	type group struct {
+		provider string
+		phone    string
+	}
+	groups := make(map[group][]*MessageRecord)
+	var order []group
+	for _, record := range records {
+		key := group{provider: record.Provider, phone: record.Phone}
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], record)
+	}
+
+	var (
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, r.config.Concurrency)
+	)
+	for _, key := range order {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case sem <- struct{}{}:
+		}
+
+		wg.Add(1)
+		go func(key group, records []*MessageRecord) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			r.reconcileGroup(ctx, r.providerFor(key.provider), key.phone, records)
+		}(key, groups[key])
+	}
+	wg.Wait()
+}
+
+// reconcileGroup 查询一个手机号的短信状态，更新已有最终状态的记录
+// 服务商实现 MessageStatusQuerier 时逐条按消息ID查询，否则按手机号查询一次后按消息ID匹配
+func (r *Reconciler) reconcileGroup(ctx context.Context, provider SMSProvider, phone string, records []*MessageRecord) {
+	byMsgID := make(map[string]*StatusResponse, len(records))
+	if querier, ok := provider.(MessageStatusQuerier); ok {
+		for _, record := range records {
+			status, err := querier.QueryMessageStatus(ctx, phone, record.MsgID, record.CreatedAt)
+			if err != nil {
+				r.onError(err)
+				continue
+			}
+			if status != nil {
+				byMsgID[record.MsgID] = status
+			}
+		}
+	} else {
+		statuses, err := provider.QueryStatusByPhone(ctx, phone)
+		if err != nil {
+			r.onError(err)
+			return
+		}
+		for _, status := range statuses {
+			byMsgID[status.MsgID] = status
+		}
+	}
+
+	for _, record := range records {
+		status, ok := byMsgID[record.MsgID]
+		if !ok || (status.Status != StatusDelivered && status.Status != StatusFailed) {
+			continue
+		}
+		if status.Provider == "" {
+			status.Provider = record.Provider
+		}
+		if err := r.store.UpdateStatus(ctx, status); err != nil {
+			r.onError(err)
+			continue
+		}
+
+		applyStatus(record, status)
+		if status.Status == StatusDelivered && r.config.OnDelivered != nil {
+			r.config.OnDelivered(ctx, record)
+		}
+		if status.Status == StatusFailed && r.config.OnFailed != nil {
+			r.config.OnFailed(ctx, record)
+		}
+	}
+}
+
+// providerFor 获取记录对应的服务商
+func (r *Reconciler) providerFor(name string) SMSProvider {
+	if provider, ok := r.config.Providers[name]; ok {
+		return provider
+	}
+	return r.provider
+}
+
+// trackableRecords 过滤没有服务商消息ID的记录（无法查询和更新状态）
+func trackableRecords(records []*MessageRecord) []*MessageRecord {
+	trackable := records[:0:0]
+	for _, record := range records {
+		if record.MsgID != "" {
+			trackable = append(trackable, record)
+		}
+	}
+	return trackable
+}
+
+// onError 报告对账错误
+func (r *Reconciler) onError(err error) {
+	if r.config.OnError != nil {
+		r.config.OnError(err)
+	}
+}
@@ -0,0 +1,232 @@
+package sms
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryMessageStore 内存发送记录存储
+type memoryMessageStore struct {
+	mu      sync.Mutex
+	records []*MessageRecord
+}
+
+func (s *memoryMessageStore) Save(ctx context.Context, record *MessageRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if record.ID == "" {
+		record.ID = newMessageID()
+	}
+	copied := *record
+	s.records = append(s.records, &copied)
+	return nil
+}
+
+func (s *memoryMessageStore) UpdateStatus(ctx context.Context, status *StatusResponse) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	updated := false
+	for _, record := range s.records {
+		if record.MsgID != status.MsgID {
+			continue
+		}
+		if phone := statusPhone(status); phone != "" && normalizePhone(record.Phone, "") != phone {
+			continue
+		}
+		applyStatus(record, status)
+		updated = true
+	}
+	if !updated {
+		return ErrMessageNotFound
+	}
+	return nil
+}
+
+func (s *memoryMessageStore) Get(ctx context.Context, id string) (*MessageRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, record := range s.records {
+		if record.ID == id {
+			copied := *record
+			return &copied, nil
+		}
+	}
+	return nil, ErrMessageNotFound
+}
+
+func (s *memoryMessageStore) GetByMsgID(ctx context.Context, msgID string) (*MessageRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, record := range s.records {
+		if record.MsgID == msgID {
+			copied := *record
+			return &copied, nil
+		}
+	}
+	return nil, ErrMessageNotFound
+}
+
+func (s *memoryMessageStore) Query(ctx context.Context, query *MessageQuery) ([]*MessageRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var records []*MessageRecord
+	for _, record := range s.records {
+		if query.matches(record) {
+			copied := *record
+			records = append(records, &copied)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if !records[i].CreatedAt.Equal(records[j].CreatedAt) {
+			return records[i].CreatedAt.After(records[j].CreatedAt)
+		}
+		return records[i].ID > records[j].ID
+	})
+	if len(records) > query.limit() {
+		records = records[:query.limit()]
+	}
+	return records, nil
+}
+
+func TestReconciler(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	store := &memoryMessageStore{}
+
+	// MockProvider 查询时返回 mock_{phone}_1 已送达
+	for i, phone := range []string{"13800138000", "13800138001", "13800138002"} {
+		require.NoError(t, store.Save(ctx, &MessageRecord{
+			MsgID:     "mock_" + phone + "_1",
+			Phone:     phone,
+			Status:    StatusPending,
+			CreatedAt: now.Add(-time.Duration(i+2) * time.Minute),
+		}))
+	}
+	// 刚发送，等待状态报告
+	require.NoError(t, store.Save(ctx, &MessageRecord{MsgID: "mock_13900139000_1", Phone: "13900139000", Status: StatusPending, CreatedAt: now}))
+	// 超过最长对账时间
+	require.NoError(t, store.Save(ctx, &MessageRecord{MsgID: "old", Phone: "13900139001", Status: StatusPending, CreatedAt: now.Add(-73 * time.Hour)}))
+
+	var (
+		mu        sync.Mutex
+		delivered []string
+	)
+	reconciler := NewReconciler(store, NewMockProvider(newOfflineRedis()), &ReconcilerConfig{
+		BatchSize:   2,
+		Concurrency: 2,
+		OnDelivered: func(ctx context.Context, record *MessageRecord) {
+			mu.Lock()
+			defer mu.Unlock()
+			delivered = append(delivered, record.Phone)
+		},
+	})
+	reconciler.Reconcile(ctx)
+
+	assert.ElementsMatch(t, []string{"13800138000", "13800138001", "13800138002"}, delivered)
+
+	record, err := store.GetByMsgID(ctx, "mock_13900139000_1")
+	require.NoError(t, err)
+	assert.Equal(t, StatusPending, record.Status)
+
+	record, err = store.GetByMsgID(ctx, "old")
+	require.NoError(t, err)
+	assert.Equal(t, StatusUnknown, record.Status)
+
+	// Start/Stop
+	reconciler.Start(ctx)
+	reconciler.Stop()
+	reconciler.Stop()
+}
+
+func TestReconcilerSameMillisecond(t *testing.T) {
+	ctx := context.Background()
+	created := time.UnixMilli(time.Now().Add(-time.Hour).UnixMilli())
+	store := &memoryMessageStore{}
+
+	// 同一毫秒发送的记录多于一批
+	for i, phone := range []string{"13800138000", "13800138001", "13800138002", "13800138003", "13800138004"} {
+		require.NoError(t, store.Save(ctx, &MessageRecord{
+			ID:        "r" + strconv.Itoa(i),
+			MsgID:     "mock_" + phone + "_1",
+			Phone:     phone,
+			Status:    StatusPending,
+			CreatedAt: created,
+		}))
+	}
+
+	var delivered int
+	reconciler := NewReconciler(store, NewMockProvider(newOfflineRedis()), &ReconcilerConfig{
+		BatchSize:   2,
+		Concurrency: 1,
+		OnDelivered: func(ctx context.Context, record *MessageRecord) { delivered++ },
+	})
+	reconciler.Reconcile(ctx)
+	assert.Equal(t, 5, delivered)
+}
+
+func TestReconcilerSkipsWithoutMsgID(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryMessageStore{}
+	require.NoError(t, store.Save(ctx, &MessageRecord{Phone: "13800138000", Status: StatusPending, CreatedAt: time.Now().Add(-73 * time.Hour)}))
+	require.NoError(t, store.Save(ctx, &MessageRecord{Phone: "13800138001", Status: StatusPending, CreatedAt: time.Now().Add(-time.Hour)}))
+
+	var errs []error
+	provider := &stubProvider{}
+	config := &ReconcilerConfig{OnError: func(err error) { errs = append(errs, err) }}
+	reconciler := NewReconciler(store, provider, config)
+	// 填充默认值不影响调用方的配置
+	assert.Zero(t, config.Interval)
+	assert.Zero(t, config.BatchSize)
+	reconciler.Reconcile(ctx)
+	reconciler.Reconcile(ctx)
+
+	// 没有 MsgID 的记录既不查询也不更新，不会反复报告 ErrMessageNotFound
+	assert.Empty(t, errs)
+	assert.Zero(t, provider.queries)
+}
+
+func TestReconcilerAliyun(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryMessageStore{}
+
+	// 阿里云的状态查询结果不含 BizId，按 BizId 分别查询
+	created := time.Now().Add(-10 * time.Minute)
+	require.NoError(t, store.Save(ctx, &MessageRecord{MsgID: "biz_a^0", Phone: "+8613800138000", Provider: "aliyun", Status: StatusPending, CreatedAt: created}))
+	require.NoError(t, store.Save(ctx, &MessageRecord{MsgID: "biz_b^0", Phone: "+8613800138000", Provider: "aliyun", Status: StatusPending, CreatedAt: created.Add(time.Second)}))
+
+	provider := newAliyunStandInFunc(t, 0, func(r *http.Request) string {
+		switch r.Form.Get("BizId") {
+		case "biz_a^0":
+			return `{"Code":"OK","TotalCount":"1","SmsSendDetailDTOs":{"SmsSendDetailDTO":[
+				{"PhoneNum":"13800138000","SendStatus":3,"ErrCode":"DELIVERED","OutId":"out_a"}]}}`
+		case "biz_b^0":
+			return `{"Code":"OK","TotalCount":"1","SmsSendDetailDTOs":{"SmsSendDetailDTO":[
+				{"PhoneNum":"13800138000","SendStatus":2,"ErrCode":"MK:0001","OutId":"out_b"}]}}`
+		}
+		return `{"Code":"OK","TotalCount":"0","SmsSendDetailDTOs":{"SmsSendDetailDTO":[]}}`
+	})
+
+	var errs []error
+	reconciler := NewReconciler(store, NewRetryProvider(provider, nil), &ReconcilerConfig{
+		OnError: func(err error) { errs = append(errs, err) },
+	})
+	reconciler.Reconcile(ctx)
+	assert.Empty(t, errs)
+
+	record, err := store.GetByMsgID(ctx, "biz_a^0")
+	require.NoError(t, err)
+	assert.Equal(t, StatusDelivered, record.Status)
+
+	record, err = store.GetByMsgID(ctx, "biz_b^0")
+	require.NoError(t, err)
+	assert.Equal(t, StatusFailed, record.Status)
+	assert.Equal(t, "MK:0001", record.ErrorMsg)
+}
@@ -0,0 +1,31 @@
+package sms
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// newTestRedis 启动内存 Redis（支持 Lua 脚本），测试结束后自动关闭
+func newTestRedis(t *testing.T) (*redis.Client, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	return client, server
+}
+
+// newOfflineRedis 创建一个不可用的 Redis 客户端，所有命令立即失败
+func newOfflineRedis() *redis.Client {
+	return redis.NewClient(&redis.Options{
+		MaxRetries:    -1,
+		DialerRetries: 1,
+		Dialer: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return nil, errors.New("redis offline")
+		},
+	})
+}
@@ -3,6 +3,8 @@ package sms
 import (
 	"context"
 	"fmt"
+	"math"
+	"math/rand"
 	"time"
 )
 
@@ -12,11 +14,23 @@ type RetryProvider struct {
 	config   *RetryConfig // 重试配置
 }
 
-// NewRetryProvider 创建重试装饰器 默认3次重试，2s退避
+// NewRetryProvider 创建重试装饰器 默认3次重试，2s线性退避
 func NewRetryProvider(provider SMSProvider, config *RetryConfig) *RetryProvider {
 	if config == nil {
 		config = DefaultRetryConfig()
 	}
+	// 复制配置，填充默认值不影响调用方
+	cloned := *config
+	config = &cloned
+	if config.Strategy == "" {
+		config.Strategy = BackoffLinear
+	}
+	if config.Multiplier <= 0 {
+		config.Multiplier = 2
+	}
+	if config.Jitter == "" {
+		config.Jitter = JitterNone
+	}
 	return &RetryProvider{
 		provider: provider,
 		config:   config,
@@ -25,128 +39,160 @@ func NewRetryProvider(provider SMSProvider, config *RetryConfig) *RetryProvider
 
 // Send 发送短信（带重试）
 func (r *RetryProvider) Send(ctx context.Context, req *SendRequest) (*SendResponse, error) {
-	var lastErr error
+	return withRetry(ctx, r, "短信发送失败", func() (*SendResponse, error) {
+		return r.provider.Send(ctx, req)
+	}, r.shouldRetry)
+}
+
+// QueryStatus 查询短信发送状态（带重试）
+func (r *RetryProvider) QueryStatus(ctx context.Context, msgID string) (*StatusResponse, error) {
+	return withRetry(ctx, r, "查询短信状态失败", func() (*StatusResponse, error) {
+		return r.provider.QueryStatus(ctx, msgID)
+	}, shouldRetryQuery[*StatusResponse](r))
+}
 
-	for attempt := 0; attempt <= r.config.MaxRetries; attempt++ {
+// QueryStatusByPhone 通过手机号查询短信状态（带重试）
+func (r *RetryProvider) QueryStatusByPhone(ctx context.Context, phone string) ([]*StatusResponse, error) {
+	return withRetry(ctx, r, "查询短信状态失败", func() ([]*StatusResponse, error) {
+		return r.provider.QueryStatusByPhone(ctx, phone)
+	}, shouldRetryQuery[[]*StatusResponse](r))
+}
+
+// QueryMessageStatus 按手机号和消息ID查询短信状态（带重试）
+func (r *RetryProvider) QueryMessageStatus(ctx context.Context, phone, msgID string, sentAt time.Time) (*StatusResponse, error) {
+	return withRetry(ctx, r, "查询短信状态失败", func() (*StatusResponse, error) {
+		return queryMessageStatus(ctx, r.provider, phone, msgID, sentAt)
+	}, shouldRetryQuery[*StatusResponse](r))
+}
+
+// withRetry 按重试配置执行调用
+// shouldRetry 返回 (是否成功, 是否重试, 失败原因)；不重试时原样返回调用结果，重试耗尽时返回包装后的最后一次失败原因
+func withRetry[T any](ctx context.Context, r *RetryProvider, action string, call func() (T, error), shouldRetry func(T, error) (bool, bool, error)) (T, error) {
+	var (
+		zero    T
+		lastErr error
+		start   = time.Now()
+		retries int
+	)
+
+	for attempt := 0; ; attempt++ {
 		if attempt > 0 {
 			// 重试前延迟
 			select {
 			case <-ctx.Done():
-				return nil, ctx.Err()
-			case <-time.After(r.config.RetryDelay * time.Duration(attempt)): // 指数退避
+				return zero, ctx.Err()
+			case <-time.After(r.backoff(attempt)):
 			}
+			retries = attempt
 		}
 
-		resp, err := r.provider.Send(ctx, req)
+		result, err := call()
 
 		// 成功则直接返回
-		if err == nil && resp != nil && resp.Success {
-			return resp, nil
+		success, retry, cause := shouldRetry(result, err)
+		if success {
+			return result, nil
 		}
 
-		// 记录错误
-		lastErr = err
+		// 记录失败原因（没有返回错误时为响应错误码生成的错误）
+		lastErr = cause
 
-		// 不应该重试，直接返回错误
-		if !r.shouldRetry(err, resp) {
-			return resp, err
+		// 不应该重试，直接返回
+		if !retry {
+			return result, err
 		}
 
-		// 如果是最后一次尝试，不再继续
-		if attempt == r.config.MaxRetries {
+		// 重试次数或时间预算耗尽，不再继续
+		if attempt == r.config.MaxRetries || !r.withinBudget(start, attempt+1) {
 			break
 		}
 	}
 
 	// 所有重试都失败
-	return nil, fmt.Errorf("短信发送失败，已重试%d次: %w", r.config.MaxRetries, lastErr)
+	return zero, fmt.Errorf("%s，已重试%d次: %w", action, retries, lastErr)
 }
 
-// Verify 验证短信验证码（不需要重试）
-func (r *RetryProvider) Verify(ctx context.Context, req *VerifyRequest) (*VerifyResponse, error) {
-	return r.provider.Verify(ctx, req)
+// backoff 计算第 attempt 次重试前的延迟（含抖动）
+func (r *RetryProvider) backoff(attempt int) time.Duration {
+	delay := float64(r.maxBackoff(attempt))
+	switch r.config.Jitter {
+	case JitterFull:
+		delay = rand.Float64() * delay
+	case JitterEqual:
+		delay = delay/2 + rand.Float64()*delay/2
+	}
+	return time.Duration(delay)
 }
 
-// QueryStatus 查询短信发送状态（带重试）
-func (r *RetryProvider) QueryStatus(ctx context.Context, msgID string) (*StatusResponse, error) {
-	var lastErr error
-
-	for attempt := 0; attempt <= r.config.MaxRetries; attempt++ {
-		if attempt > 0 {
-			select {
-			case <-ctx.Done():
-				return nil, ctx.Err()
-			case <-time.After(r.config.RetryDelay * time.Duration(attempt)):
-			}
-		}
+// maxBackoff 计算第 attempt 次重试前的延迟（不含抖动）
+func (r *RetryProvider) maxBackoff(attempt int) time.Duration {
+	base := float64(r.config.RetryDelay)
+
+	var delay float64
+	switch r.config.Strategy {
+	case BackoffConstant:
+		delay = base
+	case BackoffExponential:
+		delay = base * math.Pow(r.config.Multiplier, float64(attempt-1))
+	default:
+		delay = base * float64(attempt)
+	}
 
-		resp, err := r.provider.QueryStatus(ctx, msgID)
+	if r.config.MaxDelay > 0 && delay > float64(r.config.MaxDelay) {
+		delay = float64(r.config.MaxDelay)
+	}
+	if delay > math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return time.Duration(delay)
+}
 
-		if err == nil {
-			return resp, nil
-		}
+// withinBudget 判断第 attempt 次重试是否在总时长预算内（抖动按最大延迟计算）
+func (r *RetryProvider) withinBudget(start time.Time, attempt int) bool {
+	if r.config.MaxElapsed <= 0 {
+		return true
+	}
+	return time.Since(start)+r.maxBackoff(attempt) <= r.config.MaxElapsed
+}
 
-		lastErr = err
+// shouldRetry 判断发送是否成功、是否应该重试，返回失败原因
+func (r *RetryProvider) shouldRetry(resp *SendResponse, err error) (bool, bool, error) {
+	if err == nil && resp != nil && resp.Success {
+		return true, false, nil
+	}
 
-		// 查询失败可以重试（网络问题等）
-		if !IsRetryableError(err) {
-			return nil, err
-		}
+	if err != nil {
+		return false, r.isRetryable(err, IsRetryableError), err
+	}
 
-		if attempt == r.config.MaxRetries {
-			break
-		}
+	// 检查错误码
+	cause := responseError(resp)
+	if resp != nil {
+		return false, r.isRetryable(cause, func(error) bool {
+			return ShouldRetry(GetErrorType(resp.ErrorCode))
+		}), cause
 	}
 
-	return nil, fmt.Errorf("查询短信状态失败，已重试%d次: %w", r.config.MaxRetries, lastErr)
+	// 其他情况不重试
+	return false, false, cause
 }
 
-// QueryStatusByPhone 通过手机号查询短信状态（带重试）
-func (r *RetryProvider) QueryStatusByPhone(ctx context.Context, phone string) ([]*StatusResponse, error) {
-	var lastErr error
-
-	for attempt := 0; attempt <= r.config.MaxRetries; attempt++ {
-		if attempt > 0 {
-			select {
-			case <-ctx.Done():
-				return nil, ctx.Err()
-			case <-time.After(r.config.RetryDelay * time.Duration(attempt)):
-			}
-		}
-
-		resp, err := r.provider.QueryStatusByPhone(ctx, phone)
-
+// shouldRetryQuery 判断查询是否成功、是否应该重试（网络问题等可以重试）
+func shouldRetryQuery[T any](r *RetryProvider) func(T, error) (bool, bool, error) {
+	return func(_ T, err error) (bool, bool, error) {
 		if err == nil {
-			return resp, nil
-		}
-
-		lastErr = err
-
-		// 查询失败可以重试（网络问题等）
-		if !IsRetryableError(err) {
-			return nil, err
-		}
-
-		if attempt == r.config.MaxRetries {
-			break
+			return true, false, nil
 		}
+		return false, r.isRetryable(err, IsRetryableError), err
 	}
-
-	return nil, fmt.Errorf("查询短信状态失败，已重试%d次: %w", r.config.MaxRetries, lastErr)
 }
 
-// shouldRetry 判断是否应该重试
-func (r *RetryProvider) shouldRetry(err error, resp *SendResponse) bool {
-	if err != nil {
-		return IsRetryableError(err)
-	}
-
-	// 检查错误码
-	if resp != nil && !resp.Success {
-		errType := GetErrorType(resp.ErrorCode)
-		return ShouldRetry(errType)
+// isRetryable 优先使用自定义重试判断，未处理时使用默认规则
+func (r *RetryProvider) isRetryable(err error, fallback func(error) bool) bool {
+	if r.config.Classifier != nil {
+		if retry, handled := r.config.Classifier(err); handled {
+			return retry
+		}
 	}
-
-	// 其他情况不重试
-	return false
+	return fallback(err)
 }
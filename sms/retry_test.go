@@ -0,0 +1,134 @@
+package sms
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryBackoff(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *RetryConfig
+		want   []time.Duration
+	}{
+		{
+			name:   "默认线性退避",
+			config: &RetryConfig{RetryDelay: time.Second},
+			want:   []time.Duration{time.Second, 2 * time.Second, 3 * time.Second},
+		},
+		{
+			name:   "固定延迟",
+			config: &RetryConfig{RetryDelay: time.Second, Strategy: BackoffConstant},
+			want:   []time.Duration{time.Second, time.Second, time.Second},
+		},
+		{
+			name:   "指数退避并限制最大延迟",
+			config: &RetryConfig{RetryDelay: time.Second, Strategy: BackoffExponential, MaxDelay: 5 * time.Second},
+			want:   []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second},
+		},
+		{
+			name:   "指数退避自定义倍数",
+			config: &RetryConfig{RetryDelay: time.Second, Strategy: BackoffExponential, Multiplier: 3},
+			want:   []time.Duration{time.Second, 3 * time.Second, 9 * time.Second},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRetryProvider(&stubProvider{}, tt.config)
+			for i, want := range tt.want {
+				assert.Equal(t, want, r.backoff(i+1))
+			}
+		})
+	}
+
+	full := NewRetryProvider(&stubProvider{}, &RetryConfig{RetryDelay: time.Second, Jitter: JitterFull})
+	equal := NewRetryProvider(&stubProvider{}, &RetryConfig{RetryDelay: time.Second, Jitter: JitterEqual})
+	for i := 0; i < 100; i++ {
+		assert.Less(t, full.backoff(2), 2*time.Second)
+		assert.GreaterOrEqual(t, equal.backoff(2), time.Second)
+		assert.Less(t, equal.backoff(2), 2*time.Second)
+	}
+}
+
+func TestRetryClassifier(t *testing.T) {
+	ctx := context.Background()
+
+	// 默认规则下余额不足不重试，自定义规则可以覆盖
+	provider := &stubProvider{err: NewSMSError("isv.AMOUNT_NOT_ENOUGH", "余额不足", false, nil)}
+	r := NewRetryProvider(provider, &RetryConfig{
+		MaxRetries: 2,
+		RetryDelay: time.Millisecond,
+		Classifier: func(err error) (bool, bool) {
+			return true, true
+		},
+	})
+	_, err := r.Send(ctx, &SendRequest{Phone: "13800138000"})
+	assert.Error(t, err)
+	assert.Equal(t, 3, provider.calls)
+
+	// 未处理的错误使用默认规则
+	provider = &stubProvider{err: NewSMSError("NETWORK_ERROR", "网络错误", true, nil)}
+	r = NewRetryProvider(provider, &RetryConfig{
+		MaxRetries: 2,
+		RetryDelay: time.Millisecond,
+		Classifier: func(err error) (bool, bool) {
+			return false, false
+		},
+	})
+	_, err = r.Send(ctx, &SendRequest{Phone: "13800138000"})
+	assert.ErrorContains(t, err, "已重试2次")
+	assert.Equal(t, 3, provider.calls)
+
+	// 总时长预算耗尽后不再重试
+	provider = &stubProvider{err: NewSMSError("NETWORK_ERROR", "网络错误", true, nil)}
+	r = NewRetryProvider(provider, &RetryConfig{
+		MaxRetries: 5,
+		RetryDelay: 20 * time.Millisecond,
+		Strategy:   BackoffConstant,
+		MaxElapsed: 30 * time.Millisecond,
+	})
+	_, err = r.Send(ctx, &SendRequest{Phone: "13800138000"})
+	assert.ErrorContains(t, err, "已重试1次")
+	assert.Equal(t, 2, provider.calls)
+}
+
+// failedProvider 不返回错误、只在响应中返回错误码的服务商
+type failedProvider struct {
+	stubProvider
+	resp *SendResponse
+}
+
+func (p *failedProvider) Send(ctx context.Context, req *SendRequest) (*SendResponse, error) {
+	p.calls++
+	return p.resp, nil
+}
+
+func TestRetryFailedResponse(t *testing.T) {
+	provider := &failedProvider{resp: &SendResponse{ErrorCode: "isp.SYSTEM_ERROR", ErrorMsg: "系统错误"}}
+	r := NewRetryProvider(provider, &RetryConfig{MaxRetries: 2, RetryDelay: time.Millisecond})
+
+	// 重试耗尽时返回由响应错误码生成的错误
+	_, err := r.Send(context.Background(), &SendRequest{Phone: "13800138000"})
+	require.Error(t, err)
+	assert.Equal(t, 3, provider.calls)
+	assert.NotContains(t, err.Error(), "%!w")
+	assert.ErrorContains(t, err, "已重试2次: 系统错误")
+
+	var smsErr *SMSError
+	require.True(t, errors.As(err, &smsErr))
+	assert.Equal(t, "isp.SYSTEM_ERROR", smsErr.Code)
+
+	// 不重试的错误码原样返回响应
+	provider = &failedProvider{resp: &SendResponse{ErrorCode: "InsufficientBalance", ErrorMsg: "余额不足"}}
+	r = NewRetryProvider(provider, &RetryConfig{MaxRetries: 2, RetryDelay: time.Millisecond})
+	resp, err := r.Send(context.Background(), &SendRequest{Phone: "13800138000"})
+	require.NoError(t, err)
+	assert.Equal(t, provider.resp, resp)
+	assert.Equal(t, 1, provider.calls)
+}
@@ -0,0 +1,273 @@
+package sms
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ProviderEntry 多服务商路由中的一个服务商
+type ProviderEntry struct {
+	Name      string            // 服务商名称（唯一，写入 SendResponse.Provider）
+	Provider  SMSProvider       // 短信服务商
+	Templates map[string]string // 模板映射：业务模板ID -> 该服务商的模板ID（可选，未配置时原样使用）
+	SignName  string            // 该服务商使用的签名（可选，请求未指定签名时生效）
+	Weight    int               // 流量权重（仅 WeightedProvider 使用，0 表示不分配流量）
+}
+
+// buildRequest 按服务商配置生成实际发送的请求（不修改原请求）
+func (e *ProviderEntry) buildRequest(req *SendRequest) *SendRequest {
+	template, hasTemplate := e.Templates[req.Template]
+	if !hasTemplate && (e.SignName == "" || req.SignName != "") {
+		return req
+	}
+
+	cloned := *req
+	if hasTemplate {
+		cloned.Template = template
+	}
+	if cloned.SignName == "" {
+		cloned.SignName = e.SignName
+	}
+	return &cloned
+}
+
+// providerGroup 一组带名称的服务商，负责把查询路由到发送时使用的服务商
+type providerGroup struct {
+	entries []*ProviderEntry
+	byName  map[string]*ProviderEntry
+	routes  *providerRoutes
+}
+
+// newProviderGroup 创建服务商组（entries 需先通过 validateEntries 校验）
+func newProviderGroup(redis *redis.Client, entries []*ProviderEntry, routeTTL time.Duration) *providerGroup {
+	byName := make(map[string]*ProviderEntry, len(entries))
+	for _, entry := range entries {
+		byName[entry.Name] = entry
+	}
+	return &providerGroup{
+		entries: entries,
+		byName:  byName,
+		routes:  newProviderRoutes(redis, routeTTL),
+	}
+}
+
+// QueryStatus 查询短信发送状态（路由到发送该短信的服务商）
+func (g *providerGroup) QueryStatus(ctx context.Context, msgID string) (*StatusResponse, error) {
+	name, err := g.routes.byMsgID(ctx, msgID)
+	if err != nil {
+		return nil, err
+	}
+	if entry, ok := g.byName[name]; ok {
+		return g.queryStatus(ctx, entry, msgID)
+	}
+
+	// 没有路由记录，依次尝试
+	var lastErr error
+	for _, entry := range g.entries {
+		status, err := g.queryStatus(ctx, entry, msgID)
+		if err == nil {
+			return status, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// QueryStatusByPhone 通过手机号查询短信状态，汇总多个服务商的结果
+// 有路由记录时查询该手机号使用过的所有服务商，否则查询全部服务商
+func (g *providerGroup) QueryStatusByPhone(ctx context.Context, phone string) ([]*StatusResponse, error) {
+	names, err := g.routes.byPhone(ctx, phone)
+	if err != nil {
+		return nil, err
+	}
+	var entries []*ProviderEntry
+	for _, entry := range g.entries {
+		if names[entry.Name] {
+			entries = append(entries, entry)
+		}
+	}
+	if len(entries) == 0 {
+		entries = g.entries
+	}
+
+	var (
+		allResults []*StatusResponse
+		errs       []error
+	)
+	for _, entry := range entries {
+		statuses, err := g.queryStatusByPhone(ctx, entry, phone)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		allResults = append(allResults, statuses...)
+	}
+
+	// 全部失败时返回错误
+	if len(errs) == len(entries) {
+		return nil, errors.Join(errs...)
+	}
+	return allResults, nil
+}
+
+// QueryMessageStatus 按手机号和消息ID查询短信状态（路由到发送该短信的服务商，没有路由记录时依次查询）
+func (g *providerGroup) QueryMessageStatus(ctx context.Context, phone, msgID string, sentAt time.Time) (*StatusResponse, error) {
+	name, err := g.routes.byMsgID(ctx, msgID)
+	if err != nil {
+		return nil, err
+	}
+	entries := g.entries
+	if entry, ok := g.byName[name]; ok {
+		entries = []*ProviderEntry{entry}
+	}
+
+	var errs []error
+	for _, entry := range entries {
+		status, err := queryMessageStatus(ctx, entry.Provider, phone, msgID, sentAt)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if status != nil {
+			status.Provider = entry.Name
+			return status, nil
+		}
+	}
+	return nil, errors.Join(errs...)
+}
+
+// send 使用指定服务商发送，成功时记录路由
+func (g *providerGroup) send(ctx context.Context, entry *ProviderEntry, req *SendRequest) (*SendResponse, error) {
+	resp, err := entry.Provider.Send(ctx, entry.buildRequest(req))
+	if resp != nil {
+		resp.Provider = entry.Name
+	}
+
+	if err == nil && resp != nil && resp.Success {
+		_ = g.routes.record(ctx, req, resp, entry.Name)
+		return resp, nil
+	}
+
+	if err == nil {
+		err = responseError(resp)
+	}
+	return resp, err
+}
+
+func (g *providerGroup) queryStatus(ctx context.Context, entry *ProviderEntry, msgID string) (*StatusResponse, error) {
+	status, err := entry.Provider.QueryStatus(ctx, msgID)
+	if status != nil {
+		status.Provider = entry.Name
+	}
+	return status, err
+}
+
+func (g *providerGroup) queryStatusByPhone(ctx context.Context, entry *ProviderEntry, phone string) ([]*StatusResponse, error) {
+	statuses, err := entry.Provider.QueryStatusByPhone(ctx, phone)
+	for _, status := range statuses {
+		status.Provider = entry.Name
+	}
+	return statuses, err
+}
+
+// responseError 将失败的响应转换为错误
+func responseError(resp *SendResponse) error {
+	if resp == nil {
+		return NewSMSError("RESPONSE_ERROR", "响应体为空", true, nil).WithType(ErrorTypeOther)
+	}
+	return NewSMSError(resp.ErrorCode, resp.ErrorMsg, ShouldRetry(GetErrorType(resp.ErrorCode)), nil)
+}
+
+// validateEntries 校验服务商列表
+func validateEntries(entries []*ProviderEntry) error {
+	if len(entries) == 0 {
+		return errors.New("至少需要一个短信服务商")
+	}
+
+	names := make(map[string]struct{}, len(entries))
+	for _, entry := range entries {
+		if entry == nil || entry.Provider == nil {
+			return errors.New("短信服务商不能为空")
+		}
+		if entry.Name == "" {
+			return errors.New("短信服务商名称不能为空")
+		}
+		if _, ok := names[entry.Name]; ok {
+			return fmt.Errorf("短信服务商名称重复: %s", entry.Name)
+		}
+		names[entry.Name] = struct{}{}
+	}
+	return nil
+}
+
+// providerRoutes 记录消息由哪个服务商发送，保证后续查询路由到同一服务商
+type providerRoutes struct {
+	redis *redis.Client
+	ttl   time.Duration
+}
+
+// newProviderRoutes 创建路由记录
+func newProviderRoutes(redis *redis.Client, ttl time.Duration) *providerRoutes {
+	if ttl == 0 {
+		ttl = 72 * time.Hour
+	}
+	return &providerRoutes{
+		redis: redis,
+		ttl:   ttl,
+	}
+}
+
+// record 记录一次成功发送使用的服务商
+func (r *providerRoutes) record(ctx context.Context, req *SendRequest, resp *SendResponse, name string) error {
+	pipe := r.redis.Pipeline()
+	if resp.MsgID != "" {
+		pipe.Set(ctx, getMsgRouteKey(resp.MsgID), name, r.ttl)
+	}
+	// 同一手机号可能经由多个服务商发送（故障转移、按权重分配），记录全部服务商
+	key := getPhoneRouteKey(req.GetFullPhone())
+	pipe.SAdd(ctx, key, name)
+	pipe.Expire(ctx, key, r.ttl)
+
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// byMsgID 查询消息对应的服务商，没有记录时返回空字符串
+func (r *providerRoutes) byMsgID(ctx context.Context, msgID string) (string, error) {
+	return r.get(ctx, getMsgRouteKey(msgID))
+}
+
+// byPhone 查询手机号使用过的服务商，没有记录时返回空集合
+func (r *providerRoutes) byPhone(ctx context.Context, phone string) (map[string]bool, error) {
+	members, err := r.redis.SMembers(ctx, getPhoneRouteKey(phone)).Result()
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]bool, len(members))
+	for _, name := range members {
+		names[name] = true
+	}
+	return names, nil
+}
+
+func (r *providerRoutes) get(ctx context.Context, key string) (string, error) {
+	name, err := r.redis.Get(ctx, key).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return name, err
+}
+
+// getMsgRouteKey 获取消息路由的key
+func getMsgRouteKey(msgID string) string {
+	return "sms:route:msg:" + msgID
+}
+
+// getPhoneRouteKey 获取手机号路由的key（集合，记录使用过的服务商）
+func getPhoneRouteKey(phone string) string {
+	return "sms:route:phones:" + normalizePhone(phone, "")
+}
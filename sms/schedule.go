@@ -0,0 +1,373 @@
+package sms
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	// ErrScheduleNotFound 定时短信不存在（已发送或已取消）
+	ErrScheduleNotFound = errors.New("定时短信不存在")
+	// ErrScheduleInFlight 定时短信已被领取、正在发送，不能取消
+	ErrScheduleInFlight = errors.New("定时短信正在发送，不能取消")
+)
+
+// claimScheduleScript 领取到期的定时短信
+// 领取后将执行时间推迟到租约到期并记录到已领取集合，发送完成后删除；进程崩溃时租约到期后由其他实例重新领取
+// KEYS[1]：定时有序集合；KEYS[2]：请求哈希表；KEYS[3]：已领取集合；KEYS[4]：计划发送时间有序集合
+// ARGV[1]：当前时间（毫秒）；ARGV[2]：租约到期时间（毫秒）；ARGV[3]：最大数量
+var claimScheduleScript = redis.NewScript(`
+local ids = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[3])
+local result = {}
+for _, id in ipairs(ids) do
+	local data = redis.call('HGET', KEYS[2], id)
+	if data then
+		redis.call('ZADD', KEYS[1], ARGV[2], id)
+		redis.call('SADD', KEYS[3], id)
+		table.insert(result, id)
+		table.insert(result, data)
+	else
+		redis.call('ZREM', KEYS[1], id)
+		redis.call('SREM', KEYS[3], id)
+		redis.call('ZREM', KEYS[4], id)
+	end
+end
+return result
+`)
+
+// cancelScheduleScript 取消未领取的定时短信
+// 返回 -1 表示已被领取，0 表示不存在，1 表示已取消
+// KEYS[1]：定时有序集合；KEYS[2]：请求哈希表；KEYS[3]：已领取集合；KEYS[4]：计划发送时间有序集合；ARGV[1]：定时ID
+var cancelScheduleScript = redis.NewScript(`
+if redis.call('SISMEMBER', KEYS[3], ARGV[1]) == 1 then
+	return -1
+end
+local removed = redis.call('ZREM', KEYS[1], ARGV[1])
+redis.call('HDEL', KEYS[2], ARGV[1])
+redis.call('ZREM', KEYS[4], ARGV[1])
+return removed
+`)
+
+// ScheduledMessage 定时短信
+type ScheduledMessage struct {
+	ID      string       // 定时ID（Schedule 返回）
+	Request *SendRequest // 发送请求
+	At      time.Time    // 计划发送时间
+}
+
+// scheduledData 定时短信存储格式
+type scheduledData struct {
+	At      int64        `json:"at"` // 计划发送时间（毫秒）
+	Request *SendRequest `json:"request"`
+}
+
+// scheduleStore 定时短信存储
+type scheduleStore struct {
+	redis *redis.Client
+}
+
+// newScheduleStore 创建定时短信存储
+func newScheduleStore(redis *redis.Client) *scheduleStore {
+	return &scheduleStore{redis: redis}
+}
+
+// add 添加定时短信
+func (s *scheduleStore) add(ctx context.Context, req *SendRequest, at time.Time) (string, error) {
+	data, err := json.Marshal(&scheduledData{At: at.UnixMilli(), Request: req})
+	if err != nil {
+		return "", err
+	}
+
+	id := newMessageID()
+	pipe := s.redis.TxPipeline()
+	pipe.HSet(ctx, getScheduleDataKey(), id, data)
+	pipe.ZAdd(ctx, getScheduleKey(), redis.Z{Score: float64(at.UnixMilli()), Member: id})
+	pipe.ZAdd(ctx, getScheduleAtKey(), redis.Z{Score: float64(at.UnixMilli()), Member: id})
+	if _, err := pipe.Exec(ctx); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// cancel 取消定时短信，不存在时返回 ErrScheduleNotFound，已被领取时返回 ErrScheduleInFlight
+func (s *scheduleStore) cancel(ctx context.Context, id string) error {
+	result, err := cancelScheduleScript.Run(ctx, s.redis, s.keys(), id).Int()
+	if err != nil {
+		return err
+	}
+	switch result {
+	case -1:
+		return ErrScheduleInFlight
+	case 0:
+		return ErrScheduleNotFound
+	}
+	return nil
+}
+
+// complete 删除已发送（或无法发送）的定时短信
+func (s *scheduleStore) complete(ctx context.Context, id string) error {
+	pipe := s.redis.TxPipeline()
+	pipe.ZRem(ctx, getScheduleKey(), id)
+	pipe.HDel(ctx, getScheduleDataKey(), id)
+	pipe.SRem(ctx, getScheduleClaimedKey(), id)
+	pipe.ZRem(ctx, getScheduleAtKey(), id)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// list 按计划发送时间列出待发送的定时短信（包括已领取、正在发送的）
+func (s *scheduleStore) list(ctx context.Context, offset, limit int) ([]*ScheduledMessage, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	// 领取后定时有序集合的分数变为租约到期时间，按计划发送时间有序集合分页
+	ids, err := s.redis.ZRange(ctx, getScheduleAtKey(), int64(offset), int64(offset+limit-1)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	values, err := s.redis.HMGet(ctx, getScheduleDataKey(), ids...).Result()
+	if err != nil {
+		return nil, err
+	}
+	messages := make([]*ScheduledMessage, 0, len(ids))
+	for i, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			continue
+		}
+		message, err := decodeScheduledMessage(ids[i], data)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+// claim 领取到期的定时短信
+func (s *scheduleStore) claim(ctx context.Context, limit int, lease time.Duration) ([]*ScheduledMessage, error) {
+	now := time.Now()
+	result, err := claimScheduleScript.Run(ctx, s.redis, s.keys(),
+		now.UnixMilli(), now.Add(lease).UnixMilli(), limit,
+	).StringSlice()
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]*ScheduledMessage, 0, len(result)/2)
+	for i := 0; i+1 < len(result); i += 2 {
+		message, err := decodeScheduledMessage(result[i], result[i+1])
+		if err != nil {
+			// 无法解析的数据直接删除，避免反复领取
+			_ = s.complete(ctx, result[i])
+			continue
+		}
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+// keys 脚本使用的 key
+func (s *scheduleStore) keys() []string {
+	return []string{getScheduleKey(), getScheduleDataKey(), getScheduleClaimedKey(), getScheduleAtKey()}
+}
+
+// decodeScheduledMessage 解析定时短信
+func decodeScheduledMessage(id, value string) (*ScheduledMessage, error) {
+	var data scheduledData
+	if err := json.Unmarshal([]byte(value), &data); err != nil {
+		return nil, err
+	}
+	return &ScheduledMessage{
+		ID:      id,
+		Request: data.Request,
+		At:      time.UnixMilli(data.At),
+	}, nil
+}
+
+// getScheduleKey 获取定时有序集合的key
+func getScheduleKey() string {
+	return "sms:schedule"
+}
+
+// getScheduleDataKey 获取定时请求哈希表的key
+func getScheduleDataKey() string {
+	return "sms:schedule:requests"
+}
+
+// getScheduleClaimedKey 获取已领取定时短信集合的key
+func getScheduleClaimedKey() string {
+	return "sms:schedule:claimed"
+}
+
+// getScheduleAtKey 获取按计划发送时间排序的有序集合的key
+func getScheduleAtKey() string {
+	return "sms:schedule:at"
+}
+
+// ========== 定时发送 ==========
+
+// SchedulerConfig 定时发送配置
+type SchedulerConfig struct {
+	Interval    time.Duration // 轮询间隔，默认 1 秒
+	BatchSize   int           // 每次领取的最大数量，默认 100
+	Concurrency int           // 同时发送的最大数量，默认 5
+	Lease       time.Duration // 领取后的租约时间，超过后未完成的短信会被重新领取，默认 1 分钟
+
+	// OnDispatch 定时短信发送完成（可选），err 不为空时表示发送失败（不会重新发送）
+	OnDispatch func(ctx context.Context, message *ScheduledMessage, resp *SendResponse, err error)
+	OnError    func(err error) // 领取出错（可选）
+}
+
+// DefaultSchedulerConfig 默认定时发送配置
+func DefaultSchedulerConfig() *SchedulerConfig {
+	return &SchedulerConfig{
+		Interval:    time.Second,
+		BatchSize:   100,
+		Concurrency: 5,
+		Lease:       time.Minute,
+	}
+}
+
+// Scheduler 定时发送
+// 定期领取到期的定时短信，通过 Client.Send 发送（同样经过免打扰、限流、配额检查）；多个实例可以同时运行，每条短信只会被一个实例领取
+type Scheduler struct {
+	client *Client
+	config *SchedulerConfig
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewScheduler 创建定时发送
+func NewScheduler(client *Client, config *SchedulerConfig) *Scheduler {
+	if config == nil {
+		config = DefaultSchedulerConfig()
+	}
+	// 复制配置，填充默认值不影响调用方
+	cloned := *config
+	config = &cloned
+	defaults := DefaultSchedulerConfig()
+	if config.Interval <= 0 {
+		config.Interval = defaults.Interval
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaults.BatchSize
+	}
+	if config.Concurrency <= 0 {
+		config.Concurrency = defaults.Concurrency
+	}
+	if config.Lease <= 0 {
+		config.Lease = defaults.Lease
+	}
+	return &Scheduler{
+		client: client,
+		config: config,
+	}
+}
+
+// Start 启动定时发送，重复调用无效
+func (s *Scheduler) Start(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil {
+		return
+	}
+
+	ctx, s.cancel = context.WithCancel(ctx)
+	s.done = make(chan struct{})
+	go s.run(ctx, s.done)
+}
+
+// Stop 停止定时发送，等待正在发送的短信处理完成
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	cancel, done := s.cancel, s.done
+	s.cancel, s.done = nil, nil
+	s.mu.Unlock()
+
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+}
+
+// run 定期领取并发送到期的定时短信
+func (s *Scheduler) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+	for {
+		// 领取满一批时立即继续，避免积压
+		if s.Dispatch(ctx) >= s.config.BatchSize && ctx.Err() == nil {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Dispatch 领取一批到期的定时短信并发送，返回领取的数量
+func (s *Scheduler) Dispatch(ctx context.Context) int {
+	messages, err := s.client.schedules.claim(ctx, s.config.BatchSize, s.config.Lease)
+	if err != nil {
+		if s.config.OnError != nil {
+			s.config.OnError(err)
+		}
+		return 0
+	}
+
+	// 停止时让已领取的短信发送完成
+	ctx = context.WithoutCancel(ctx)
+	var (
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, s.config.Concurrency)
+	)
+	for _, message := range messages {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(message *ScheduledMessage) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			s.dispatch(ctx, message)
+		}(message)
+	}
+	wg.Wait()
+	return len(messages)
+}
+
+// dispatch 发送一条定时短信
+func (s *Scheduler) dispatch(ctx context.Context, message *ScheduledMessage) {
+	req := *message.Request
+	// 以定时ID作为默认幂等键，租约到期被重新领取时不会重复发送
+	if req.IdempotencyKey == "" {
+		req.IdempotencyKey = "schedule:" + message.ID
+	}
+
+	resp, err := s.client.Send(ctx, &req)
+	if errors.Is(err, ErrIdempotencyInProgress) {
+		// 其他实例正在发送，租约到期后再确认结果
+		return
+	}
+	if err := s.client.schedules.complete(ctx, message.ID); err != nil && s.config.OnError != nil {
+		s.config.OnError(err)
+	}
+	if s.config.OnDispatch != nil {
+		s.config.OnDispatch(ctx, message, resp, err)
+	}
+}
@@ -0,0 +1,135 @@
+package sms
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeScheduledMessage(t *testing.T) {
+	at := time.UnixMilli(1733625000000)
+	data, err := json.Marshal(&scheduledData{
+		At:      at.UnixMilli(),
+		Request: &SendRequest{Phone: "13800138000", Template: "SMS_123", Params: map[string]string{"name": "张三"}},
+	})
+	require.NoError(t, err)
+
+	message, err := decodeScheduledMessage("id1", string(data))
+	require.NoError(t, err)
+	assert.Equal(t, "id1", message.ID)
+	assert.True(t, at.Equal(message.At))
+	assert.Equal(t, "13800138000", message.Request.Phone)
+	assert.Equal(t, "张三", message.Request.Params["name"])
+
+	_, err = decodeScheduledMessage("id2", "invalid")
+	assert.Error(t, err)
+}
+
+func TestScheduleClaimAndCancel(t *testing.T) {
+	ctx := context.Background()
+	rdb, _ := newTestRedis(t)
+	store := newScheduleStore(rdb)
+
+	now := time.Now()
+	due, err := store.add(ctx, &SendRequest{Phone: "13800138000"}, now.Add(-time.Second))
+	require.NoError(t, err)
+	later, err := store.add(ctx, &SendRequest{Phone: "13800138001"}, now.Add(time.Hour))
+	require.NoError(t, err)
+
+	// 只领取到期的短信，租约期间不会被重复领取
+	messages, err := store.claim(ctx, 10, 2*time.Hour)
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+	assert.Equal(t, due, messages[0].ID)
+	messages, err = store.claim(ctx, 10, 2*time.Hour)
+	require.NoError(t, err)
+	assert.Empty(t, messages)
+
+	// 领取后仍按计划发送时间排序
+	listed, err := store.list(ctx, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, listed, 2)
+	assert.Equal(t, due, listed[0].ID)
+	assert.Equal(t, later, listed[1].ID)
+
+	// 正在发送的短信不能取消
+	assert.ErrorIs(t, store.cancel(ctx, due), ErrScheduleInFlight)
+	require.NoError(t, store.cancel(ctx, later))
+	assert.ErrorIs(t, store.cancel(ctx, later), ErrScheduleNotFound)
+
+	require.NoError(t, store.complete(ctx, due))
+	assert.ErrorIs(t, store.cancel(ctx, due), ErrScheduleNotFound)
+	listed, err = store.list(ctx, 0, 10)
+	require.NoError(t, err)
+	assert.Empty(t, listed)
+	assert.Zero(t, rdb.SCard(ctx, getScheduleClaimedKey()).Val())
+}
+
+func TestScheduleLeaseExpiry(t *testing.T) {
+	ctx := context.Background()
+	rdb, _ := newTestRedis(t)
+	store := newScheduleStore(rdb)
+
+	id, err := store.add(ctx, &SendRequest{Phone: "13800138000"}, time.Now())
+	require.NoError(t, err)
+
+	messages, err := store.claim(ctx, 10, 50*time.Millisecond)
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+
+	// 租约到期后（实例崩溃）重新领取
+	time.Sleep(60 * time.Millisecond)
+	messages, err = store.claim(ctx, 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+	assert.Equal(t, id, messages[0].ID)
+	assert.Equal(t, "13800138000", messages[0].Request.Phone)
+}
+
+func TestSchedulerDispatch(t *testing.T) {
+	ctx := context.Background()
+	rdb, _ := newTestRedis(t)
+	provider := &stubProvider{}
+	client := NewClient(&ClientConfig{Redis: rdb, Provider: provider})
+
+	id, err := client.Schedule(ctx, &SendRequest{Phone: "13800138000", Template: "SMS_123", BizID: "marketing"}, time.Now().Add(-time.Second))
+	require.NoError(t, err)
+	_, err = client.Schedule(ctx, &SendRequest{Phone: "13800138001", Template: "SMS_123", BizID: "marketing"}, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+
+	var dispatched []string
+	scheduler := NewScheduler(client, &SchedulerConfig{
+		OnDispatch: func(ctx context.Context, message *ScheduledMessage, resp *SendResponse, err error) {
+			assert.NoError(t, err)
+			assert.Equal(t, "stub", resp.MsgID)
+			dispatched = append(dispatched, message.ID)
+		},
+	})
+	assert.Equal(t, 1, scheduler.Dispatch(ctx))
+	assert.Equal(t, []string{id}, dispatched)
+	assert.Equal(t, 1, provider.calls)
+
+	// 发送后删除
+	assert.ErrorIs(t, client.CancelSchedule(ctx, id), ErrScheduleNotFound)
+	schedules, err := client.ListSchedules(ctx, 0, 10)
+	require.NoError(t, err)
+	assert.Len(t, schedules, 1)
+	assert.Equal(t, 0, scheduler.Dispatch(ctx))
+}
+
+func TestSchedulerDispatchError(t *testing.T) {
+	client := NewClient(&ClientConfig{Redis: newOfflineRedis(), Provider: &stubProvider{}})
+
+	var errs []error
+	config := &SchedulerConfig{OnError: func(err error) { errs = append(errs, err) }}
+	scheduler := NewScheduler(client, config)
+	// 填充默认值不影响调用方的配置
+	assert.Zero(t, config.Interval)
+	assert.Zero(t, config.Lease)
+	assert.Equal(t, 0, scheduler.Dispatch(context.Background()))
+	assert.Len(t, errs, 1)
+}
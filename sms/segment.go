@@ -0,0 +1,164 @@
+package sms
+
+import (
+	"strings"
+	"sync"
+	"unicode/utf16"
+)
+
+// Encoding 短信编码
+type Encoding string
+
+const (
+	EncodingGSM7 Encoding = "GSM-7" // GSM 7 位默认字母表：单条 160 字符，长短信每条 153 字符
+	EncodingUCS2 Encoding = "UCS-2" // UCS-2（含中文等字符时）：单条 70 字符，长短信每条 67 字符
+)
+
+// 每条短信的容量（GSM-7 按 7 位单元计，UCS-2 按 UTF-16 编码单元计）
+const (
+	gsm7SingleLimit = 160
+	gsm7MultiLimit  = 153
+	ucs2SingleLimit = 70
+	ucs2MultiLimit  = 67
+)
+
+// gsm7Basic GSM 7 位默认字母表（每个字符占 1 个单元）
+var gsm7Basic = "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?" +
+	"¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"
+
+// gsm7Extension GSM 7 位扩展字符（转义后占 2 个单元）
+var gsm7Extension = "^{}\\[~]|€\f"
+
+// SegmentInfo 短信分段（计费条数）信息
+type SegmentInfo struct {
+	Encoding Encoding  // 编码
+	Units    int       // 长度（GSM-7 为 7 位单元数，UCS-2 为 UTF-16 编码单元数）
+	Segments int       // 计费条数
+	Parts    []Segment // 每条短信的内容范围
+}
+
+// Segment 一条短信的内容范围
+type Segment struct {
+	Start int    // 起始位置（字符下标，包含）
+	End   int    // 结束位置（字符下标，不包含）
+	Text  string // 内容
+}
+
+// CalculateSegments 计算短信分段，content 为渲染后的短信内容，signName 不为空时按【签名】计入长度
+// 超过单条容量时按长短信拆分，转义字符和代理对不会被拆开
+// 【】不在 GSM-7 字母表中，带签名时始终按 UCS-2 计算（国内短信的签名都是【签名】格式，不会使用 GSM-7）
+func CalculateSegments(content, signName string) *SegmentInfo {
+	text := content
+	if signName != "" {
+		text = "【" + signName + "】" + content
+	}
+	runes := []rune(text)
+
+	encoding := EncodingGSM7
+	for _, r := range runes {
+		if gsm7Units(r) == 0 {
+			encoding = EncodingUCS2
+			break
+		}
+	}
+
+	units := make([]int, len(runes))
+	total := 0
+	for i, r := range runes {
+		if encoding == EncodingGSM7 {
+			units[i] = gsm7Units(r)
+		} else {
+			units[i] = len(utf16.Encode([]rune{r}))
+		}
+		total += units[i]
+	}
+
+	info := &SegmentInfo{Encoding: encoding, Units: total}
+	if total == 0 {
+		return info
+	}
+
+	single, multi := gsm7SingleLimit, gsm7MultiLimit
+	if encoding == EncodingUCS2 {
+		single, multi = ucs2SingleLimit, ucs2MultiLimit
+	}
+	if total <= single {
+		info.Segments = 1
+		info.Parts = []Segment{{Start: 0, End: len(runes), Text: text}}
+		return info
+	}
+
+	start, used := 0, 0
+	for i := range runes {
+		if used+units[i] > multi {
+			info.Parts = append(info.Parts, Segment{Start: start, End: i, Text: string(runes[start:i])})
+			start, used = i, 0
+		}
+		used += units[i]
+	}
+	info.Parts = append(info.Parts, Segment{Start: start, End: len(runes), Text: string(runes[start:])})
+	info.Segments = len(info.Parts)
+	return info
+}
+
+// gsm7Units 字符在 GSM-7 中占用的单元数，不在字母表中时返回 0
+func gsm7Units(r rune) int {
+	switch {
+	case strings.ContainsRune(gsm7Basic, r):
+		return 1
+	case strings.ContainsRune(gsm7Extension, r):
+		return 2
+	default:
+		return 0
+	}
+}
+
+// ========== 价格 ==========
+
+// PriceTable 短信单价表：服务商 + 国家代码 -> 每条价格（货币单位由使用方约定，如元）
+// 服务商或国家代码为空表示通配，查询时依次匹配：服务商+国家、任意服务商+国家、服务商+任意国家、任意服务商+任意国家
+type PriceTable struct {
+	mu     sync.RWMutex
+	prices map[string]float64 // provider + "|" + countryCode -> 单价
+}
+
+// NewPriceTable 创建单价表
+func NewPriceTable() *PriceTable {
+	return &PriceTable{
+		prices: make(map[string]float64),
+	}
+}
+
+// Set 设置单价（链式调用），provider、countryCode 为空表示通配
+func (t *PriceTable) Set(provider, countryCode string, price float64) *PriceTable {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.prices[provider+"|"+countryCode] = price
+	return t
+}
+
+// Price 查询单价
+func (t *PriceTable) Price(provider, countryCode string) (float64, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for _, key := range []string{
+		provider + "|" + countryCode,
+		"|" + countryCode,
+		provider + "|",
+		"|",
+	} {
+		if price, ok := t.prices[key]; ok {
+			return price, true
+		}
+	}
+	return 0, false
+}
+
+// Estimate 估算费用：单价 * 条数，未配置单价时返回 false
+func (t *PriceTable) Estimate(provider, countryCode string, segments int) (float64, bool) {
+	price, ok := t.Price(provider, countryCode)
+	if !ok {
+		return 0, false
+	}
+	return price * float64(segments), true
+}
@@ -0,0 +1,93 @@
+package sms
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCalculateSegmentsGSM7(t *testing.T) {
+	info := CalculateSegments("Your code is 123456", "")
+	assert.Equal(t, EncodingGSM7, info.Encoding)
+	assert.Equal(t, 19, info.Units)
+	assert.Equal(t, 1, info.Segments)
+
+	info = CalculateSegments(strings.Repeat("a", 160), "")
+	assert.Equal(t, 1, info.Segments)
+
+	info = CalculateSegments(strings.Repeat("a", 161), "")
+	assert.Equal(t, 2, info.Segments)
+	assert.Equal(t, Segment{Start: 0, End: 153, Text: strings.Repeat("a", 153)}, info.Parts[0])
+	assert.Equal(t, 153, info.Parts[1].Start)
+	assert.Equal(t, 161, info.Parts[1].End)
+
+	// 扩展字符占 2 个单元，且不会被拆开
+	info = CalculateSegments(strings.Repeat("a", 152)+"€"+strings.Repeat("a", 10), "")
+	assert.Equal(t, 164, info.Units)
+	require.Equal(t, 2, info.Segments)
+	assert.Equal(t, 152, info.Parts[0].End)
+	assert.Equal(t, "€", info.Parts[1].Text[:len("€")])
+}
+
+func TestCalculateSegmentsUCS2(t *testing.T) {
+	// 签名计入长度：【测试】4 个字符
+	info := CalculateSegments(strings.Repeat("中", 66), "测试")
+	assert.Equal(t, EncodingUCS2, info.Encoding)
+	assert.Equal(t, 70, info.Units)
+	assert.Equal(t, 1, info.Segments)
+
+	info = CalculateSegments(strings.Repeat("中", 67), "测试")
+	assert.Equal(t, 2, info.Segments)
+	assert.Equal(t, 67, info.Parts[0].End)
+	assert.Equal(t, 71, info.Parts[1].End)
+
+	// 代理对占 2 个单元，且不会被拆开
+	info = CalculateSegments(strings.Repeat("中", 66)+"😀"+strings.Repeat("中", 3), "")
+	assert.Equal(t, 71, info.Units)
+	require.Equal(t, 2, info.Segments)
+	assert.Equal(t, 66, info.Parts[0].End)
+	assert.Equal(t, "😀中中中", info.Parts[1].Text)
+
+	assert.Equal(t, 0, CalculateSegments("", "").Segments)
+
+	// 【】不在 GSM-7 字母表中，英文内容带签名时同样按 UCS-2 计算
+	info = CalculateSegments("Your code is 123456", "Acme")
+	assert.Equal(t, EncodingUCS2, info.Encoding)
+}
+
+func TestPriceTable(t *testing.T) {
+	prices := NewPriceTable().
+		Set("", "+86", 0.045).
+		Set("tencent", "+86", 0.04).
+		Set("", "", 0.5)
+
+	cost, ok := prices.Estimate("tencent", "+86", 2)
+	assert.True(t, ok)
+	assert.InDelta(t, 0.08, cost, 1e-9)
+
+	price, _ := prices.Price("aliyun", "+86")
+	assert.InDelta(t, 0.045, price, 1e-9)
+	price, _ = prices.Price("aliyun", "+1")
+	assert.InDelta(t, 0.5, price, 1e-9)
+
+	_, ok = NewPriceTable().Estimate("aliyun", "+86", 1)
+	assert.False(t, ok)
+}
+
+func TestClientEstimate(t *testing.T) {
+	client := NewClient(&ClientConfig{
+		Redis:      newOfflineRedis(),
+		Provider:   &stubProvider{},
+		Templates:  NewTemplateRegistry(&Template{Code: "SMS_123", Content: "您的验证码为${code}，5分钟内有效", SignName: "测试"}),
+		PriceTable: NewPriceTable().Set("", "+86", 0.045),
+	})
+
+	cloned, _, err := client.prepare(&SendRequest{Phone: "13800138000", Template: "SMS_123", Params: map[string]string{"code": "123456"}})
+	require.NoError(t, err)
+	resp := &SendResponse{Success: true}
+	client.estimate(cloned, resp)
+	assert.Equal(t, 1, resp.Segments)
+	assert.InDelta(t, 0.045, resp.EstimatedCost, 1e-9)
+}
@@ -0,0 +1,211 @@
+package sms
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// ErrMessageNotFound 发送记录不存在
+var ErrMessageNotFound = errors.New("短信发送记录不存在")
+
+// MessageStore 短信发送记录存储
+// Client 会记录每一次发送尝试；状态报告、状态对账通过 UpdateStatus 更新最终状态
+type MessageStore interface {
+	// Save 保存发送记录（ID 为空时自动生成）
+	Save(ctx context.Context, record *MessageRecord) error
+	// UpdateStatus 按 MsgID 和手机号（StatusResponse.Phone 不为空时）更新发送状态，记录不存在时返回 ErrMessageNotFound
+	// 批量发送的多个接收人 MsgID 相同，按手机号区分；未填写手机号时更新该 MsgID 的所有记录
+	UpdateStatus(ctx context.Context, status *StatusResponse) error
+	// Get 按记录ID查询，记录不存在时返回 ErrMessageNotFound
+	Get(ctx context.Context, id string) (*MessageRecord, error)
+	// GetByMsgID 按服务商消息ID查询（同一 MsgID 有多条记录时返回最新的一条），记录不存在时返回 ErrMessageNotFound
+	GetByMsgID(ctx context.Context, msgID string) (*MessageRecord, error)
+	// Query 按条件查询，结果按发送时间、记录ID倒序
+	Query(ctx context.Context, query *MessageQuery) ([]*MessageRecord, error)
+}
+
+// MessageRecord 短信发送记录
+type MessageRecord struct {
+	ID          string            // 记录ID
+	MsgID       string            // 服务商消息ID（发送成功时填写）
+	Phone       string            // 手机号（E.164 格式，如 +8613800138000）
+	CountryCode string            // 国家代码（请求中的原始值）
+	BizID       string            // 业务ID
+	Template    string            // 模板ID
+	Params      map[string]string // 模板参数（验证码已脱敏）
+	SignName    string            // 签名名称
+	OutID       string            // 外部ID
+	DeviceID    string            // 设备ID
+	UserID      string            // 用户账号ID
+	IP          string            // IP地址
+	Provider    string            // 服务商名称（多服务商路由时填写）
+	Status      MessageStatus     // 发送状态：发送成功等待回执为 StatusPending，失败为 StatusFailed
+	ErrorCode   string            // 错误码
+	ErrorMsg    string            // 错误信息
+	CreatedAt   time.Time         // 发送时间
+	UpdatedAt   time.Time         // 最后更新时间
+}
+
+// MessageQuery 发送记录查询条件，未设置的条件不过滤
+type MessageQuery struct {
+	Phone    string          // 手机号（任意格式，标准化为 E.164 后匹配，国家代码默认 +86）
+	BizID    string          // 业务ID
+	Provider string          // 服务商名称
+	Status   []MessageStatus // 发送状态（任一匹配）
+	Start    time.Time       // 发送时间起（包含）
+	End      time.Time       // 发送时间止（不包含）
+	BeforeID string          // 分页游标（可选）：设置后发送时间与 End 同一毫秒、且记录ID小于 BeforeID 的记录也返回
+	Limit    int             // 返回条数，默认 100
+	Offset   int             // 跳过条数
+}
+
+// StoreReceipts 将状态报告写入发送记录，用于 ReceiptHandler
+// 找不到对应的发送记录时忽略（可能不是通过 Client 发送的短信）
+func StoreReceipts(store MessageStore) ReceiptSink {
+	return ReceiptFunc(func(ctx context.Context, receipt *StatusResponse) error {
+		err := store.UpdateStatus(ctx, receipt)
+		if errors.Is(err, ErrMessageNotFound) {
+			return nil
+		}
+		return err
+	})
+}
+
+// newMessageRecord 根据发送请求和结果生成发送记录
+func newMessageRecord(req *SendRequest, resp *SendResponse, err error, codeParam string) *MessageRecord {
+	now := time.Now()
+	record := &MessageRecord{
+		Phone:       req.GetFullPhone(),
+		CountryCode: req.CountryCode,
+		BizID:       req.BizID,
+		Template:    req.Template,
+		Params:      maskCodeParam(req.Params, codeParam),
+		SignName:    req.SignName,
+		OutID:       req.OutID,
+		DeviceID:    req.DeviceID,
+		UserID:      req.UserID,
+		IP:          req.IP,
+		Status:      StatusFailed,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	if resp != nil {
+		record.MsgID = resp.MsgID
+		record.Provider = resp.Provider
+		record.ErrorCode = resp.ErrorCode
+		record.ErrorMsg = resp.ErrorMsg
+		if resp.Success {
+			record.Status = StatusPending
+			// 没有服务商消息ID无法匹配状态报告和对账结果
+			if resp.MsgID == "" {
+				record.Status = StatusUnknown
+			}
+		}
+	}
+	if err != nil {
+		record.Status = StatusFailed
+		record.ErrorCode = errorCode(err)
+		record.ErrorMsg = err.Error()
+	}
+	return record
+}
+
+// maskCodeParam 复制模板参数并隐藏验证码
+func maskCodeParam(params map[string]string, codeParam string) map[string]string {
+	if params == nil {
+		return nil
+	}
+	masked := make(map[string]string, len(params))
+	for k, v := range params {
+		masked[k] = v
+	}
+	if _, ok := masked[codeParam]; ok {
+		masked[codeParam] = "******"
+	}
+	return masked
+}
+
+// newMessageID 生成发送记录ID（毫秒时间戳 + 随机数，大致按时间有序）
+func newMessageID() string {
+	random := make([]byte, 6)
+	_, _ = rand.Read(random)
+	return strconv.FormatInt(time.Now().UnixMilli(), 36) + hex.EncodeToString(random)
+}
+
+// statusPhone 状态更新对应的手机号（E.164 格式，与 MessageRecord.Phone 一致），未填写时返回空
+func statusPhone(status *StatusResponse) string {
+	if status.Phone == "" {
+		return ""
+	}
+	return normalizePhone(status.Phone, "")
+}
+
+// applyStatus 将状态更新应用到发送记录
+func applyStatus(record *MessageRecord, status *StatusResponse) {
+	record.Status = status.Status
+	if status.Status == StatusFailed && status.ErrorMsg != "" {
+		record.ErrorMsg = status.ErrorMsg
+	}
+	if record.Provider == "" {
+		record.Provider = status.Provider
+	}
+	record.UpdatedAt = time.Now()
+}
+
+// phone 查询的手机号（E.164 格式），未设置时返回空
+func (q *MessageQuery) phone() string {
+	if q.Phone == "" {
+		return ""
+	}
+	return normalizePhone(q.Phone, "")
+}
+
+// matches 判断发送记录是否满足查询条件
+func (q *MessageQuery) matches(record *MessageRecord) bool {
+	if phone := q.phone(); phone != "" && record.Phone != phone {
+		return false
+	}
+	if q.BizID != "" && record.BizID != q.BizID {
+		return false
+	}
+	if q.Provider != "" && record.Provider != q.Provider {
+		return false
+	}
+	if !q.Start.IsZero() && record.CreatedAt.Before(q.Start) {
+		return false
+	}
+	if !q.End.IsZero() && !q.beforeEnd(record) {
+		return false
+	}
+	if len(q.Status) > 0 {
+		for _, status := range q.Status {
+			if record.Status == status {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// beforeEnd 判断发送记录是否在截止位置之前
+func (q *MessageQuery) beforeEnd(record *MessageRecord) bool {
+	if q.BeforeID == "" {
+		return record.CreatedAt.Before(q.End)
+	}
+	created, end := record.CreatedAt.UnixMilli(), q.End.UnixMilli()
+	return created < end || (created == end && record.ID < q.BeforeID)
+}
+
+// limit 获取返回条数
+func (q *MessageQuery) limit() int {
+	if q.Limit <= 0 {
+		return 100
+	}
+	return q.Limit
+}
@@ -0,0 +1,316 @@
+package sms
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisMessageStore 基于 Redis 的发送记录存储
+// 记录以 JSON 保存，按手机号、业务、状态建立有序集合索引（按发送时间排序），全部记录的索引按天拆分；
+// 记录和索引超过保存时间自动过期
+type RedisMessageStore struct {
+	redis     *redis.Client
+	retention time.Duration
+}
+
+// NewRedisMessageStore 创建 Redis 发送记录存储，retention 为记录保存时间，默认 30 天
+func NewRedisMessageStore(redis *redis.Client, retention time.Duration) *RedisMessageStore {
+	if retention <= 0 {
+		retention = 30 * 24 * time.Hour
+	}
+	return &RedisMessageStore{
+		redis:     redis,
+		retention: retention,
+	}
+}
+
+// Save 保存发送记录
+func (s *RedisMessageStore) Save(ctx context.Context, record *MessageRecord) error {
+	if record.ID == "" {
+		record.ID = newMessageID()
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	score := float64(record.CreatedAt.UnixMilli())
+	expired := strconv.FormatInt(time.Now().Add(-s.retention).UnixMilli(), 10)
+	member := redis.Z{Score: score, Member: record.ID}
+
+	pipe := s.redis.TxPipeline()
+	pipe.Set(ctx, getMessageKey(record.ID), data, s.retention)
+	if record.MsgID != "" {
+		pipe.HSet(ctx, getMessageMsgIDKey(record.MsgID), record.Phone, record.ID)
+		pipe.Expire(ctx, getMessageMsgIDKey(record.MsgID), s.retention)
+	}
+	for _, key := range s.indexKeys(record) {
+		pipe.ZAdd(ctx, key, member)
+		pipe.ZRemRangeByScore(ctx, key, "-inf", "("+expired)
+		pipe.Expire(ctx, key, s.retention)
+	}
+	// 按天拆分的索引在当天最后一条记录过期后整体过期
+	pipe.ZAdd(ctx, getMessageDayIndexKey(record.CreatedAt), member)
+	pipe.Expire(ctx, getMessageDayIndexKey(record.CreatedAt), s.retention+24*time.Hour)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// UpdateStatus 按 MsgID 和手机号（填写时）更新发送状态
+func (s *RedisMessageStore) UpdateStatus(ctx context.Context, status *StatusResponse) error {
+	ids, err := s.recordIDs(ctx, status.MsgID, statusPhone(status))
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := s.update(ctx, id, status); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// update 更新一条发送记录的状态
+func (s *RedisMessageStore) update(ctx context.Context, id string, status *StatusResponse) error {
+	key := getMessageKey(id)
+	return s.redis.Watch(ctx, func(tx *redis.Tx) error {
+		record, err := s.load(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		oldStatusKey := getMessageIndexKey("status", strconv.Itoa(int(record.Status)))
+		applyStatus(record, status)
+
+		data, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		ttl, err := tx.PTTL(ctx, key).Result()
+		if err != nil {
+			return err
+		}
+		if ttl <= 0 {
+			ttl = s.retention
+		}
+
+		newStatusKey := getMessageIndexKey("status", strconv.Itoa(int(record.Status)))
+		expired := strconv.FormatInt(time.Now().Add(-s.retention).UnixMilli(), 10)
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, data, ttl)
+			pipe.ZRem(ctx, oldStatusKey, id)
+			pipe.ZAdd(ctx, newStatusKey, redis.Z{Score: float64(record.CreatedAt.UnixMilli()), Member: id})
+			pipe.ZRemRangeByScore(ctx, newStatusKey, "-inf", "("+expired)
+			pipe.Expire(ctx, newStatusKey, s.retention)
+			return nil
+		})
+		return err
+	}, key)
+}
+
+// Get 按记录ID查询
+func (s *RedisMessageStore) Get(ctx context.Context, id string) (*MessageRecord, error) {
+	return s.load(ctx, s.redis, id)
+}
+
+// GetByMsgID 按服务商消息ID查询（同一 MsgID 有多条记录时返回最新的一条）
+func (s *RedisMessageStore) GetByMsgID(ctx context.Context, msgID string) (*MessageRecord, error) {
+	ids, err := s.recordIDs(ctx, msgID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var latest *MessageRecord
+	for _, id := range ids {
+		record, err := s.load(ctx, s.redis, id)
+		if err == ErrMessageNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if latest == nil || record.CreatedAt.After(latest.CreatedAt) {
+			latest = record
+		}
+	}
+	if latest == nil {
+		return nil, ErrMessageNotFound
+	}
+	return latest, nil
+}
+
+// recordIDs 按 MsgID 和手机号（为空时不限）查找记录ID，找不到时返回 ErrMessageNotFound
+func (s *RedisMessageStore) recordIDs(ctx context.Context, msgID, phone string) ([]string, error) {
+	if msgID == "" {
+		return nil, ErrMessageNotFound
+	}
+
+	key := getMessageMsgIDKey(msgID)
+	if phone != "" {
+		id, err := s.redis.HGet(ctx, key, phone).Result()
+		if err == redis.Nil {
+			return nil, ErrMessageNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		return []string{id}, nil
+	}
+
+	ids, err := s.redis.HVals(ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, ErrMessageNotFound
+	}
+	return ids, nil
+}
+
+// Query 按条件查询
+// 使用最精确的索引（手机号 > 业务 > 状态 > 按天的全部记录）按时间、记录ID倒序扫描，其余条件逐条过滤
+func (s *RedisMessageStore) Query(ctx context.Context, query *MessageQuery) ([]*MessageRecord, error) {
+	min, max := "-inf", "+inf"
+	if !query.Start.IsZero() {
+		min = strconv.FormatInt(query.Start.UnixMilli(), 10)
+	}
+	if !query.End.IsZero() {
+		max = "(" + strconv.FormatInt(query.End.UnixMilli(), 10)
+		// 同一毫秒的记录按记录ID倒序（有序集合分数相同时按成员倒序），由 matches 过滤
+		if query.BeforeID != "" {
+			max = strconv.FormatInt(query.End.UnixMilli(), 10)
+		}
+	}
+
+	var (
+		records []*MessageRecord
+		skipped int
+		limit   = query.limit()
+		batch   = int64(limit + query.Offset)
+	)
+	for _, key := range s.queryKeys(query) {
+		for offset := int64(0); ; offset += batch {
+			ids, err := s.redis.ZRevRangeByScore(ctx, key, &redis.ZRangeBy{
+				Min:    min,
+				Max:    max,
+				Offset: offset,
+				Count:  batch,
+			}).Result()
+			if err != nil {
+				return nil, err
+			}
+
+			for _, id := range ids {
+				record, err := s.load(ctx, s.redis, id)
+				if err == ErrMessageNotFound {
+					continue
+				}
+				if err != nil {
+					return nil, err
+				}
+				if !query.matches(record) {
+					continue
+				}
+				if skipped < query.Offset {
+					skipped++
+					continue
+				}
+				records = append(records, record)
+				if len(records) >= limit {
+					return records, nil
+				}
+			}
+
+			if int64(len(ids)) < batch {
+				break
+			}
+		}
+	}
+	return records, nil
+}
+
+// load 读取发送记录
+func (s *RedisMessageStore) load(ctx context.Context, cmd redis.Cmdable, id string) (*MessageRecord, error) {
+	data, err := cmd.Get(ctx, getMessageKey(id)).Bytes()
+	if err == redis.Nil {
+		return nil, ErrMessageNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var record MessageRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// indexKeys 发送记录需要写入的索引（不含按天拆分的全部记录索引）
+func (s *RedisMessageStore) indexKeys(record *MessageRecord) []string {
+	keys := []string{
+		getMessageIndexKey("phone", record.Phone),
+		getMessageIndexKey("status", strconv.Itoa(int(record.Status))),
+	}
+	if record.BizID != "" {
+		keys = append(keys, getMessageIndexKey("biz", record.BizID))
+	}
+	return keys
+}
+
+// queryKeys 查询使用的索引，没有可用的条件索引时按天倒序扫描保存时间内的全部记录索引
+func (s *RedisMessageStore) queryKeys(query *MessageQuery) []string {
+	switch {
+	case query.Phone != "":
+		return []string{getMessageIndexKey("phone", query.phone())}
+	case query.BizID != "":
+		return []string{getMessageIndexKey("biz", query.BizID)}
+	case len(query.Status) == 1:
+		return []string{getMessageIndexKey("status", strconv.Itoa(int(query.Status[0])))}
+	}
+
+	now := time.Now()
+	end := now
+	if !query.End.IsZero() && query.End.Before(end) {
+		end = query.End
+	}
+	start := now.Add(-s.retention)
+	if query.Start.After(start) {
+		start = query.Start
+	}
+	y, m, d := start.UTC().Date()
+	first := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+
+	var keys []string
+	for day := end; !day.Before(first); day = day.AddDate(0, 0, -1) {
+		keys = append(keys, getMessageDayIndexKey(day))
+	}
+	return keys
+}
+
+// getMessageKey 获取发送记录的key
+func getMessageKey(id string) string {
+	return "sms:message:" + id
+}
+
+// getMessageMsgIDKey 获取服务商消息ID索引的key（Hash：手机号 -> 记录ID）
+func getMessageMsgIDKey(msgID string) string {
+	return "sms:message:msg:" + msgID
+}
+
+// getMessageDayIndexKey 获取按天（UTC）拆分的全部记录索引的key
+func getMessageDayIndexKey(t time.Time) string {
+	return getMessageIndexKey("all", t.UTC().Format("20060102"))
+}
+
+// getMessageIndexKey 获取发送记录索引的key
+func getMessageIndexKey(index, value string) string {
+	if value == "" {
+		return "sms:message:index:" + index
+	}
+	return "sms:message:index:" + index + ":" + value
+}
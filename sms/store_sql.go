@@ -0,0 +1,268 @@
+package sms
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MessageTableSchema 发送记录表结构（MySQL 语法，其他数据库按需调整类型），时间字段为毫秒时间戳
+const MessageTableSchema = `CREATE TABLE IF NOT EXISTS sms_messages (
+	id           VARCHAR(32)  NOT NULL PRIMARY KEY,
+	msg_id       VARCHAR(64)  NOT NULL DEFAULT '',
+	phone        VARCHAR(32)  NOT NULL,
+	country_code VARCHAR(8)   NOT NULL DEFAULT '',
+	biz_id       VARCHAR(64)  NOT NULL DEFAULT '',
+	template     VARCHAR(64)  NOT NULL DEFAULT '',
+	params       TEXT,
+	sign_name    VARCHAR(64)  NOT NULL DEFAULT '',
+	out_id       VARCHAR(64)  NOT NULL DEFAULT '',
+	device_id    VARCHAR(128) NOT NULL DEFAULT '',
+	user_id      VARCHAR(64)  NOT NULL DEFAULT '',
+	ip           VARCHAR(64)  NOT NULL DEFAULT '',
+	provider     VARCHAR(32)  NOT NULL DEFAULT '',
+	status       INT          NOT NULL,
+	error_code   VARCHAR(128) NOT NULL DEFAULT '',
+	error_msg    VARCHAR(512) NOT NULL DEFAULT '',
+	created_at   BIGINT       NOT NULL,
+	updated_at   BIGINT       NOT NULL,
+	INDEX idx_msg_id (msg_id),
+	INDEX idx_phone_created (phone, created_at),
+	INDEX idx_biz_created (biz_id, created_at),
+	INDEX idx_status_created (status, created_at)
+)`
+
+// messageColumns 发送记录表字段（顺序与 scanMessage 一致）
+var messageColumns = []string{
+	"id", "msg_id", "phone", "country_code", "biz_id", "template", "params", "sign_name", "out_id",
+	"device_id", "user_id", "ip", "provider", "status", "error_code", "error_msg", "created_at", "updated_at",
+}
+
+// SQLMessageStore 基于 database/sql 的发送记录存储，表结构见 MessageTableSchema
+type SQLMessageStore struct {
+	db     *sql.DB
+	config *SQLStoreConfig
+}
+
+// SQLStoreConfig 数据库发送记录存储配置
+type SQLStoreConfig struct {
+	Table       string // 表名，默认 sms_messages
+	Placeholder string // 参数占位符：?（默认，MySQL/SQLite）或 $（PostgreSQL，生成 $1、$2）
+}
+
+// NewSQLMessageStore 创建数据库发送记录存储
+func NewSQLMessageStore(db *sql.DB, config *SQLStoreConfig) *SQLMessageStore {
+	if config == nil {
+		config = &SQLStoreConfig{}
+	}
+	// 复制配置，填充默认值不影响调用方
+	cloned := *config
+	config = &cloned
+	if config.Table == "" {
+		config.Table = "sms_messages"
+	}
+	if config.Placeholder == "" {
+		config.Placeholder = "?"
+	}
+	return &SQLMessageStore{
+		db:     db,
+		config: config,
+	}
+}
+
+// Save 保存发送记录
+func (s *SQLMessageStore) Save(ctx context.Context, record *MessageRecord) error {
+	if record.ID == "" {
+		record.ID = newMessageID()
+	}
+	params, err := json.Marshal(record.Params)
+	if err != nil {
+		return err
+	}
+
+	placeholders := make([]string, len(messageColumns))
+	for i := range placeholders {
+		placeholders[i] = s.placeholder(i + 1)
+	}
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		s.config.Table, strings.Join(messageColumns, ", "), strings.Join(placeholders, ", "))
+
+	_, err = s.db.ExecContext(ctx, query,
+		record.ID, record.MsgID, record.Phone, record.CountryCode, record.BizID, record.Template, string(params),
+		record.SignName, record.OutID, record.DeviceID, record.UserID, record.IP, record.Provider,
+		int(record.Status), record.ErrorCode, record.ErrorMsg, record.CreatedAt.UnixMilli(), record.UpdatedAt.UnixMilli())
+	return err
+}
+
+// UpdateStatus 按 MsgID 和手机号（填写时）更新发送状态
+func (s *SQLMessageStore) UpdateStatus(ctx context.Context, status *StatusResponse) error {
+	if status.MsgID == "" {
+		return ErrMessageNotFound
+	}
+
+	statement, args := s.buildUpdate(status)
+	result, err := s.db.ExecContext(ctx, statement, args...)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrMessageNotFound
+	}
+	return nil
+}
+
+// Get 按记录ID查询
+func (s *SQLMessageStore) Get(ctx context.Context, id string) (*MessageRecord, error) {
+	return s.get(ctx, "id", id)
+}
+
+// GetByMsgID 按服务商消息ID查询（同一 MsgID 有多条记录时返回最新的一条）
+func (s *SQLMessageStore) GetByMsgID(ctx context.Context, msgID string) (*MessageRecord, error) {
+	return s.get(ctx, "msg_id", msgID)
+}
+
+// Query 按条件查询
+func (s *SQLMessageStore) Query(ctx context.Context, query *MessageQuery) ([]*MessageRecord, error) {
+	statement, args := s.buildQuery(query)
+	rows, err := s.db.QueryContext(ctx, statement, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []*MessageRecord
+	for rows.Next() {
+		record, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+// get 按字段查询一条记录
+func (s *SQLMessageStore) get(ctx context.Context, column, value string) (*MessageRecord, error) {
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s = %s ORDER BY created_at DESC LIMIT 1",
+		strings.Join(messageColumns, ", "), s.config.Table, column, s.placeholder(1))
+
+	record, err := scanMessage(s.db.QueryRowContext(ctx, query, value))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrMessageNotFound
+	}
+	return record, err
+}
+
+// buildUpdate 生成状态更新语句
+func (s *SQLMessageStore) buildUpdate(status *StatusResponse) (string, []any) {
+	// 与 applyStatus 一致：只有失败时覆盖错误信息，服务商名称只在原记录为空时写入
+	var errorMsg string
+	if status.Status == StatusFailed {
+		errorMsg = status.ErrorMsg
+	}
+	statement := fmt.Sprintf(
+		"UPDATE %s SET status = %s, error_msg = CASE WHEN %s <> '' THEN %s ELSE error_msg END, "+
+			"provider = CASE WHEN provider = '' THEN %s ELSE provider END, updated_at = %s WHERE msg_id = %s",
+		s.config.Table, s.placeholder(1), s.placeholder(2), s.placeholder(3), s.placeholder(4), s.placeholder(5), s.placeholder(6))
+	args := []any{int(status.Status), errorMsg, errorMsg, status.Provider, time.Now().UnixMilli(), status.MsgID}
+
+	// 批量发送的接收人共用 MsgID，按手机号区分
+	if phone := statusPhone(status); phone != "" {
+		statement += " AND phone = " + s.placeholder(7)
+		args = append(args, phone)
+	}
+	return statement, args
+}
+
+// buildQuery 生成查询语句
+func (s *SQLMessageStore) buildQuery(query *MessageQuery) (string, []any) {
+	var (
+		conditions []string
+		args       []any
+	)
+	add := func(condition string, values ...any) {
+		for _, value := range values {
+			args = append(args, value)
+			condition = strings.Replace(condition, "?", s.placeholder(len(args)), 1)
+		}
+		conditions = append(conditions, condition)
+	}
+
+	if phone := query.phone(); phone != "" {
+		add("phone = ?", phone)
+	}
+	if query.BizID != "" {
+		add("biz_id = ?", query.BizID)
+	}
+	if query.Provider != "" {
+		add("provider = ?", query.Provider)
+	}
+	if len(query.Status) > 0 {
+		statuses := make([]any, len(query.Status))
+		for i, status := range query.Status {
+			statuses[i] = int(status)
+		}
+		add("status IN ("+strings.TrimSuffix(strings.Repeat("?, ", len(statuses)), ", ")+")", statuses...)
+	}
+	if !query.Start.IsZero() {
+		add("created_at >= ?", query.Start.UnixMilli())
+	}
+	if !query.End.IsZero() && query.BeforeID != "" {
+		add("(created_at < ? OR (created_at = ? AND id < ?))", query.End.UnixMilli(), query.End.UnixMilli(), query.BeforeID)
+	} else if !query.End.IsZero() {
+		add("created_at < ?", query.End.UnixMilli())
+	}
+
+	statement := fmt.Sprintf("SELECT %s FROM %s", strings.Join(messageColumns, ", "), s.config.Table)
+	if len(conditions) > 0 {
+		statement += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	statement += " ORDER BY created_at DESC, id DESC LIMIT " + strconv.Itoa(query.limit())
+	if query.Offset > 0 {
+		statement += " OFFSET " + strconv.Itoa(query.Offset)
+	}
+	return statement, args
+}
+
+// placeholder 获取第 n 个参数的占位符
+func (s *SQLMessageStore) placeholder(n int) string {
+	if s.config.Placeholder == "$" {
+		return "$" + strconv.Itoa(n)
+	}
+	return "?"
+}
+
+// scanMessage 读取一条发送记录
+func scanMessage(row interface{ Scan(dest ...any) error }) (*MessageRecord, error) {
+	var (
+		record               MessageRecord
+		params               sql.NullString
+		status               int
+		createdAt, updatedAt int64
+	)
+	err := row.Scan(&record.ID, &record.MsgID, &record.Phone, &record.CountryCode, &record.BizID, &record.Template,
+		&params, &record.SignName, &record.OutID, &record.DeviceID, &record.UserID, &record.IP, &record.Provider,
+		&status, &record.ErrorCode, &record.ErrorMsg, &createdAt, &updatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if params.Valid && params.String != "" {
+		if err := json.Unmarshal([]byte(params.String), &record.Params); err != nil {
+			return nil, err
+		}
+	}
+	record.Status = MessageStatus(status)
+	record.CreatedAt = time.UnixMilli(createdAt)
+	record.UpdatedAt = time.UnixMilli(updatedAt)
+	return &record, nil
+}
@@ -0,0 +1,303 @@
+package sms
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+)
+
+func TestNewMessageRecord(t *testing.T) {
+	req := &SendRequest{
+		Phone:    "13800138000",
+		BizID:    "login",
+		Template: "SMS_123",
+		Params:   map[string]string{"code": "123456", "name": "张三"},
+	}
+
+	record := newMessageRecord(req, &SendResponse{Success: true, MsgID: "m1", Provider: "aliyun"}, nil, "code")
+	assert.Equal(t, StatusPending, record.Status)
+	assert.Equal(t, "+8613800138000", record.Phone)
+	assert.Equal(t, "m1", record.MsgID)
+	assert.Equal(t, map[string]string{"code": "******", "name": "张三"}, record.Params)
+	assert.Equal(t, "123456", req.Params["code"], "不应修改原请求参数")
+
+	// 没有服务商消息ID的记录无法跟踪状态
+	record = newMessageRecord(req, &SendResponse{Success: true}, nil, "code")
+	assert.Equal(t, StatusUnknown, record.Status)
+
+	record = newMessageRecord(req, nil, NewSMSError("LIMIT", "发送太频繁", false, nil), "code")
+	assert.Equal(t, StatusFailed, record.Status)
+	assert.Equal(t, "LIMIT", record.ErrorCode)
+
+	record = newMessageRecord(req, nil, errors.New("network"), "code")
+	assert.Equal(t, StatusFailed, record.Status)
+	assert.Equal(t, "network", record.ErrorMsg)
+}
+
+func TestMessageQueryMatches(t *testing.T) {
+	now := time.Now()
+	record := &MessageRecord{Phone: "+8613800138000", BizID: "login", Status: StatusPending, CreatedAt: now}
+
+	assert.True(t, (&MessageQuery{}).matches(record))
+	assert.True(t, (&MessageQuery{Phone: "13800138000", Status: []MessageStatus{StatusFailed, StatusPending}}).matches(record))
+	assert.True(t, (&MessageQuery{Phone: "+86 138-0013-8000"}).matches(record))
+	assert.False(t, (&MessageQuery{Phone: "13800138001"}).matches(record))
+	assert.False(t, (&MessageQuery{BizID: "marketing"}).matches(record))
+	assert.False(t, (&MessageQuery{Status: []MessageStatus{StatusDelivered}}).matches(record))
+	assert.True(t, (&MessageQuery{Start: now, End: now.Add(time.Second)}).matches(record))
+	assert.False(t, (&MessageQuery{End: now}).matches(record))
+
+	// 分页游标：同一毫秒的记录按记录ID比较
+	record.ID = "b"
+	assert.True(t, (&MessageQuery{End: now, BeforeID: "c"}).matches(record))
+	assert.False(t, (&MessageQuery{End: now, BeforeID: "b"}).matches(record))
+}
+
+func TestSQLMessageStoreBuildQuery(t *testing.T) {
+	start := time.UnixMilli(1733625000000)
+	query := &MessageQuery{
+		Phone:  "13800138000",
+		Status: []MessageStatus{StatusPending, StatusFailed},
+		Start:  start,
+		Limit:  20,
+		Offset: 40,
+	}
+
+	statement, args := NewSQLMessageStore(nil, nil).buildQuery(query)
+	assert.Contains(t, statement, "FROM sms_messages WHERE phone = ? AND status IN (?, ?) AND created_at >= ? ORDER BY created_at DESC, id DESC LIMIT 20 OFFSET 40")
+	assert.Equal(t, []any{"+8613800138000", int(StatusPending), int(StatusFailed), int64(1733625000000)}, args)
+
+	config := &SQLStoreConfig{Table: "messages"}
+	NewSQLMessageStore(nil, config)
+	assert.Equal(t, SQLStoreConfig{Table: "messages"}, *config)
+
+	statement, _ = NewSQLMessageStore(nil, &SQLStoreConfig{Table: "messages", Placeholder: "$"}).buildQuery(query)
+	assert.Contains(t, statement, "FROM messages WHERE phone = $1 AND status IN ($2, $3) AND created_at >= $4")
+
+	// 分页游标
+	statement, args = NewSQLMessageStore(nil, nil).buildQuery(&MessageQuery{End: start, BeforeID: "abc"})
+	assert.Contains(t, statement, "WHERE (created_at < ? OR (created_at = ? AND id < ?)) ORDER BY created_at DESC, id DESC")
+	assert.Equal(t, []any{int64(1733625000000), int64(1733625000000), "abc"}, args)
+}
+
+func TestRedisMessageStoreQuery(t *testing.T) {
+	ctx := context.Background()
+	rdb, server := newTestRedis(t)
+	store := NewRedisMessageStore(rdb, 48*time.Hour)
+
+	now := time.Now()
+	records := []*MessageRecord{
+		newMessageRecord(&SendRequest{Phone: "13800138000", BizID: "login"}, &SendResponse{Success: true, MsgID: "m1"}, nil, ""),
+		newMessageRecord(&SendRequest{Phone: "+86 138 0013 8000", BizID: "marketing"}, &SendResponse{Success: true, MsgID: "m2"}, nil, ""),
+		newMessageRecord(&SendRequest{Phone: "13900139000", BizID: "login"}, nil, errors.New("network"), ""),
+	}
+	records[0].CreatedAt = now.Add(-26 * time.Hour)
+	records[1].CreatedAt = now.Add(-time.Minute)
+	for _, record := range records {
+		require.NoError(t, store.Save(ctx, record))
+	}
+
+	// 不同格式的手机号查到同一批记录
+	for _, phone := range []string{"13800138000", "+8613800138000", "0086 138-0013-8000"} {
+		found, err := store.Query(ctx, &MessageQuery{Phone: phone})
+		require.NoError(t, err)
+		require.Len(t, found, 2, phone)
+		assert.Equal(t, records[1].ID, found[0].ID)
+		assert.Equal(t, records[0].ID, found[1].ID)
+	}
+
+	// 全部记录索引按天拆分并设置过期时间，查询跨天倒序扫描
+	assert.True(t, server.Exists(getMessageDayIndexKey(records[0].CreatedAt)))
+	assert.True(t, server.Exists(getMessageDayIndexKey(now)))
+	assert.False(t, server.Exists(getMessageIndexKey("all", "")))
+	assert.Equal(t, 72*time.Hour, server.TTL(getMessageDayIndexKey(now)))
+
+	found, err := store.Query(ctx, &MessageQuery{})
+	require.NoError(t, err)
+	require.Len(t, found, 3)
+	assert.Equal(t, records[0].ID, found[2].ID)
+
+	found, err = store.Query(ctx, &MessageQuery{Start: now.Add(-time.Hour), Limit: 1, Offset: 1})
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	assert.Equal(t, records[1].ID, found[0].ID)
+
+	// 超过保存时间的记录不再扫描
+	server.FastForward(49 * time.Hour)
+	found, err = store.Query(ctx, &MessageQuery{})
+	require.NoError(t, err)
+	assert.Empty(t, found)
+}
+
+func TestRedisMessageStoreQueryCursor(t *testing.T) {
+	ctx := context.Background()
+	rdb, _ := newTestRedis(t)
+	store := NewRedisMessageStore(rdb, 0)
+
+	created := time.UnixMilli(time.Now().UnixMilli())
+	for _, id := range []string{"a", "b", "c", "d"} {
+		require.NoError(t, store.Save(ctx, &MessageRecord{ID: id, Phone: "+8613800138000", Status: StatusPending, CreatedAt: created}))
+	}
+
+	// 同一毫秒的记录按记录ID倒序分页
+	var ids []string
+	query := &MessageQuery{Status: []MessageStatus{StatusPending}, Limit: 3}
+	for {
+		records, err := store.Query(ctx, query)
+		require.NoError(t, err)
+		for _, record := range records {
+			ids = append(ids, record.ID)
+		}
+		if len(records) < query.Limit {
+			break
+		}
+		last := records[len(records)-1]
+		query.End, query.BeforeID = last.CreatedAt, last.ID
+	}
+	assert.Equal(t, []string{"d", "c", "b", "a"}, ids)
+}
+
+func TestRedisMessageStoreSharedMsgID(t *testing.T) {
+	ctx := context.Background()
+	rdb, _ := newTestRedis(t)
+	store := NewRedisMessageStore(rdb, 0)
+
+	// 批量发送的接收人共用 MsgID
+	first := newMessageRecord(&SendRequest{Phone: "13800138000"}, &SendResponse{Success: true, MsgID: "batch"}, nil, "")
+	second := newMessageRecord(&SendRequest{Phone: "13800138001"}, &SendResponse{Success: true, MsgID: "batch"}, nil, "")
+	require.NoError(t, store.Save(ctx, first))
+	require.NoError(t, store.Save(ctx, second))
+
+	// 按手机号（任意格式）更新对应的记录
+	require.NoError(t, store.UpdateStatus(ctx, &StatusResponse{MsgID: "batch", Phone: "8613800138001", Status: StatusDelivered}))
+	record, err := store.Get(ctx, first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusPending, record.Status)
+	record, err = store.Get(ctx, second.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusDelivered, record.Status)
+
+	assert.ErrorIs(t, store.UpdateStatus(ctx, &StatusResponse{MsgID: "batch", Phone: "13900139000", Status: StatusDelivered}), ErrMessageNotFound)
+	assert.ErrorIs(t, store.UpdateStatus(ctx, &StatusResponse{MsgID: "other", Status: StatusDelivered}), ErrMessageNotFound)
+
+	// 未填写手机号时更新所有记录
+	require.NoError(t, store.UpdateStatus(ctx, &StatusResponse{MsgID: "batch", Status: StatusUnknown}))
+	records, err := store.Query(ctx, &MessageQuery{Status: []MessageStatus{StatusUnknown}})
+	require.NoError(t, err)
+	assert.Len(t, records, 2)
+
+	_, err = store.GetByMsgID(ctx, "batch")
+	require.NoError(t, err)
+
+}
+
+// newTestSQLStore 创建使用内存 SQLite 的发送记录存储
+func newTestSQLStore(t *testing.T) *SQLMessageStore {
+	t.Helper()
+	db, err := sql.Open("sqlite", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1) // 每个连接是独立的内存数据库
+	t.Cleanup(func() { _ = db.Close() })
+
+	// SQLite 不支持建表语句中的 INDEX，去掉索引定义
+	var lines []string
+	for _, line := range strings.Split(MessageTableSchema, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "INDEX") {
+			lines = append(lines, line)
+		}
+	}
+	schema := strings.Join(lines, "\n")
+	schema = schema[:strings.LastIndex(schema, ",")] + "\n)"
+	_, err = db.Exec(schema)
+	require.NoError(t, err)
+	return NewSQLMessageStore(db, nil)
+}
+
+func TestSQLMessageStore(t *testing.T) {
+	ctx := context.Background()
+	store := newTestSQLStore(t)
+
+	now := time.Now()
+	records := []*MessageRecord{
+		newMessageRecord(&SendRequest{Phone: "13800138000", BizID: "notice", Template: "SMS_1", Params: map[string]string{"name": "张三"}},
+			&SendResponse{Success: true, MsgID: "batch", Provider: "aliyun"}, nil, ""),
+		newMessageRecord(&SendRequest{Phone: "13900139000", BizID: "notice", Template: "SMS_1"},
+			&SendResponse{Success: true, MsgID: "batch", Provider: "aliyun"}, nil, ""),
+		newMessageRecord(&SendRequest{Phone: "13800138000", BizID: "login"}, nil, NewSMSError("NETWORK_ERROR", "网络错误", true, nil), ""),
+	}
+	for i, record := range records {
+		record.CreatedAt = now.Add(-time.Duration(len(records)-i) * time.Minute)
+		record.UpdatedAt = record.CreatedAt
+		require.NoError(t, store.Save(ctx, record))
+		assert.NotEmpty(t, record.ID)
+	}
+
+	// 字段、时间（毫秒）和参数原样读回
+	record, err := store.Get(ctx, records[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, "+8613800138000", record.Phone)
+	assert.Equal(t, "batch", record.MsgID)
+	assert.Equal(t, "aliyun", record.Provider)
+	assert.Equal(t, StatusPending, record.Status)
+	assert.Equal(t, map[string]string{"name": "张三"}, record.Params)
+	assert.Equal(t, records[0].CreatedAt.UnixMilli(), record.CreatedAt.UnixMilli())
+
+	_, err = store.Get(ctx, "missing")
+	assert.ErrorIs(t, err, ErrMessageNotFound)
+
+	// 按手机号（不同格式）查询，按发送时间倒序
+	found, err := store.Query(ctx, &MessageQuery{Phone: "+86 138 0013 8000"})
+	require.NoError(t, err)
+	require.Len(t, found, 2)
+	assert.Equal(t, records[2].ID, found[0].ID)
+	assert.Equal(t, StatusFailed, found[0].Status)
+	assert.Equal(t, "NETWORK_ERROR", found[0].ErrorCode)
+	assert.Equal(t, records[0].ID, found[1].ID)
+
+	// 按 MsgID + 手机号更新，同一批次的其他接收人不受影响
+	require.NoError(t, store.UpdateStatus(ctx, &StatusResponse{MsgID: "batch", Phone: "13900139000", Status: StatusFailed, ErrorMsg: "MK:0005"}))
+	record, err = store.Get(ctx, records[1].ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusFailed, record.Status)
+	assert.Equal(t, "MK:0005", record.ErrorMsg)
+	assert.True(t, record.UpdatedAt.After(records[1].UpdatedAt))
+
+	found, err = store.Query(ctx, &MessageQuery{Status: []MessageStatus{StatusPending}})
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	assert.Equal(t, records[0].ID, found[0].ID)
+
+	assert.ErrorIs(t, store.UpdateStatus(ctx, &StatusResponse{MsgID: "batch", Phone: "13700137000", Status: StatusDelivered}), ErrMessageNotFound)
+	assert.ErrorIs(t, store.UpdateStatus(ctx, &StatusResponse{MsgID: "missing", Status: StatusDelivered}), ErrMessageNotFound)
+
+	// 未填写手机号时更新整批记录
+	require.NoError(t, store.UpdateStatus(ctx, &StatusResponse{MsgID: "batch", Status: StatusDelivered}))
+	found, err = store.Query(ctx, &MessageQuery{BizID: "notice", Status: []MessageStatus{StatusDelivered}})
+	require.NoError(t, err)
+	assert.Len(t, found, 2)
+	record, err = store.GetByMsgID(ctx, "batch")
+	require.NoError(t, err)
+	assert.Equal(t, StatusDelivered, record.Status)
+}
+
+func TestSQLMessageStoreBuildUpdate(t *testing.T) {
+	store := NewSQLMessageStore(nil, &SQLStoreConfig{Placeholder: "$"})
+
+	statement, args := store.buildUpdate(&StatusResponse{MsgID: "batch", Phone: "138 0013 8000", Status: StatusFailed, ErrorMsg: "MK:0005"})
+	assert.Contains(t, statement, "WHERE msg_id = $6 AND phone = $7")
+	require.Len(t, args, 7)
+	assert.Equal(t, "MK:0005", args[1])
+	assert.Equal(t, "batch", args[5])
+	assert.Equal(t, "+8613800138000", args[6])
+
+	statement, args = store.buildUpdate(&StatusResponse{MsgID: "batch", Status: StatusDelivered})
+	assert.True(t, strings.HasSuffix(statement, "WHERE msg_id = $6"))
+	assert.Len(t, args, 6)
+}
@@ -0,0 +1,81 @@
+package sms
+
+import (
+	"context"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// SuppressionList 免打扰名单（退订名单）
+// 名单按业务划分，bizID 为空表示全局名单（所有业务都不发送）；Client.Send 发送前会检查全局名单和请求业务的名单
+// 豁免全局名单的业务（见 Exempt）只检查业务自己的名单
+type SuppressionList struct {
+	redis *redis.Client
+
+	mu     sync.RWMutex
+	exempt map[string]bool // 豁免全局名单的业务
+}
+
+// NewSuppressionList 创建免打扰名单
+func NewSuppressionList(redis *redis.Client) *SuppressionList {
+	return &SuppressionList{
+		redis:  redis,
+		exempt: make(map[string]bool),
+	}
+}
+
+// Exempt 设置豁免全局名单的业务（如登录、注册等验证码业务），用户全局退订后仍然可以收到这些业务的短信
+func (s *SuppressionList) Exempt(bizIDs ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, bizID := range bizIDs {
+		if bizID != "" {
+			s.exempt[bizID] = true
+		}
+	}
+}
+
+// exempted 判断业务是否豁免全局名单
+func (s *SuppressionList) exempted(bizID string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.exempt[bizID]
+}
+
+// Add 将手机号加入名单，bizID 为空时加入全局名单
+func (s *SuppressionList) Add(ctx context.Context, phone, bizID string) error {
+	return s.redis.SAdd(ctx, getSuppressionKey(bizID), normalizePhone(phone, "")).Err()
+}
+
+// Remove 将手机号移出名单
+func (s *SuppressionList) Remove(ctx context.Context, phone, bizID string) error {
+	return s.redis.SRem(ctx, getSuppressionKey(bizID), normalizePhone(phone, "")).Err()
+}
+
+// IsSuppressed 判断手机号在该业务下是否免打扰（全局名单或业务名单，豁免的业务只检查业务名单）
+func (s *SuppressionList) IsSuppressed(ctx context.Context, phone, bizID string) (bool, error) {
+	phone = normalizePhone(phone, "")
+	if bizID == "" {
+		return s.redis.SIsMember(ctx, getSuppressionKey(""), phone).Result()
+	}
+	if s.exempted(bizID) {
+		return s.redis.SIsMember(ctx, getSuppressionKey(bizID), phone).Result()
+	}
+
+	pipe := s.redis.Pipeline()
+	global := pipe.SIsMember(ctx, getSuppressionKey(""), phone)
+	biz := pipe.SIsMember(ctx, getSuppressionKey(bizID), phone)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, err
+	}
+	return global.Val() || biz.Val(), nil
+}
+
+// getSuppressionKey 获取免打扰名单的key
+func getSuppressionKey(bizID string) string {
+	if bizID == "" {
+		return "sms:suppression:global"
+	}
+	return "sms:suppression:biz:" + bizID
+}
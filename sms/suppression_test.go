@@ -0,0 +1,49 @@
+package sms
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnsubscribeDefaultScope(t *testing.T) {
+	ctx := context.Background()
+	rdb, _ := newTestRedis(t)
+	provider := &stubProvider{}
+	client := NewClient(&ClientConfig{Redis: rdb, Provider: provider})
+	list := NewSuppressionList(rdb)
+
+	// 未指定业务时退订只影响营销类短信
+	router := NewKeywordRouter()
+	router.HandleUnsubscribe(list)
+	require.NoError(t, router.HandleInbound(ctx, &InboundMessage{Phone: "13800138000", CountryCode: "+86", Content: "TD"}))
+
+	_, err := client.Send(ctx, &SendRequest{Phone: "13800138000", Template: "SMS_123", BizID: DefaultUnsubscribeBizID})
+	assert.ErrorIs(t, err, ErrPhoneSuppressed)
+	_, err = client.Send(ctx, &SendRequest{Phone: "13800138000", Template: "SMS_123", BizID: "login"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, provider.calls)
+}
+
+func TestSuppressionExempt(t *testing.T) {
+	ctx := context.Background()
+	rdb, _ := newTestRedis(t)
+	provider := &stubProvider{}
+	client := NewClient(&ClientConfig{Redis: rdb, Provider: provider, SuppressionExempt: []string{"login"}})
+
+	// 全局退订后，豁免的业务仍然可以发送，但业务自己的名单仍然生效
+	list := NewSuppressionList(rdb)
+	require.NoError(t, NewUnsubscribeHandler(list, "").HandleInbound(ctx, &InboundMessage{Phone: "13800138000", Content: "TD"}))
+
+	_, err := client.Send(ctx, &SendRequest{Phone: "13800138000", Template: "SMS_123", BizID: "login"})
+	require.NoError(t, err)
+	_, err = client.Send(ctx, &SendRequest{Phone: "13800138000", Template: "SMS_123", BizID: "notify"})
+	assert.ErrorIs(t, err, ErrPhoneSuppressed)
+
+	require.NoError(t, list.Add(ctx, "+86 138 0013 8000", "login"))
+	_, err = client.Send(ctx, &SendRequest{Phone: "13800138000", Template: "SMS_123", BizID: "login"})
+	assert.ErrorIs(t, err, ErrPhoneSuppressed)
+	assert.Equal(t, 1, provider.calls)
+}
@@ -0,0 +1,162 @@
+package sms
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// templateVarPattern 模板变量占位符：${name}
+var templateVarPattern = regexp.MustCompile(`\$\{(\w+)\}`)
+
+// Template 短信模板（与短信平台配置的模板保持一致）
+type Template struct {
+	Code       string         // 模板ID
+	Content    string         // 模板内容，变量使用 ${name} 占位，如：您的验证码为${code}，5分钟内有效
+	Required   []string       // 必填参数（可选，默认为内容中的全部变量）
+	MaxLengths map[string]int // 参数最大长度（按字符计，可选），如阿里云验证码类变量最长 6 位
+	SignName   string         // 默认签名（可选，请求未指定签名时用于计算计费条数）
+}
+
+// Vars 模板内容中的变量（按出现顺序，去重）
+func (t *Template) Vars() []string {
+	var (
+		vars []string
+		seen = make(map[string]bool)
+	)
+	for _, match := range templateVarPattern.FindAllStringSubmatch(t.Content, -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			vars = append(vars, match[1])
+		}
+	}
+	return vars
+}
+
+// Validate 校验模板参数：必填参数不能为空、参数不能超过最大长度、不能包含模板中不存在的参数（通常是拼写错误）
+func (t *Template) Validate(params map[string]string) error {
+	vars := t.Vars()
+	known := make(map[string]bool, len(vars))
+	for _, name := range vars {
+		known[name] = true
+	}
+
+	required := t.Required
+	if required == nil {
+		required = vars
+	}
+	var missing []string
+	for _, name := range required {
+		if params[name] == "" {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return templateError("TEMPLATE_MISSING_PARAMETERS",
+			fmt.Sprintf("模板 %s 缺少参数: %s", t.Code, strings.Join(missing, ", ")))
+	}
+
+	var unknown []string
+	for name, value := range params {
+		if !known[name] {
+			unknown = append(unknown, name)
+			continue
+		}
+		if limit, ok := t.MaxLengths[name]; ok && utf8.RuneCountInString(value) > limit {
+			return templateError("TEMPLATE_PARAM_TOO_LONG",
+				fmt.Sprintf("模板 %s 参数 %s 超过最大长度 %d", t.Code, name, limit))
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return templateError("TEMPLATE_UNKNOWN_PARAMETERS",
+			fmt.Sprintf("模板 %s 不存在参数: %s", t.Code, strings.Join(unknown, ", ")))
+	}
+	return nil
+}
+
+// Render 使用参数替换模板变量，生成最终发送的短信内容（不含签名）
+func (t *Template) Render(params map[string]string) (string, error) {
+	if err := t.Validate(params); err != nil {
+		return "", err
+	}
+	return templateVarPattern.ReplaceAllStringFunc(t.Content, func(placeholder string) string {
+		return params[placeholder[2:len(placeholder)-1]]
+	}), nil
+}
+
+// TemplateRegistry 本地模板注册表
+// 配置到 ClientConfig.Templates 后，Send 在消耗限流次数和配额之前校验模板参数；未注册的模板不校验
+type TemplateRegistry struct {
+	mu        sync.RWMutex
+	templates map[string]*Template
+}
+
+// NewTemplateRegistry 创建模板注册表
+func NewTemplateRegistry(templates ...*Template) *TemplateRegistry {
+	r := &TemplateRegistry{
+		templates: make(map[string]*Template),
+	}
+	for _, t := range templates {
+		r.Register(t)
+	}
+	return r
+}
+
+// Register 注册模板，相同模板ID重复注册时覆盖
+func (r *TemplateRegistry) Register(t *Template) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.templates[t.Code] = t
+}
+
+// Get 获取模板
+func (r *TemplateRegistry) Get(code string) (*Template, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	t, ok := r.templates[code]
+	return t, ok
+}
+
+// Validate 校验发送请求的模板参数，模板未注册时不校验
+func (r *TemplateRegistry) Validate(req *SendRequest) error {
+	t, ok := r.Get(req.Template)
+	if !ok {
+		return nil
+	}
+	return t.Validate(req.Params)
+}
+
+// Render 预览短信内容（不含签名），模板未注册时返回错误
+func (r *TemplateRegistry) Render(code string, params map[string]string) (string, error) {
+	t, ok := r.Get(code)
+	if !ok {
+		return "", templateError("TEMPLATE_NOT_FOUND", fmt.Sprintf("模板 %s 未注册", code))
+	}
+	return t.Render(params)
+}
+
+// Segments 计算发送请求的计费条数，模板未注册或参数错误时返回 false
+func (r *TemplateRegistry) Segments(req *SendRequest) (*SegmentInfo, bool) {
+	t, ok := r.Get(req.Template)
+	if !ok {
+		return nil, false
+	}
+	content, err := t.Render(req.Params)
+	if err != nil {
+		return nil, false
+	}
+	signName := req.SignName
+	if signName == "" {
+		signName = t.SignName
+	}
+	return CalculateSegments(content, signName), true
+}
+
+// templateError 创建模板参数错误（不可重试，支持 errors.Is(err, ErrInvalidParams)）
+func templateError(code, message string) *SMSError {
+	return NewSMSError(code, message, false, ErrInvalidParams).WithType(ErrorTypeFormat)
+}
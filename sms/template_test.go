@@ -0,0 +1,55 @@
+package sms
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplateValidate(t *testing.T) {
+	tpl := &Template{
+		Code:       "SMS_123",
+		Content:    "${name}您好，您的验证码为${code}，${minutes}分钟内有效。${name}请勿泄露",
+		Required:   []string{"name", "code"},
+		MaxLengths: map[string]int{"code": 6},
+	}
+	assert.Equal(t, []string{"name", "code", "minutes"}, tpl.Vars())
+
+	assert.NoError(t, tpl.Validate(map[string]string{"name": "张三", "code": "123456"}))
+
+	err := tpl.Validate(map[string]string{"name": "张三"})
+	assert.True(t, errors.Is(err, ErrInvalidParams))
+	assert.Equal(t, ErrorTypeFormat, ErrorTypeOf(err))
+	assert.False(t, IsRetryableError(err))
+
+	var smsErr *SMSError
+	require.ErrorAs(t, tpl.Validate(map[string]string{"name": "张三", "code": "1234567"}), &smsErr)
+	assert.Equal(t, "TEMPLATE_PARAM_TOO_LONG", smsErr.Code)
+
+	require.ErrorAs(t, tpl.Validate(map[string]string{"name": "张三", "code": "123456", "minute": "5"}), &smsErr)
+	assert.Equal(t, "TEMPLATE_UNKNOWN_PARAMETERS", smsErr.Code)
+
+	text, err := tpl.Render(map[string]string{"name": "张三", "code": "123456", "minutes": "5"})
+	require.NoError(t, err)
+	assert.Equal(t, "张三您好，您的验证码为123456，5分钟内有效。张三请勿泄露", text)
+}
+
+func TestTemplateRegistry(t *testing.T) {
+	registry := NewTemplateRegistry(&Template{Code: "SMS_123", Content: "您的验证码为${code}"})
+
+	assert.NoError(t, registry.Validate(&SendRequest{Template: "SMS_OTHER"}))
+	_, err := registry.Render("SMS_OTHER", nil)
+	assert.Error(t, err)
+
+	// 参数错误在限流、配额之前返回（Redis 不可用也不会调用）
+	client := NewClient(&ClientConfig{Redis: newOfflineRedis(), Provider: &stubProvider{}, Templates: registry})
+	_, err = client.Send(context.Background(), &SendRequest{Phone: "13800138000", Template: "SMS_123", Params: map[string]string{"cdoe": "123456"}})
+	assert.True(t, errors.Is(err, ErrInvalidParams))
+
+	// 由客户端生成的验证码参与校验
+	_, err = client.Send(context.Background(), &SendRequest{Phone: "13800138000", Template: "SMS_123", GenerateCode: true})
+	assert.False(t, errors.Is(err, ErrInvalidParams))
+}
@@ -0,0 +1,120 @@
+package sms
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ticketClaims 验证凭证内容
+type ticketClaims struct {
+	BizID     string `json:"b"`
+	Phone     string `json:"p"`
+	Nonce     string `json:"n"`
+	ExpiresAt int64  `json:"e"` // 过期时间（Unix 秒）
+}
+
+// IssueTicket 签发一次性验证凭证，凭证绑定业务和手机号
+// 凭证格式：base64(内容).base64(HMAC-SHA256 签名)，Redis 记录随机数用于保证只能使用一次
+func (m *CodeManager) IssueTicket(ctx context.Context, bizID, phone string) (string, error) {
+	ticket, claims, err := m.newTicket(bizID, phone)
+	if err != nil {
+		return "", err
+	}
+	if err := m.redis.Set(ctx, getTicketKey(claims.Nonce), claims.value(), m.config.TicketTTL).Err(); err != nil {
+		return "", err
+	}
+	return ticket, nil
+}
+
+// newTicket 生成并签名验证凭证（不写入 Redis）
+func (m *CodeManager) newTicket(bizID, phone string) (string, *ticketClaims, error) {
+	if m.config.Secret == "" {
+		return "", nil, errors.New("签发验证凭证需要配置 CodeConfig.Secret")
+	}
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", nil, err
+	}
+
+	claims := &ticketClaims{
+		BizID:     bizID,
+		Phone:     normalizePhone(phone, ""),
+		Nonce:     hex.EncodeToString(nonce),
+		ExpiresAt: time.Now().Add(m.config.TicketTTL).Unix(),
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", nil, err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + m.signTicket(encoded), claims, nil
+}
+
+// value 凭证在 Redis 中记录的值
+func (c *ticketClaims) value() string {
+	return c.BizID + ":" + c.Phone
+}
+
+// ConsumeTicket 校验并作废验证凭证，凭证必须由同一业务、同一手机号的验证签发，且只能使用一次
+// 返回 ErrTicketInvalid、ErrTicketExpired 或 ErrTicketUsed
+func (m *CodeManager) ConsumeTicket(ctx context.Context, ticket, bizID, phone string) error {
+	claims, err := m.parseTicket(ticket)
+	if err != nil {
+		return err
+	}
+	if claims.BizID != bizID || normalizePhone(claims.Phone, "") != normalizePhone(phone, "") {
+		return ErrTicketInvalid
+	}
+	if time.Now().Unix() >= claims.ExpiresAt {
+		return ErrTicketExpired
+	}
+
+	_, err = m.redis.GetDel(ctx, getTicketKey(claims.Nonce)).Result()
+	if err == redis.Nil {
+		return ErrTicketUsed
+	}
+	return err
+}
+
+// parseTicket 校验签名并解析凭证内容
+func (m *CodeManager) parseTicket(ticket string) (*ticketClaims, error) {
+	if m.config.Secret == "" {
+		return nil, ErrTicketInvalid
+	}
+
+	encoded, signature, ok := strings.Cut(ticket, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(m.signTicket(encoded))) {
+		return nil, ErrTicketInvalid
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrTicketInvalid
+	}
+	var claims ticketClaims
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Nonce == "" {
+		return nil, ErrTicketInvalid
+	}
+	return &claims, nil
+}
+
+// signTicket 计算凭证签名（与验证码哈希使用不同的前缀，避免签名被挪用）
+func (m *CodeManager) signTicket(encoded string) string {
+	return base64.RawURLEncoding.EncodeToString(hmacSHA256([]byte(m.config.Secret), "ticket:"+encoded))
+}
+
+// getTicketKey 获取验证凭证的key
+func getTicketKey(nonce string) string {
+	return "sms:ticket:" + nonce
+}
@@ -0,0 +1,77 @@
+package sms
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsumeTicket(t *testing.T) {
+	ctx := context.Background()
+	codes := NewCodeManager(newOfflineRedis(), &CodeConfig{Secret: "s3cret"})
+
+	sign := func(claims *ticketClaims) string {
+		payload, err := json.Marshal(claims)
+		require.NoError(t, err)
+		encoded := base64.RawURLEncoding.EncodeToString(payload)
+		return encoded + "." + codes.signTicket(encoded)
+	}
+	valid := &ticketClaims{BizID: "login", Phone: "13800138000", Nonce: "abc", ExpiresAt: time.Now().Add(time.Minute).Unix()}
+	ticket := sign(valid)
+
+	claims, err := codes.parseTicket(ticket)
+	require.NoError(t, err)
+	assert.Equal(t, valid, claims)
+
+	// 篡改、换绑、过期的凭证在访问 Redis 前即被拒绝
+	assert.ErrorIs(t, codes.ConsumeTicket(ctx, ticket+"x", "login", "13800138000"), ErrTicketInvalid)
+	assert.ErrorIs(t, codes.ConsumeTicket(ctx, "garbage", "login", "13800138000"), ErrTicketInvalid)
+	assert.ErrorIs(t, codes.ConsumeTicket(ctx, ticket, "pay", "13800138000"), ErrTicketInvalid)
+	assert.ErrorIs(t, codes.ConsumeTicket(ctx, ticket, "login", "13900139000"), ErrTicketInvalid)
+
+	other := NewCodeManager(newOfflineRedis(), &CodeConfig{Secret: "other"})
+	assert.ErrorIs(t, other.ConsumeTicket(ctx, ticket, "login", "13800138000"), ErrTicketInvalid)
+
+	expired := *valid
+	expired.ExpiresAt = time.Now().Add(-time.Second).Unix()
+	assert.ErrorIs(t, codes.ConsumeTicket(ctx, sign(&expired), "login", "13800138000"), ErrTicketExpired)
+
+	// 未配置密钥时不能签发凭证
+	_, err = NewCodeManager(newOfflineRedis(), nil).Verify(ctx, &VerifyRequest{Phone: "13800138000", Code: "1234", IssueTicket: true})
+	assert.Error(t, err)
+}
+
+func TestVerifyIssueTicket(t *testing.T) {
+	ctx := context.Background()
+	rdb, server := newTestRedis(t)
+	client := NewClient(&ClientConfig{
+		Redis:      rdb,
+		Provider:   &stubProvider{},
+		CodeConfig: &CodeConfig{Secret: "s3cret"},
+	})
+	require.NoError(t, client.codes.Save(ctx, "reset_password", "13800138000", "123456"))
+
+	// 验证失败时不写入凭证
+	resp, err := client.Verify(ctx, &VerifyRequest{Phone: "13800138000", Code: "000000", BizID: "reset_password", IssueTicket: true})
+	require.NoError(t, err)
+	assert.False(t, resp.Success)
+	assert.Empty(t, resp.Ticket)
+	for _, key := range server.Keys() {
+		assert.NotContains(t, key, "sms:ticket:")
+	}
+
+	resp, err = client.Verify(ctx, &VerifyRequest{Phone: "13800138000", Code: "123456", BizID: "reset_password", IssueTicket: true})
+	require.NoError(t, err)
+	require.True(t, resp.Success)
+	require.NotEmpty(t, resp.Ticket)
+
+	// 凭证只能使用一次
+	assert.ErrorIs(t, client.ConsumeTicket(ctx, resp.Ticket, "login", "13800138000"), ErrTicketInvalid)
+	require.NoError(t, client.ConsumeTicket(ctx, resp.Ticket, "reset_password", "+86 138 0013 8000"))
+	assert.ErrorIs(t, client.ConsumeTicket(ctx, resp.Ticket, "reset_password", "13800138000"), ErrTicketUsed)
+}
@@ -2,7 +2,10 @@ package sms
 
 import (
 	"context"
+	"strings"
 	"time"
+
+	"github.com/gpencil/go-common/phone"
 )
 
 // SMSProvider 短信服务商接口
@@ -10,9 +13,6 @@ type SMSProvider interface {
 	// Send 发送短信
 	Send(ctx context.Context, req *SendRequest) (*SendResponse, error)
 
-	// Verify 验证短信验证码
-	Verify(ctx context.Context, req *VerifyRequest) (*VerifyResponse, error)
-
 	// QueryStatus 通过消息ID查询短信发送状态
 	QueryStatus(ctx context.Context, msgID string) (*StatusResponse, error)
 
@@ -20,6 +20,49 @@ type SMSProvider interface {
 	QueryStatusByPhone(ctx context.Context, phone string) ([]*StatusResponse, error)
 }
 
+// HealthChecker 健康检查接口（可选实现）
+// 实现该接口的服务商在不健康时会被 WeightedProvider 跳过
+type HealthChecker interface {
+	// Healthy 是否健康
+	Healthy() bool
+}
+
+// BatchProvider 批量发送接口（可选实现）
+// 实现该接口的服务商由 Client.SendBatch 合并为批量请求发送，否则逐条发送
+type BatchProvider interface {
+	// SendBatch 批量发送同一模板、同一外部ID的短信，返回的响应与 reqs 一一对应
+	// 服务商只返回一个批次ID时，所有接收人的 MsgID 相同，状态报告按 MsgID + 手机号匹配发送记录
+	SendBatch(ctx context.Context, reqs []*SendRequest) ([]*SendResponse, error)
+
+	// BatchSize 单次批量发送的最大接收人数
+	BatchSize() int
+}
+
+// MessageStatusQuerier 按手机号和消息ID查询单条短信状态（可选实现）
+// 服务商的查询结果不含消息ID、需要按消息ID过滤时实现（如阿里云 QuerySendDetails 按 BizId 查询），Reconciler 优先使用
+type MessageStatusQuerier interface {
+	// QueryMessageStatus 查询 sentAt 发送的短信状态（sentAt 用于确定查询日期，为零值时查询最近两天），查询不到时返回 nil
+	QueryMessageStatus(ctx context.Context, phone, msgID string, sentAt time.Time) (*StatusResponse, error)
+}
+
+// queryMessageStatus 按手机号和消息ID查询短信状态
+// 服务商没有实现 MessageStatusQuerier 时通过 QueryStatusByPhone 查询后按消息ID匹配，查询不到时返回 nil
+func queryMessageStatus(ctx context.Context, provider SMSProvider, phone, msgID string, sentAt time.Time) (*StatusResponse, error) {
+	if querier, ok := provider.(MessageStatusQuerier); ok {
+		return querier.QueryMessageStatus(ctx, phone, msgID, sentAt)
+	}
+	statuses, err := provider.QueryStatusByPhone(ctx, phone)
+	if err != nil {
+		return nil, err
+	}
+	for _, status := range statuses {
+		if status.MsgID == msgID {
+			return status, nil
+		}
+	}
+	return nil, nil
+}
+
 // SendRequest 发送短信请求
 type SendRequest struct {
 	Phone       string            // 手机号（不含国家代码，如：13800138000）
@@ -28,36 +71,75 @@ type SendRequest struct {
 	Params      map[string]string // 模板参数（用于替换模板中的${变量}）
 	BizID       string            // 业务ID (login/register/pay等)
 	DeviceID    string            // 设备ID（用于防刷）
+	UserID      string            // 用户账号ID（用于防刷，登录态下填写）
 	IP          string            // IP地址（用于防刷）
 	SignName    string            // 签名名称（如：阿里云）
 	OutID       string            // 外部ID，用于业务追踪
+
+	// GenerateCode 由 Client 生成验证码并注入模板参数（参数名见 CodeConfig.ParamName）
+	// 为 false 时，模板参数中已有的验证码仍会在发送成功后保存，用于 Verify
+	GenerateCode bool
+
+	// IdempotencyKey 幂等键（可选），相同幂等键的重复请求直接返回首次成功的结果，不会重复发送和计费
+	// 发送被明确拒绝时不保存结果，可以使用同一幂等键重试；发送超时等结果未知时重复请求返回 ErrIdempotencyUnknown
+	// 同一幂等键只能用于手机号、模板和参数都相同的请求
+	IdempotencyKey string
 }
 
 // SendResponse 发送短信响应
 type SendResponse struct {
 	MsgID     string // 消息ID（服务商返回的唯一标识，用于追踪和查询状态）
+	Provider  string // 实际发送的服务商名称（多服务商路由时填写）
 	Success   bool   // 是否成功
 	ErrorCode string // 错误码
 	ErrorMsg  string // 错误信息
+
+	// 以下字段在 ClientConfig.Templates 中注册了模板时填写
+	Segments      int     // 计费条数（含签名）
+	EstimatedCost float64 // 预估费用（配置 ClientConfig.PriceTable 时填写）
 }
 
 // VerifyRequest 验证短信请求
 type VerifyRequest struct {
-	Phone string // 手机号
-	Code  string // 验证码
-	BizID string // 业务ID
+	Phone       string // 手机号
+	CountryCode string // 国家代码（默认+86），与发送时一致
+	Code        string // 验证码
+	BizID       string // 业务ID
+	IssueTicket bool   // 验证成功后签发一次性凭证（需配置 CodeConfig.Secret），用于证明后续操作前已完成验证
+}
+
+// GetFullPhone 获取完整手机号（E.164 格式），国家代码为空时默认 +86
+func (r *VerifyRequest) GetFullPhone() string {
+	return normalizePhone(r.Phone, r.CountryCode)
 }
 
 // VerifyResponse 验证短信响应
 type VerifyResponse struct {
-	Success bool   // 是否验证成功
-	ErrMsg  string // 错误信息
+	Success           bool          // 是否验证成功
+	Reason            VerifyReason  // 验证失败原因（成功时为空）
+	RemainingAttempts int           // 剩余可尝试次数（Reason 为 mismatch 时有效）
+	RetryAfter        time.Duration // 距离解除锁定的剩余时间（Reason 为 locked、too_many_attempts 时有效）
+	Ticket            string        // 一次性验证凭证（请求设置 IssueTicket 且验证成功时返回），通过 Client.ConsumeTicket 校验
+
+	// Deprecated: ErrMsg 仅用于展示，请使用 Reason 判断失败原因
+	ErrMsg string
 }
 
+// VerifyReason 验证失败原因
+type VerifyReason string
+
+const (
+	VerifyReasonExpired         VerifyReason = "expired"           // 验证码不存在或已过期
+	VerifyReasonMismatch        VerifyReason = "mismatch"          // 验证码错误
+	VerifyReasonLocked          VerifyReason = "locked"            // 错误次数过多，手机号在该业务下已被锁定
+	VerifyReasonTooManyAttempts VerifyReason = "too_many_attempts" // 本次错误达到最大次数，验证码失效并锁定
+)
+
 // StatusResponse 查询短信状态响应
 type StatusResponse struct {
 	MsgID       string        // 消息ID（必须！一个手机号可能有多条短信，通过MsgID精确标识）
 	Phone       string        // 手机号（方便查看是哪个号码的短信）
+	Provider    string        // 服务商名称（多服务商路由时填写）
 	Status      MessageStatus // 消息状态
 	SentTime    int64         // 发送时间（Unix时间戳）
 	ReceiveTime int64         // 接收时间（Unix时间戳）
@@ -77,10 +159,11 @@ const (
 
 // SMSError 短信错误类型
 type SMSError struct {
-	Code      string // 错误码
-	Message   string // 错误信息
-	Retryable bool   // 是否可重试
-	RawError  error  // 原始错误
+	Code      string    // 错误码
+	Message   string    // 错误信息
+	Retryable bool      // 是否可重试
+	Type      ErrorType // 错误类型（服务商错误码映射后的统一类型，为空时按 Code 推断）
+	RawError  error     // 原始错误
 }
 
 func (e *SMSError) Error() string {
@@ -90,6 +173,17 @@ func (e *SMSError) Error() string {
 	return e.Message
 }
 
+// Unwrap 返回原始错误，支持 errors.Is / errors.As
+func (e *SMSError) Unwrap() error {
+	return e.RawError
+}
+
+// WithType 设置错误类型（链式调用）
+func (e *SMSError) WithType(errType ErrorType) *SMSError {
+	e.Type = errType
+	return e
+}
+
 // ErrorType 错误类型枚举
 type ErrorType string
 
@@ -103,8 +197,21 @@ const (
 	ErrorTypeOther        ErrorType = "other"         // 其他错误
 )
 
+// LimiterMode 限流模式
+type LimiterMode string
+
+const (
+	// LimiterModeFixed 固定窗口（默认）：按自然分钟/小时/天计数，每个维度一个计数器，开销最小；
+	// 窗口边界处可能出现短时间内连续发送（如 10:59:59 和 11:00:00）
+	LimiterModeFixed LimiterMode = "fixed"
+	// LimiterModeSliding 滑动窗口：基于 Redis 有序集合记录每次发送时间，统计任意连续窗口内的次数
+	LimiterModeSliding LimiterMode = "sliding"
+)
+
 // LimiterConfig 限流配置
 type LimiterConfig struct {
+	Mode LimiterMode // 限流模式，默认固定窗口
+
 	// 基于手机号的限制
 	PhonePerMinute int // 每分钟手机号限制
 	PhonePerHour   int // 每小时手机号限制
@@ -115,6 +222,11 @@ type LimiterConfig struct {
 
 	// 基于IP的限制
 	IPPerDay int // 每天IP限制
+
+	// 基于用户账号的限制（请求带 UserID 时生效）
+	UserPerMinute int // 每分钟用户限制
+	UserPerHour   int // 每小时用户限制
+	UserPerDay    int // 每天用户限制
 }
 
 // DefaultLimiterConfig 默认限流配置
@@ -125,21 +237,55 @@ func DefaultLimiterConfig() *LimiterConfig {
 		PhonePerDay:    10,
 		DevicePerDay:   10,
 		IPPerDay:       10,
+		UserPerMinute:  1,
+		UserPerHour:    5,
+		UserPerDay:     20,
 	}
 }
 
 // QuotaConfig 业务配额配置
 type QuotaConfig struct {
 	BizID     string // 业务ID
-	MaxPerDay int    // 每个手机号每天最大次数
+	MaxPerDay int    // 该业务每天最大次数（所有手机号合计）
 }
 
 // RetryConfig 重试配置
 type RetryConfig struct {
-	MaxRetries int           // 最大重试次数
-	RetryDelay time.Duration // 重试延迟
+	MaxRetries int             // 最大重试次数
+	RetryDelay time.Duration   // 重试延迟（退避基数）
+	Strategy   BackoffStrategy // 退避策略，默认线性退避
+	Multiplier float64         // 指数退避倍数，默认 2
+	MaxDelay   time.Duration   // 单次延迟上限（可选，0 表示不限制）
+	Jitter     JitterMode      // 抖动模式，默认不抖动
+	MaxElapsed time.Duration   // 重试总时长预算（可选）：从首次调用开始计算，等待后会超出预算时不再重试
+
+	// Classifier 自定义重试判断（可选），优先于 IsRetryableError、ShouldRetry
+	// 发送失败但没有返回错误时，err 为由响应错误码生成的 *SMSError
+	Classifier RetryClassifier
 }
 
+// BackoffStrategy 退避策略
+type BackoffStrategy string
+
+const (
+	BackoffLinear      BackoffStrategy = "linear"      // 线性退避（默认）：RetryDelay * 重试次数
+	BackoffConstant    BackoffStrategy = "constant"    // 固定延迟：RetryDelay
+	BackoffExponential BackoffStrategy = "exponential" // 指数退避：RetryDelay * Multiplier^(重试次数-1)
+)
+
+// JitterMode 退避抖动模式，避免大量请求同时重试
+type JitterMode string
+
+const (
+	JitterNone  JitterMode = "none"  // 不抖动（默认）
+	JitterFull  JitterMode = "full"  // 全抖动：[0, delay) 内随机
+	JitterEqual JitterMode = "equal" // 等抖动：delay/2 + [0, delay/2) 内随机
+)
+
+// RetryClassifier 自定义重试判断
+// handled 为 false 时表示不处理该错误，使用默认规则判断
+type RetryClassifier func(err error) (retry bool, handled bool)
+
 // DefaultRetryConfig 默认重试配置
 func DefaultRetryConfig() *RetryConfig {
 	return &RetryConfig{
@@ -150,12 +296,26 @@ func DefaultRetryConfig() *RetryConfig {
 
 // ========== 辅助函数 ==========
 
-// GetFullPhone 获取完整手机号（国家代码+手机号）
+// GetFullPhone 获取完整手机号（E.164 格式，如 +8613800138000），国家代码为空时默认 +86
+// 手机号格式错误时返回 国家代码+手机号
 func (r *SendRequest) GetFullPhone() string {
-	if r.CountryCode == "" {
-		r.CountryCode = "+86" // 默认中国
+	return normalizePhone(r.Phone, r.CountryCode)
+}
+
+// normalizePhone 手机号标准化为 E.164 格式，用于限流、配额、验证码等 key，避免格式差异（空格、+86 前缀等）绕过限制
+// 格式错误时原样返回（已包含 + 时不再拼接国家代码）
+func normalizePhone(number, countryCode string) string {
+	if countryCode == "" {
+		countryCode = "+86" // 默认中国
+	}
+	if normalized, err := phone.Normalize(number, countryCode); err == nil {
+		return normalized
+	}
+	number = strings.TrimSpace(number)
+	if strings.HasPrefix(number, "+") {
+		return number
 	}
-	return r.CountryCode + r.Phone
+	return countryCode + number
 }
 
 // SetCountryCode 设置国家代码（链式调用）
@@ -0,0 +1,86 @@
+package sms
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetFullPhone(t *testing.T) {
+	req := &SendRequest{Phone: "138-0013-8000"}
+	assert.Equal(t, "+8613800138000", req.GetFullPhone())
+	// 不修改请求
+	assert.Equal(t, "138-0013-8000", req.Phone)
+	assert.Empty(t, req.CountryCode)
+
+	for _, input := range []string{"13800138000", "+86 138 0013 8000", "008613800138000", "8613800138000"} {
+		assert.Equal(t, "+8613800138000", (&SendRequest{Phone: input}).GetFullPhone(), input)
+	}
+	assert.Equal(t, "+447911123456", (&SendRequest{Phone: "07911 123456", CountryCode: "+44"}).GetFullPhone())
+	assert.Equal(t, "+8613800138000", (&VerifyRequest{Phone: "+86 13800138000"}).GetFullPhone())
+
+	// 格式错误时原样拼接
+	assert.Equal(t, "+86abc", (&SendRequest{Phone: "abc"}).GetFullPhone())
+}
+
+func TestNormalizedKeys(t *testing.T) {
+	now := time.Now()
+	limiter := NewRateLimiter(newOfflineRedis(), &LimiterConfig{PhonePerMinute: 1, PhonePerDay: 10})
+	keys := func(req *SendRequest) []string {
+		var keys []string
+		for _, rule := range limiter.rules(limiter.config, req, now) {
+			keys = append(keys, rule.key)
+		}
+		return keys
+	}
+
+	// 格式不同的同一手机号共用限流计数和路由记录
+	plain := &SendRequest{Phone: "13800138000", BizID: "login"}
+	formatted := &SendRequest{Phone: "+86 138-0013-8000", BizID: "login"}
+	assert.Equal(t, keys(plain), keys(formatted))
+	assert.Equal(t, getPhoneRouteKey("13800138000"), getPhoneRouteKey("+8613800138000"))
+}
+
+func TestSendInvalidPhone(t *testing.T) {
+	provider := &stubProvider{}
+	client := NewClient(&ClientConfig{
+		Redis:    newOfflineRedis(),
+		Provider: provider,
+	})
+
+	// 手机号格式错误时在访问 Redis、调用服务商之前返回
+	for _, req := range []*SendRequest{
+		{Phone: "1380013800", Template: "SMS_123", BizID: "login"},
+		{Phone: "abc", Template: "SMS_123", BizID: "login"},
+		{Phone: "13800138000", CountryCode: "ZZ", Template: "SMS_123", BizID: "login"},
+		{Phone: "+880 123", Template: "SMS_123", BizID: "login"},
+	} {
+		_, err := client.Send(context.Background(), req)
+		require.Error(t, err, req.Phone)
+		assert.True(t, errors.Is(err, ErrInvalidParams), req.Phone)
+
+		var smsErr *SMSError
+		require.True(t, errors.As(err, &smsErr))
+		assert.Equal(t, ErrorTypeInvalidPhone, smsErr.Type)
+	}
+	assert.Zero(t, provider.calls)
+}
+
+func TestPrepareUnregisteredCountry(t *testing.T) {
+	client := NewClient(&ClientConfig{Redis: newOfflineRedis(), Provider: &stubProvider{}})
+
+	// 未注册号段规则的国家代码按 E.164 通用规则放行
+	cloned, _, err := client.prepare(&SendRequest{Phone: "+880 1712-345678", Template: "SMS_123"})
+	require.NoError(t, err)
+	assert.Equal(t, "+8801712345678", cloned.GetFullPhone())
+
+	cloned, _, err = client.prepare(&SendRequest{Phone: "1712 345678", CountryCode: "+880", Template: "SMS_123"})
+	require.NoError(t, err)
+	assert.Equal(t, "1712345678", cloned.Phone)
+	assert.Equal(t, "+880", cloned.CountryCode)
+	assert.Equal(t, "+8801712345678", cloned.GetFullPhone())
+}
@@ -0,0 +1,195 @@
+package sms
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// WeightedProvider 多服务商加权负载均衡
+// 按权重把发送流量分配到各服务商，每条短信只由一个服务商发送，并自动跳过不健康的服务商
+type WeightedProvider struct {
+	*providerGroup // QueryStatus、QueryStatusByPhone 路由到原服务商
+
+	config *WeightedConfig
+
+	mu      sync.RWMutex
+	weights map[string]int       // 服务商名称 -> 权重
+	fails   map[string]int       // 服务商名称 -> 连续失败次数
+	ejected map[string]time.Time // 服务商名称 -> 摘除截止时间
+}
+
+// WeightedConfig 加权负载均衡配置
+type WeightedConfig struct {
+	MaxFails      int           // 连续失败多少次后临时摘除服务商，默认 3，小于 0 表示不摘除
+	EjectDuration time.Duration // 摘除时长，默认 30 秒
+	RouteTTL      time.Duration // 路由记录保存时间，默认 72 小时
+}
+
+// DefaultWeightedConfig 默认加权负载均衡配置
+func DefaultWeightedConfig() *WeightedConfig {
+	return &WeightedConfig{
+		MaxFails:      3,
+		EjectDuration: 30 * time.Second,
+		RouteTTL:      72 * time.Hour,
+	}
+}
+
+// NewWeightedProvider 创建加权负载均衡服务商，权重取自 ProviderEntry.Weight
+func NewWeightedProvider(redis *redis.Client, entries []*ProviderEntry, config *WeightedConfig) (*WeightedProvider, error) {
+	if config == nil {
+		config = DefaultWeightedConfig()
+	}
+	// 复制配置，填充默认值不影响调用方
+	cloned := *config
+	config = &cloned
+	if config.MaxFails == 0 {
+		config.MaxFails = 3
+	}
+	if config.EjectDuration == 0 {
+		config.EjectDuration = 30 * time.Second
+	}
+	if err := validateEntries(entries); err != nil {
+		return nil, err
+	}
+
+	weights := make(map[string]int, len(entries))
+	for _, entry := range entries {
+		if entry.Weight < 0 {
+			return nil, fmt.Errorf("服务商权重不能为负数: %s", entry.Name)
+		}
+		weights[entry.Name] = entry.Weight
+	}
+
+	return &WeightedProvider{
+		providerGroup: newProviderGroup(redis, entries, config.RouteTTL),
+		config:        config,
+		weights:       weights,
+		fails:         make(map[string]int),
+		ejected:       make(map[string]time.Time),
+	}, nil
+}
+
+// SetWeight 运行时调整服务商权重
+func (w *WeightedProvider) SetWeight(name string, weight int) error {
+	if _, ok := w.byName[name]; !ok {
+		return fmt.Errorf("短信服务商不存在: %s", name)
+	}
+	if weight < 0 {
+		return fmt.Errorf("服务商权重不能为负数: %s", name)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.weights[name] = weight
+	return nil
+}
+
+// Weights 获取当前各服务商权重
+func (w *WeightedProvider) Weights() map[string]int {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	weights := make(map[string]int, len(w.weights))
+	for name, weight := range w.weights {
+		weights[name] = weight
+	}
+	return weights
+}
+
+// Send 发送短信（按权重选择一个服务商）
+func (w *WeightedProvider) Send(ctx context.Context, req *SendRequest) (*SendResponse, error) {
+	entry := w.pick()
+	if entry == nil {
+		return nil, NewSMSError("NO_PROVIDER", "没有可用的短信服务商", false, nil).WithType(ErrorTypeCircuitBreak)
+	}
+
+	resp, err := w.send(ctx, entry, req)
+	if ctx.Err() == nil {
+		w.onResult(entry.Name, err)
+	}
+	return resp, err
+}
+
+// ========== 辅助方法 ==========
+
+// pick 按权重随机选择服务商，优先选择健康的服务商；全部不健康时在所有有权重的服务商中选择
+func (w *WeightedProvider) pick() *ProviderEntry {
+	now := time.Now()
+
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	var healthy, weighted []*ProviderEntry
+	var healthyTotal, weightedTotal int
+	for _, entry := range w.entries {
+		weight := w.weights[entry.Name]
+		if weight <= 0 {
+			continue
+		}
+		weighted = append(weighted, entry)
+		weightedTotal += weight
+
+		if w.isHealthy(entry, now) {
+			healthy = append(healthy, entry)
+			healthyTotal += weight
+		}
+	}
+
+	if len(healthy) > 0 {
+		return w.pickWeighted(healthy, healthyTotal)
+	}
+	if len(weighted) > 0 {
+		return w.pickWeighted(weighted, weightedTotal)
+	}
+	return nil
+}
+
+// pickWeighted 按权重随机选择（需持有读锁）
+func (w *WeightedProvider) pickWeighted(entries []*ProviderEntry, total int) *ProviderEntry {
+	n := rand.Intn(total)
+	for _, entry := range entries {
+		n -= w.weights[entry.Name]
+		if n < 0 {
+			return entry
+		}
+	}
+	return entries[len(entries)-1]
+}
+
+// isHealthy 判断服务商是否健康（需持有读锁）
+func (w *WeightedProvider) isHealthy(entry *ProviderEntry, now time.Time) bool {
+	if until, ok := w.ejected[entry.Name]; ok && now.Before(until) {
+		return false
+	}
+	if checker, ok := entry.Provider.(HealthChecker); ok {
+		return checker.Healthy()
+	}
+	return true
+}
+
+// onResult 记录发送结果，连续失败达到阈值时临时摘除服务商
+func (w *WeightedProvider) onResult(name string, err error) {
+	if w.config.MaxFails < 0 {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err == nil || !isBreakerFailure(ErrorTypeOf(err)) {
+		w.fails[name] = 0
+		delete(w.ejected, name)
+		return
+	}
+
+	w.fails[name]++
+	if w.fails[name] >= w.config.MaxFails {
+		w.fails[name] = 0
+		w.ejected[name] = time.Now().Add(w.config.EjectDuration)
+	}
+}
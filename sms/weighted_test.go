@@ -0,0 +1,67 @@
+package sms
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWeightedProvider(t *testing.T) {
+	ctx := context.Background()
+	primary, secondary := &stubProvider{}, &stubProvider{}
+
+	weighted, err := NewWeightedProvider(newOfflineRedis(), []*ProviderEntry{
+		{Name: "primary", Provider: primary, Weight: 70},
+		{Name: "secondary", Provider: secondary, Weight: 30},
+	}, &WeightedConfig{MaxFails: 2})
+	require.NoError(t, err)
+
+	// 权重为 0 的服务商不分配流量
+	require.NoError(t, weighted.SetWeight("secondary", 0))
+	for i := 0; i < 20; i++ {
+		resp, err := weighted.Send(ctx, &SendRequest{Phone: "13800138000"})
+		require.NoError(t, err)
+		assert.Equal(t, "primary", resp.Provider)
+	}
+	assert.Equal(t, 20, primary.calls)
+	assert.Error(t, weighted.SetWeight("unknown", 1))
+
+	// 连续失败后摘除，流量切到其他服务商
+	require.NoError(t, weighted.SetWeight("secondary", 30))
+	primary.err = NewSMSError("isv.OUT_OF_SERVICE", "停机", false, nil).WithType(ErrorTypeCircuitBreak)
+	for primary.calls < 22 {
+		_, _ = weighted.Send(ctx, &SendRequest{Phone: "13800138000"})
+	}
+	for i := 0; i < 10; i++ {
+		resp, err := weighted.Send(ctx, &SendRequest{Phone: "13800138000"})
+		require.NoError(t, err)
+		assert.Equal(t, "secondary", resp.Provider)
+	}
+	assert.Equal(t, 22, primary.calls)
+}
+
+func TestProviderConfigsNotMutated(t *testing.T) {
+	weightedConfig := &WeightedConfig{}
+	_, err := NewWeightedProvider(newOfflineRedis(), []*ProviderEntry{{Name: "stub", Provider: &stubProvider{}, Weight: 1}}, weightedConfig)
+	require.NoError(t, err)
+	assert.Equal(t, WeightedConfig{}, *weightedConfig)
+
+	tencentConfig := &TencentConfig{SecretID: "id", SecretKey: "key", SdkAppID: "1400000000"}
+	_, err = NewTencentProvider(tencentConfig)
+	require.NoError(t, err)
+	assert.Empty(t, tencentConfig.Region)
+	assert.Empty(t, tencentConfig.Endpoint)
+	assert.Zero(t, tencentConfig.Timeout)
+
+	aliyunConfig := &AliyunConfig{AccessKeyID: "id", AccessKeySecret: "secret"}
+	_, err = NewAliyunProvider(nil, aliyunConfig)
+	require.NoError(t, err)
+	assert.Empty(t, aliyunConfig.Endpoint)
+	assert.Zero(t, aliyunConfig.ReadTimeout)
+
+	retryConfig := &RetryConfig{MaxRetries: 1}
+	NewRetryProvider(&stubProvider{}, retryConfig)
+	assert.Equal(t, RetryConfig{MaxRetries: 1}, *retryConfig)
+}